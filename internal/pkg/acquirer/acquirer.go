@@ -25,6 +25,7 @@ import (
 
 type Acquirer interface {
 	acquire(dest string) error
+	status(dest string) (SourceStatus, error)
 	FullyQualifiedId() (string, error)
 	Id() string
 	Path() string
@@ -57,6 +58,22 @@ func Acquire(a Acquirer, dest string) error {
 	return a.acquire(dest)
 }
 
+// Details of a source that's been (or should have been) acquired into a directory
+type SourceStatus struct {
+	Acquirer   string `json:"acquirer"`             // type of acquirer, e.g. git or file
+	Uri        string `json:"uri"`                  // where the source comes from
+	Cached     bool   `json:"cached"`               // whether the source exists at its destination
+	Requested  string `json:"requested,omitempty"`  // the branch or tag that should be checked out
+	CheckedOut string `json:"checkedOut,omitempty"` // the branch or tag that's actually checked out
+	Sha        string `json:"sha,omitempty"`        // commit SHA of the checkout
+	Dirty      bool   `json:"dirty"`                // whether there are uncommitted changes in the checkout
+}
+
+// Delegate to an acquirer implementation to report the status of a source previously acquired to `dest`
+func Status(a Acquirer, dest string) (SourceStatus, error) {
+	return a.status(dest)
+}
+
 // Takes a list of Sources and returns a list of instantiated acquirers that represent them
 func GetAcquirersFromSources(sources map[string]structs.Source, installableId string) (map[string]Acquirer, error) {
 	acquirers := make(map[string]Acquirer, len(sources))
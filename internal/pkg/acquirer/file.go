@@ -98,3 +98,23 @@ func (a FileAcquirer) acquire(dest string) error {
 
 	return nil
 }
+
+// Reports whether the file exists. Files aren't copied into workspaces so `dest` is ignored, and
+// they aren't version controlled so there's nothing else to report.
+func (a FileAcquirer) status(dest string) (SourceStatus, error) {
+	status := SourceStatus{
+		Acquirer: "file",
+		Uri:      a.uri,
+	}
+
+	if _, err := os.Stat(a.Path()); err != nil {
+		if os.IsNotExist(err) {
+			return status, nil
+		}
+		return status, errors.WithStack(err)
+	}
+
+	status.Cached = true
+
+	return status, nil
+}
@@ -276,3 +276,66 @@ func (a GitAcquirer) update(dest string) error {
 
 	return nil
 }
+
+// Reports which branch/tag and commit is checked out in `dest` and whether it contains uncommitted changes
+func (a GitAcquirer) status(dest string) (SourceStatus, error) {
+	status := SourceStatus{
+		Acquirer:  "git",
+		Uri:       a.Uri(),
+		Requested: a.branch,
+	}
+
+	if _, err := os.Stat(dest); err != nil {
+		if os.IsNotExist(err) {
+			return status, nil
+		}
+		return status, errors.WithStack(err)
+	}
+
+	status.Cached = true
+
+	checkedOut, err := gitOutput(dest, "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return status, errors.WithStack(err)
+	}
+
+	// tags are checked out into a detached head, so see if one points at it
+	if checkedOut == "HEAD" {
+		tag, err := gitOutput(dest, "describe", "--tags", "--exact-match", "HEAD")
+		if err != nil {
+			log.Logger.Debugf("No tag found for the detached head in '%s': %v", dest, err)
+			checkedOut = "(detached)"
+		} else {
+			checkedOut = tag
+		}
+	}
+
+	status.CheckedOut = checkedOut
+
+	status.Sha, err = gitOutput(dest, "rev-parse", "HEAD")
+	if err != nil {
+		return status, errors.WithStack(err)
+	}
+
+	changes, err := gitOutput(dest, "status", "--porcelain")
+	if err != nil {
+		return status, errors.WithStack(err)
+	}
+
+	status.Dirty = changes != ""
+
+	return status, nil
+}
+
+// Runs a git command in a directory and returns its trimmed stdout
+func gitOutput(dir string, args ...string) (string, error) {
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	err := utils.ExecCommand(GitPath, args, map[string]string{}, &stdoutBuf, &stderrBuf, dir,
+		5, 0, false)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return strings.TrimSpace(stdoutBuf.String()), nil
+}
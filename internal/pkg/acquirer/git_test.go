@@ -17,8 +17,13 @@
 package acquirer
 
 import (
+	"bytes"
 	"github.com/stretchr/testify/assert"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"github.com/sugarkube/sugarkube/internal/pkg/utils"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -109,3 +114,50 @@ func TestFullyQualifiedId(t *testing.T) {
 		}
 	}
 }
+
+func TestGitStatus(t *testing.T) {
+	acquirerObj := discardErr(newGitAcquirer(
+		structs.Source{
+			Uri: "git@github.com:helm/charts.git//stable/wordpress#v1.0.0",
+		}, "test-id", true))
+
+	tempDir, err := ioutil.TempDir("", "git-status-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	// nothing has been acquired yet
+	status, err := Status(acquirerObj, filepath.Join(tempDir, "missing"))
+	assert.Nil(t, err)
+	assert.Equal(t, SourceStatus{
+		Acquirer:  "git",
+		Uri:       "git@github.com:helm/charts.git//stable/wordpress#v1.0.0",
+		Requested: "v1.0.0",
+	}, status)
+
+	// create a local repo with the tag checked out
+	var stdoutBuf, stderrBuf bytes.Buffer
+	for _, args := range [][]string{
+		{"init"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--allow-empty", "-m", "init"},
+		{"tag", "v1.0.0"},
+		{"checkout", "v1.0.0"},
+	} {
+		err = utils.ExecCommand(GitPath, args, map[string]string{}, &stdoutBuf, &stderrBuf,
+			tempDir, 5, 0, false)
+		assert.Nil(t, err)
+	}
+
+	status, err = Status(acquirerObj, tempDir)
+	assert.Nil(t, err)
+	assert.True(t, status.Cached)
+	assert.Equal(t, "v1.0.0", status.CheckedOut)
+	assert.Len(t, status.Sha, 40)
+	assert.False(t, status.Dirty)
+
+	err = ioutil.WriteFile(filepath.Join(tempDir, "new.txt"), []byte("new"), 0644)
+	assert.Nil(t, err)
+
+	status, err = Status(acquirerObj, tempDir)
+	assert.Nil(t, err)
+	assert.True(t, status.Dirty)
+}
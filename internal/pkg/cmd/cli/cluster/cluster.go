@@ -19,7 +19,9 @@ package cluster
 import (
 	"fmt"
 	"github.com/spf13/cobra"
+	"github.com/sugarkube/sugarkube/internal/pkg/installable"
 	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
+	"github.com/sugarkube/sugarkube/internal/pkg/lock"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
 	"os"
 	"os/signal"
@@ -43,6 +45,8 @@ func NewClusterCommands() *cobra.Command {
 			go func() {
 				<-signals
 				log.Logger.Info("Caught termination signal. Will try to gracefully terminate...")
+				lock.ReleaseAll()
+				installable.DeleteAllSensitiveTemplates()
 				if stackObj != nil {
 					err2 := stackObj.GetProvisioner().Close()
 					if err2 != nil {
@@ -31,15 +31,16 @@ import (
 // Launches a cluster, either local or remote.
 
 type connectCommand struct {
-	dryRun      bool
-	stackName   string
-	stackFile   string
-	provider    string
-	provisioner string
-	profile     string
-	account     string
-	cluster     string
-	region      string
+	dryRun       bool
+	stackName    string
+	stackFile    string
+	provider     string
+	provisioner  string
+	profile      string
+	account      string
+	cluster      string
+	region       string
+	kappVarFlags cmd.KappVarFlags
 }
 
 func newConnectCommand() *cobra.Command {
@@ -90,23 +91,28 @@ Note: Not all providers require all arguments. See documentation for help.
 	f.StringVarP(&c.cluster, "cluster", "c", "", "name of cluster to launch, e.g. dev1, dev2, etc.")
 	f.StringVarP(&c.account, "account", "a", "", "string identifier for the account to launch in (for providers that support it)")
 	f.StringVarP(&c.region, "region", "r", "", "name of region (for providers that support it)")
+	c.kappVarFlags.AddFlags(f)
 	return command
 }
 
 func (c *connectCommand) run() error {
 
+	kappVarOverrides, err := c.kappVarFlags.Parse()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	// CLI overrides - will be merged with and take precedence over values loaded from the stack config file
 	cliStackConfig := &structs.StackFile{
-		Provider:    c.provider,
-		Provisioner: c.provisioner,
-		Profile:     c.profile,
-		Cluster:     c.cluster,
-		Region:      c.region,
-		Account:     c.account,
+		Provider:         c.provider,
+		Provisioner:      c.provisioner,
+		Profile:          c.profile,
+		Cluster:          c.cluster,
+		Region:           c.region,
+		Account:          c.account,
+		KappVarOverrides: kappVarOverrides,
 	}
 
-	var err error
-
 	stackObj, err = stack.BuildStack(c.stackName, c.stackFile, cliStackConfig)
 	if err != nil {
 		return errors.WithStack(err)
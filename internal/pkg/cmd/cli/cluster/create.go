@@ -22,11 +22,14 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
 	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
+	"github.com/sugarkube/sugarkube/internal/pkg/lock"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
 	"github.com/sugarkube/sugarkube/internal/pkg/printer"
 	"github.com/sugarkube/sugarkube/internal/pkg/provisioner"
 	"github.com/sugarkube/sugarkube/internal/pkg/stack"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"path/filepath"
+	"time"
 )
 
 // Launches a cluster, either local or remote.
@@ -43,6 +46,9 @@ type createCommand struct {
 	region        string
 	onlineTimeout uint32
 	readyTimeout  uint32
+	waitForLock   bool
+	lockTimeout   uint32
+	kappVarFlags  cmd.KappVarFlags
 }
 
 func newCreateCommand() *cobra.Command {
@@ -92,20 +98,39 @@ Note: Not all providers require all arguments. See documentation for help.
 	f.StringVarP(&c.region, "region", "r", "", "name of region (for providers that support it)")
 	f.Uint32Var(&c.onlineTimeout, "online-timeout", 600, "max number of seconds to wait for the cluster to come online")
 	f.Uint32Var(&c.readyTimeout, "ready-timeout", 600, "max number of seconds to wait for the cluster to become ready")
+	f.BoolVar(&c.waitForLock, "wait", false, "wait for the lock if another process is already working on this stack")
+	f.Uint32Var(&c.lockTimeout, "lock-timeout", 0, "max number of seconds to wait for the lock with --wait (0 waits forever)")
+	c.kappVarFlags.AddFlags(f)
 	return command
 }
 
 func (c *createCommand) run() error {
 
+	kappVarOverrides, err := c.kappVarFlags.Parse()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	// CLI overrides - will be merged with and take precedence over values loaded from the stack config file
 	cliStackConfig := &structs.StackFile{
-		Provider:    c.provider,
-		Provisioner: c.provisioner,
-		Profile:     c.profile,
-		Cluster:     c.cluster,
-		Region:      c.region,
-		Account:     c.account,
+		Provider:         c.provider,
+		Provisioner:      c.provisioner,
+		Profile:          c.profile,
+		Cluster:          c.cluster,
+		Region:           c.region,
+		Account:          c.account,
+		KappVarOverrides: kappVarOverrides,
+	}
+
+	var runLock *lock.Lock
+	if !c.dryRun {
+		runLock, err = lock.Acquire(filepath.Dir(c.stackFile), c.stackName, c.waitForLock,
+			time.Duration(c.lockTimeout)*time.Second)
+		if err != nil {
+			return errors.WithStack(err)
+		}
 	}
+	defer func() { _ = runLock.Release() }()
 
 	stackObj, err := stack.BuildStack(c.stackName, c.stackFile, cliStackConfig)
 	if err != nil {
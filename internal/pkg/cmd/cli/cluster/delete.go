@@ -22,22 +22,28 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
 	"github.com/sugarkube/sugarkube/internal/pkg/constants"
+	"github.com/sugarkube/sugarkube/internal/pkg/lock"
 	"github.com/sugarkube/sugarkube/internal/pkg/printer"
 	"github.com/sugarkube/sugarkube/internal/pkg/stack"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"path/filepath"
+	"time"
 )
 
 type deleteCommand struct {
-	dryRun      bool
-	approved    bool
-	stackName   string
-	stackFile   string
-	provider    string
-	provisioner string
-	profile     string
-	account     string
-	cluster     string
-	region      string
+	dryRun       bool
+	approved     bool
+	stackName    string
+	stackFile    string
+	provider     string
+	provisioner  string
+	profile      string
+	account      string
+	cluster      string
+	region       string
+	waitForLock  bool
+	lockTimeout  uint32
+	kappVarFlags cmd.KappVarFlags
 }
 
 func newDeleteCommand() *cobra.Command {
@@ -68,19 +74,38 @@ func newDeleteCommand() *cobra.Command {
 	f.StringVarP(&c.cluster, "cluster", "c", "", "name of cluster to launch, e.g. dev1, dev2, etc.")
 	f.StringVarP(&c.account, "account", "a", "", "string identifier for the account to launch in (for providers that support it)")
 	f.StringVarP(&c.region, "region", "r", "", "name of region (for providers that support it)")
+	f.BoolVar(&c.waitForLock, "wait", false, "wait for the lock if another process is already working on this stack")
+	f.Uint32Var(&c.lockTimeout, "lock-timeout", 0, "max number of seconds to wait for the lock with --wait (0 waits forever)")
+	c.kappVarFlags.AddFlags(f)
 	return command
 }
 
 func (c *deleteCommand) run() error {
+	kappVarOverrides, err := c.kappVarFlags.Parse()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	// CLI overrides - will be merged with and take precedence over values loaded from the stack config file
 	cliStackConfig := &structs.StackFile{
-		Provider:    c.provider,
-		Provisioner: c.provisioner,
-		Profile:     c.profile,
-		Cluster:     c.cluster,
-		Region:      c.region,
-		Account:     c.account,
+		Provider:         c.provider,
+		Provisioner:      c.provisioner,
+		Profile:          c.profile,
+		Cluster:          c.cluster,
+		Region:           c.region,
+		Account:          c.account,
+		KappVarOverrides: kappVarOverrides,
+	}
+
+	var runLock *lock.Lock
+	if !c.dryRun {
+		runLock, err = lock.Acquire(filepath.Dir(c.stackFile), c.stackName, c.waitForLock,
+			time.Duration(c.lockTimeout)*time.Second)
+		if err != nil {
+			return errors.WithStack(err)
+		}
 	}
+	defer func() { _ = runLock.Release() }()
 
 	stackObj, err := stack.BuildStack(c.stackName, c.stackFile, cliStackConfig)
 	if err != nil {
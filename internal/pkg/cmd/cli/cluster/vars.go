@@ -42,6 +42,7 @@ type varsConfig struct {
 	cluster      string
 	region       string
 	suppress     []string
+	kappVarFlags cmd.KappVarFlags
 }
 
 func newVarsCommand() *cobra.Command {
@@ -72,19 +73,26 @@ func newVarsCommand() *cobra.Command {
 	f.StringVarP(&c.region, "region", "r", "", "name of region (for providers that support it)")
 	f.StringArrayVarP(&c.suppress, "suppress", "s", []string{},
 		"paths to variables to suppress from the output to simplify it (e.g. 'provision.specs')")
+	c.kappVarFlags.AddFlags(f)
 	return command
 }
 
 func (c *varsConfig) run() error {
 
+	kappVarOverrides, err := c.kappVarFlags.Parse()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	// CLI overrides - will be merged with any loaded from a stack config file
 	cliStackConfig := &structs.StackFile{
-		Provider:    c.provider,
-		Provisioner: c.provisioner,
-		Profile:     c.profile,
-		Cluster:     c.cluster,
-		Region:      c.region,
-		Account:     c.account,
+		Provider:         c.provider,
+		Provisioner:      c.provisioner,
+		Profile:          c.profile,
+		Cluster:          c.cluster,
+		Region:           c.region,
+		Account:          c.account,
+		KappVarOverrides: kappVarOverrides,
 	}
 
 	stackObj, err := stack.BuildStack(c.stackName, c.stackFile, cliStackConfig)
@@ -0,0 +1,188 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kapps
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
+	"github.com/sugarkube/sugarkube/internal/pkg/installer"
+	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/plan"
+	"github.com/sugarkube/sugarkube/internal/pkg/printer"
+	"github.com/sugarkube/sugarkube/internal/pkg/program"
+	"github.com/sugarkube/sugarkube/internal/pkg/stack"
+	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+type execCommand struct {
+	workspaceDir   string
+	stackName      string
+	stackFile      string
+	provider       string
+	provisioner    string
+	profile        string
+	account        string
+	cluster        string
+	region         string
+	kappId         string
+	runUnit        string
+	command        []string
+	refreshOutputs bool
+	kappVarFlags   cmd.KappVarFlags
+}
+
+func newExecCommand() *cobra.Command {
+	c := &execCommand{}
+
+	usage := "exec [flags] [stack-file] [stack-name] [workspace-dir] [manifest-id:kapp-id] -- [command...]"
+	command := &cobra.Command{
+		Use:   usage,
+		Short: fmt.Sprintf("Run a command in a kapp's environment"),
+		Long: `Runs an arbitrary command with the working directory and env vars (e.g. KUBECONFIG) 
+that the kapp's run units would be executed with. Outputs from the kapp's parents 
+are loaded first so the kapp's vars are fully templated.
+
+If the kapp has several run units, env vars from all of them are merged and the 
+command is run in the kapp's cache directory. Pass '--unit' to use a single run 
+unit instead.
+
+This is useful for debugging a failing run step, e.g.:
+
+  sugarkube kapps exec stacks.yaml dev1 workspaces/dev1 infra:vpc -- terraform plan
+`,
+		RunE: func(command *cobra.Command, args []string) error {
+			numArgs := command.ArgsLenAtDash()
+			if numArgs < 0 {
+				numArgs = len(args)
+			}
+
+			err := cmd.ValidateNumArgs(args[:numArgs], 4, usage)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			if len(args) == numArgs {
+				return program.SimpleError{Message: fmt.Sprintf("No command given to execute\nUsage: %s", usage)}
+			}
+
+			c.stackFile = args[0]
+			c.stackName = args[1]
+			c.workspaceDir = args[2]
+			c.kappId = args[3]
+			c.command = args[numArgs:]
+
+			return c.run()
+		},
+	}
+
+	c.addFlags(command.Flags())
+	return command
+}
+
+func (c *execCommand) addFlags(f *pflag.FlagSet) {
+	f.StringVar(&c.provider, "provider", "", "name of provider, e.g. aws, local, etc.")
+	f.StringVar(&c.provisioner, "provisioner", "", "name of provisioner, e.g. kops, minikube, etc.")
+	f.StringVar(&c.profile, "profile", "", "launch profile, e.g. dev, test, prod, etc.")
+	f.StringVarP(&c.cluster, "cluster", "c", "", "name of cluster to launch, e.g. dev1, dev2, etc.")
+	f.StringVarP(&c.account, "account", "a", "", "string identifier for the account to launch in (for providers that support it)")
+	f.StringVarP(&c.region, "region", "r", "", "name of region (for providers that support it)")
+	f.StringVarP(&c.runUnit, "unit", "u", "", "name of the run unit to take the working directory and env vars from "+
+		"(e.g. helm, terraform)")
+	f.BoolVar(&c.refreshOutputs, "refresh-outputs", false, "run output steps again instead of reusing outputs saved in the registry by previous runs")
+	c.kappVarFlags.AddFlags(f)
+}
+
+func (c *execCommand) run() error {
+
+	kappVarOverrides, err := c.kappVarFlags.Parse()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// CLI overrides - will be merged with any loaded from a stack config file
+	cliStackConfig := &structs.StackFile{
+		Provider:         c.provider,
+		Provisioner:      c.provisioner,
+		Profile:          c.profile,
+		Cluster:          c.cluster,
+		Region:           c.region,
+		Account:          c.account,
+		RefreshOutputs:   c.refreshOutputs,
+		KappVarOverrides: kappVarOverrides,
+	}
+
+	stackObj, err = stack.BuildStack(c.stackName, c.stackFile, cliStackConfig)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	dagObj, err := plan.BuildDagForSelected(stackObj, c.workspaceDir, []string{c.kappId}, []string{}, false)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	installableObj, err := dagObj.LoadInstallable(stackObj, c.kappId, false)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	workingDir, envVars, err := installer.RunUnitEnvironment(installableObj, stackObj, c.runUnit, false)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	strEnvVars := make([]string, 0)
+	for k, v := range envVars {
+		strEnvVars = append(strEnvVars, strings.Join([]string{k, v}, "="))
+	}
+	sort.Strings(strEnvVars)
+
+	_, err = printer.Fprintf("\n[yellow]Running '[bold]%s[reset][yellow]' for kapp '[bold]%s[reset][yellow]' "+
+		"in '%s' with env vars: %s\n\n", strings.Join(c.command, " "), c.kappId, workingDir,
+		strings.Join(strEnvVars, " "))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	command := exec.Command(c.command[0], c.command[1:]...)
+	command.Dir = workingDir
+	command.Env = append(os.Environ(), strEnvVars...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	log.Logger.Infof("Executing command %#v in directory '%s' with env vars: %#v", c.command,
+		workingDir, strEnvVars)
+
+	err = command.Run()
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			return program.SimpleError{Message: fmt.Sprintf("Command exited with code %d",
+				exitError.ExitCode())}
+		}
+		return errors.Wrapf(err, "Error running command %#v", c.command)
+	}
+
+	return nil
+}
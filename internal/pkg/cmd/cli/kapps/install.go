@@ -23,6 +23,7 @@ import (
 	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
 	"github.com/sugarkube/sugarkube/internal/pkg/constants"
 	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
+	"github.com/sugarkube/sugarkube/internal/pkg/lock"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
 	"github.com/sugarkube/sugarkube/internal/pkg/plan"
 	"github.com/sugarkube/sugarkube/internal/pkg/printer"
@@ -30,6 +31,7 @@ import (
 	"github.com/sugarkube/sugarkube/internal/pkg/provisioner"
 	"github.com/sugarkube/sugarkube/internal/pkg/stack"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"time"
 )
 
 type installCommand struct {
@@ -58,6 +60,11 @@ type installCommand struct {
 	excludeSelector     []string
 	onlineTimeout       uint32
 	readyTimeout        uint32
+	waitForLock         bool
+	lockTimeout         uint32
+	watch               bool
+	refreshOutputs      bool
+	kappVarFlags        cmd.KappVarFlags
 }
 
 func newInstallCommand() *cobra.Command {
@@ -90,6 +97,10 @@ SSH port forwarding via a bastion). This happens automatically when a cluster
 is created or updated by Sugarkube, but if you're installing individual kapps 
 you may need to pass the '--connect' flag to make Sugarkube go through that
 process before installing the selected kapps.
+
+Outputs of kapps saved in the registry by previous runs are reused instead of 
+running their output steps again. Pass '--refresh-outputs' to run them again, 
+e.g. if resources were changed outside of Sugarkube.
 `,
 		RunE: func(command *cobra.Command, args []string) error {
 			err := cmd.ValidateNumArgs(args, 3, usage)
@@ -131,6 +142,8 @@ process before installing the selected kapps.
 	//	"defined in a manifest(s)/stack config, even if they're already present/absent in the target cluster")
 	f.BoolVarP(&c.skipTemplating, "no-template", "t", false, "skip writing templates for kapps before installing them")
 	f.BoolVar(&c.noValidate, "no-validate", false, "don't validate kapps")
+	f.BoolVar(&c.watch, "watch", false, "after installing, watch the selected kapps for changes and reinstall the "+
+		"ones that change")
 	f.BoolVar(&c.runActions, "run-actions", false, "run pre- and post-actions in kapps")
 	f.BoolVar(&c.noActions, "no-actions", false, "don't run any pre- and post-actions in kapps")
 	f.BoolVar(&c.runPreActions, constants.RunPreActions, false, "run pre actions in kapps")
@@ -150,22 +163,41 @@ process before installing the selected kapps.
 			constants.WildcardCharacter))
 	f.Uint32Var(&c.onlineTimeout, "online-timeout", 600, "max number of seconds to wait for the cluster to come online")
 	f.Uint32Var(&c.readyTimeout, "ready-timeout", 600, "max number of seconds to wait for the cluster to become ready")
+	f.BoolVar(&c.waitForLock, "wait", false, "wait for the lock if another process is already working on this stack")
+	f.Uint32Var(&c.lockTimeout, "lock-timeout", 0, "max number of seconds to wait for the lock with --wait (0 waits forever)")
+	f.BoolVar(&c.refreshOutputs, "refresh-outputs", false, "run output steps again instead of reusing outputs saved in the registry by previous runs")
+	c.kappVarFlags.AddFlags(f)
 	return command
 }
 
 func (c *installCommand) run() error {
 
+	kappVarOverrides, err := c.kappVarFlags.Parse()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	// CLI overrides - will be merged with any loaded from a stack config file
 	cliStackConfig := &structs.StackFile{
-		Provider:    c.provider,
-		Provisioner: c.provisioner,
-		Profile:     c.profile,
-		Cluster:     c.cluster,
-		Region:      c.region,
-		Account:     c.account,
+		Provider:         c.provider,
+		Provisioner:      c.provisioner,
+		Profile:          c.profile,
+		Cluster:          c.cluster,
+		Region:           c.region,
+		Account:          c.account,
+		RefreshOutputs:   c.refreshOutputs,
+		KappVarOverrides: kappVarOverrides,
 	}
 
-	var err error
+	var runLock *lock.Lock
+	if !c.dryRun {
+		runLock, err = lock.Acquire(c.workspaceDir, c.stackName, c.waitForLock,
+			time.Duration(c.lockTimeout)*time.Second)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	defer func() { _ = runLock.Release() }()
 
 	stackObj, err = stack.BuildStack(c.stackName, c.stackFile, cliStackConfig)
 	if err != nil {
@@ -273,6 +305,20 @@ func (c *installCommand) run() error {
 		}
 	}
 
+	if c.watch {
+		return watchKapps(stackObj, dagObj, func(kappIds []string) error {
+			// rebuild the DAG so changes to the kapps' config files are picked up
+			dagObj, err := plan.BuildDagForSelected(stackObj, c.workspaceDir, kappIds, []string{}, false)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			return dagObj.Execute(constants.DagActionInstall, stackObj, shouldPlan, approved,
+				!(c.runPreActions || c.runActions), !(c.runPostActions || c.runActions),
+				false, c.dryRun)
+		})
+	}
+
 	return nil
 }
 
@@ -19,7 +19,9 @@ package kapps
 import (
 	"fmt"
 	"github.com/spf13/cobra"
+	"github.com/sugarkube/sugarkube/internal/pkg/installable"
 	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
+	"github.com/sugarkube/sugarkube/internal/pkg/lock"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
 	"os"
 	"os/signal"
@@ -42,6 +44,8 @@ func NewKappsCommands() *cobra.Command {
 			go func() {
 				<-signals
 				log.Logger.Info("Caught termination signal. Will try to gracefully terminate...")
+				lock.ReleaseAll()
+				installable.DeleteAllSensitiveTemplates()
 				if stackObj != nil {
 					err2 := stackObj.GetProvisioner().Close()
 					if err2 != nil {
@@ -63,6 +67,9 @@ func NewKappsCommands() *cobra.Command {
 		newVarsCommand(),
 		newValidateCommand(),
 		newGraphCommand(),
+		newRunCommand(),
+		newExecCommand(),
+		newShellCommand(),
 	)
 
 	command.Aliases = []string{"kapp"}
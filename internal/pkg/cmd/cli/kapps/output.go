@@ -22,10 +22,12 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
 	"github.com/sugarkube/sugarkube/internal/pkg/constants"
+	"github.com/sugarkube/sugarkube/internal/pkg/lock"
 	"github.com/sugarkube/sugarkube/internal/pkg/plan"
 	"github.com/sugarkube/sugarkube/internal/pkg/printer"
 	"github.com/sugarkube/sugarkube/internal/pkg/stack"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"time"
 )
 
 type outputCommand struct {
@@ -42,6 +44,10 @@ type outputCommand struct {
 	region          string
 	includeSelector []string
 	excludeSelector []string
+	waitForLock     bool
+	lockTimeout     uint32
+	refreshOutputs  bool
+	kappVarFlags    cmd.KappVarFlags
 }
 
 func newOutputCommand() *cobra.Command {
@@ -51,7 +57,11 @@ func newOutputCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   usage,
 		Short: fmt.Sprintf("Generate output for kapps"),
-		Long:  "Makes all selected kapps generate output.\n",
+		Long: `Makes all selected kapps generate output.
+
+Outputs of parents saved in the registry by previous runs are reused instead of 
+running their output steps again unless '--refresh-outputs' is passed.
+`,
 		RunE: func(command *cobra.Command, args []string) error {
 			err := cmd.ValidateNumArgs(args, 3, usage)
 			if err != nil {
@@ -80,22 +90,41 @@ func newOutputCommand() *cobra.Command {
 	f.StringArrayVarP(&c.excludeSelector, "exclude", "x", []string{},
 		fmt.Sprintf("exclude individual kapps (can specify multiple, formatted 'manifest-id:kapp-id' or 'manifest-id:%s' for all)",
 			constants.WildcardCharacter))
+	f.BoolVar(&c.waitForLock, "wait", false, "wait for the lock if another process is already working on this stack")
+	f.Uint32Var(&c.lockTimeout, "lock-timeout", 0, "max number of seconds to wait for the lock with --wait (0 waits forever)")
+	f.BoolVar(&c.refreshOutputs, "refresh-outputs", false, "run output steps again instead of reusing outputs saved in the registry by previous runs")
+	c.kappVarFlags.AddFlags(f)
 	return command
 }
 
 func (c *outputCommand) run() error {
 
+	kappVarOverrides, err := c.kappVarFlags.Parse()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	// CLI overrides - will be merged with any loaded from a stack config file
 	cliStackConfig := &structs.StackFile{
-		Provider:    c.provider,
-		Provisioner: c.provisioner,
-		Profile:     c.profile,
-		Cluster:     c.cluster,
-		Region:      c.region,
-		Account:     c.account,
+		Provider:         c.provider,
+		Provisioner:      c.provisioner,
+		Profile:          c.profile,
+		Cluster:          c.cluster,
+		Region:           c.region,
+		Account:          c.account,
+		RefreshOutputs:   c.refreshOutputs,
+		KappVarOverrides: kappVarOverrides,
 	}
 
-	var err error
+	var runLock *lock.Lock
+	if !c.dryRun {
+		runLock, err = lock.Acquire(c.workspaceDir, c.stackName, c.waitForLock,
+			time.Duration(c.lockTimeout)*time.Second)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	defer func() { _ = runLock.Release() }()
 
 	stackObj, err = stack.BuildStack(c.stackName, c.stackFile, cliStackConfig)
 	if err != nil {
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kapps
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
+	"github.com/sugarkube/sugarkube/internal/pkg/lock"
+	"github.com/sugarkube/sugarkube/internal/pkg/plan"
+	"github.com/sugarkube/sugarkube/internal/pkg/printer"
+	"github.com/sugarkube/sugarkube/internal/pkg/program"
+	"github.com/sugarkube/sugarkube/internal/pkg/stack"
+	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"time"
+)
+
+type runCommand struct {
+	workspaceDir   string
+	dryRun         bool
+	skipTemplating bool
+	ignoreErrors   bool
+	stackName      string
+	stackFile      string
+	provider       string
+	provisioner    string
+	profile        string
+	account        string
+	cluster        string
+	region         string
+	kappId         string
+	call           string
+	waitForLock    bool
+	lockTimeout    uint32
+	refreshOutputs bool
+	kappVarFlags   cmd.KappVarFlags
+}
+
+func newRunCommand() *cobra.Command {
+	c := &runCommand{}
+
+	usage := "run [flags] [stack-file] [stack-name] [workspace-dir] [manifest-id:kapp-id] [run-step]"
+	command := &cobra.Command{
+		Use:   usage,
+		Short: fmt.Sprintf("Run individual run steps for a kapp"),
+		Long: `Runs one or more run steps for a single kapp. Run steps are referred to in the same
+way as with the 'call' field of run steps, i.e. either by action to run all its 
+steps (e.g. 'output') or by action and step name (e.g. 'plan_install/helm-lint').
+The name of a run unit can be used instead of an action (e.g. 'terraform/tf-output').
+
+Outputs from the kapp's parents are loaded and its templates are rendered first, 
+so steps are run exactly as they would be during an install. E.g.:
+
+  sugarkube kapps run stacks.yaml dev1 workspaces/dev1 infra:vpc output/tf-output
+`,
+		RunE: func(command *cobra.Command, args []string) error {
+			err := cmd.ValidateNumArgs(args, 5, usage)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			c.stackFile = args[0]
+			c.stackName = args[1]
+			c.workspaceDir = args[2]
+			c.kappId = args[3]
+			c.call = args[4]
+
+			err1 := c.run()
+			// shutdown any SSH port forwarding then return the error
+			if stackObj != nil {
+				err2 := stackObj.GetProvisioner().Close()
+				if err2 != nil {
+					return errors.WithStack(err2)
+				}
+			}
+
+			if err1 != nil {
+				if _, silent := errors.Cause(err1).(program.SilentError); !silent {
+					_, _ = printer.Fprint("\n[red][bold]Error running kapp. Aborting.\n")
+				}
+				return errors.WithStack(err1)
+			}
+
+			return nil
+		},
+	}
+
+	f := command.Flags()
+	f.BoolVarP(&c.dryRun, "dry-run", "n", false, "show what would happen but don't run anything")
+	f.BoolVarP(&c.skipTemplating, "no-template", "t", false, "skip writing templates for the kapp before running it")
+	f.BoolVar(&c.ignoreErrors, "ignore-errors", false, "ignore errors running steps")
+	f.StringVar(&c.provider, "provider", "", "name of provider, e.g. aws, local, etc.")
+	f.StringVar(&c.provisioner, "provisioner", "", "name of provisioner, e.g. kops, minikube, etc.")
+	f.StringVar(&c.profile, "profile", "", "launch profile, e.g. dev, test, prod, etc.")
+	f.StringVarP(&c.cluster, "cluster", "c", "", "name of cluster to launch, e.g. dev1, dev2, etc.")
+	f.StringVarP(&c.account, "account", "a", "", "string identifier for the account to launch in (for providers that support it)")
+	f.StringVarP(&c.region, "region", "r", "", "name of region (for providers that support it)")
+	f.BoolVar(&c.waitForLock, "wait", false, "wait for the lock if another process is already working on this stack")
+	f.Uint32Var(&c.lockTimeout, "lock-timeout", 0, "max number of seconds to wait for the lock with --wait (0 waits forever)")
+	f.BoolVar(&c.refreshOutputs, "refresh-outputs", false, "run output steps again instead of reusing outputs saved in the registry by previous runs")
+	c.kappVarFlags.AddFlags(f)
+	return command
+}
+
+func (c *runCommand) run() error {
+
+	kappVarOverrides, err := c.kappVarFlags.Parse()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// CLI overrides - will be merged with any loaded from a stack config file
+	cliStackConfig := &structs.StackFile{
+		Provider:         c.provider,
+		Provisioner:      c.provisioner,
+		Profile:          c.profile,
+		Cluster:          c.cluster,
+		Region:           c.region,
+		Account:          c.account,
+		RefreshOutputs:   c.refreshOutputs,
+		KappVarOverrides: kappVarOverrides,
+	}
+
+	var runLock *lock.Lock
+	if !c.dryRun {
+		runLock, err = lock.Acquire(c.workspaceDir, c.stackName, c.waitForLock,
+			time.Duration(c.lockTimeout)*time.Second)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	defer func() { _ = runLock.Release() }()
+
+	stackObj, err = stack.BuildStack(c.stackName, c.stackFile, cliStackConfig)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	dryRunPrefix := ""
+	if c.dryRun {
+		dryRunPrefix = "[Dry run] "
+	}
+
+	dagObj, err := plan.BuildDagForSelected(stackObj, c.workspaceDir, []string{c.kappId}, []string{}, false)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = printer.Fprintln("\n[yellow]Loading kapp outputs...")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	installableObj, err := dagObj.LoadInstallable(stackObj, c.kappId, c.dryRun)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = printer.Fprintln("")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = plan.ExecuteCall(installableObj, stackObj, c.call, c.skipTemplating, c.ignoreErrors, c.dryRun)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = printer.Fprintf("\n%s[green]Successfully ran '[bold]%s[reset][green]' for kapp '[bold]%s[reset][green]'\n",
+		dryRunPrefix, c.call, c.kappId)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kapps
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
+	"os"
+)
+
+// used if $SHELL isn't set
+const defaultShell = "/bin/sh"
+
+func newShellCommand() *cobra.Command {
+	c := &execCommand{}
+
+	usage := "shell [flags] [stack-file] [stack-name] [workspace-dir] [manifest-id:kapp-id]"
+	command := &cobra.Command{
+		Use:   usage,
+		Short: fmt.Sprintf("Open a shell in a kapp's environment"),
+		Long: `Opens an interactive shell (from $SHELL) with the working directory and env vars 
+(e.g. KUBECONFIG) that the kapp's run units would be executed with. See 'kapps exec' 
+for details.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			err := cmd.ValidateNumArgs(args, 4, usage)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			c.stackFile = args[0]
+			c.stackName = args[1]
+			c.workspaceDir = args[2]
+			c.kappId = args[3]
+
+			shell, ok := os.LookupEnv("SHELL")
+			if !ok || shell == "" {
+				shell = defaultShell
+			}
+			c.command = []string{shell}
+
+			return c.run()
+		},
+	}
+
+	c.addFlags(command.Flags())
+	return command
+}
@@ -22,10 +22,12 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
 	"github.com/sugarkube/sugarkube/internal/pkg/constants"
+	"github.com/sugarkube/sugarkube/internal/pkg/lock"
 	"github.com/sugarkube/sugarkube/internal/pkg/plan"
 	"github.com/sugarkube/sugarkube/internal/pkg/printer"
 	"github.com/sugarkube/sugarkube/internal/pkg/stack"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"time"
 )
 
 type templateConfig struct {
@@ -43,6 +45,11 @@ type templateConfig struct {
 	region          string
 	includeSelector []string
 	excludeSelector []string
+	waitForLock     bool
+	lockTimeout     uint32
+	watch           bool
+	refreshOutputs  bool
+	kappVarFlags    cmd.KappVarFlags
 }
 
 func newTemplateCommand() *cobra.Command {
@@ -72,6 +79,8 @@ configured for the region the target cluster is in, generating Helm
 	f.BoolVarP(&c.dryRun, "dry-run", "n", false, "show what would happen but don't create a cluster")
 	f.BoolVar(&c.includeParents, "parents", false, "process all parents of all selected kapps as well")
 	f.BoolVar(&c.ignoreErrors, "ignore-errors", false, "ignore errors templating kapps")
+	f.BoolVar(&c.watch, "watch", false, "after rendering templates, watch the selected kapps for changes and "+
+		"rerender the ones that change")
 	f.StringVar(&c.provider, "provider", "", "name of provider, e.g. aws, local, etc.")
 	f.StringVar(&c.provisioner, "provisioner", "", "name of provisioner, e.g. kops, minikube, etc.")
 	f.StringVar(&c.profile, "profile", "", "launch profile, e.g. dev, test, prod, etc.")
@@ -84,20 +93,41 @@ configured for the region the target cluster is in, generating Helm
 	f.StringArrayVarP(&c.excludeSelector, "exclude", "x", []string{},
 		fmt.Sprintf("exclude individual kapps (can specify multiple, formatted manifest-id:kapp-id or 'manifest-id:%s' for all)",
 			constants.WildcardCharacter))
+	f.BoolVar(&c.waitForLock, "wait", false, "wait for the lock if another process is already working on this stack")
+	f.Uint32Var(&c.lockTimeout, "lock-timeout", 0, "max number of seconds to wait for the lock with --wait (0 waits forever)")
+	f.BoolVar(&c.refreshOutputs, "refresh-outputs", false, "run output steps again instead of reusing outputs saved in the registry by previous runs")
+	c.kappVarFlags.AddFlags(f)
 	return command
 }
 
 func (c *templateConfig) run() error {
 
+	kappVarOverrides, err := c.kappVarFlags.Parse()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	// CLI overrides - will be merged with any loaded from a stack config file
 	cliStackConfig := &structs.StackFile{
-		Provider:    c.provider,
-		Provisioner: c.provisioner,
-		Profile:     c.profile,
-		Cluster:     c.cluster,
-		Region:      c.region,
-		Account:     c.account,
+		Provider:         c.provider,
+		Provisioner:      c.provisioner,
+		Profile:          c.profile,
+		Cluster:          c.cluster,
+		Region:           c.region,
+		Account:          c.account,
+		RefreshOutputs:   c.refreshOutputs,
+		KappVarOverrides: kappVarOverrides,
+	}
+
+	var runLock *lock.Lock
+	if !c.dryRun {
+		runLock, err = lock.Acquire(c.workspaceDir, c.stackName, c.waitForLock,
+			time.Duration(c.lockTimeout)*time.Second)
+		if err != nil {
+			return errors.WithStack(err)
+		}
 	}
+	defer func() { _ = runLock.Release() }()
 
 	stackObj, err := stack.BuildStack(c.stackName, c.stackFile, cliStackConfig)
 	if err != nil {
@@ -126,5 +156,28 @@ func (c *templateConfig) run() error {
 		return errors.WithStack(err)
 	}
 
+	if c.watch {
+		return watchKapps(stackObj, dagObj, func(kappIds []string) error {
+			// rebuild the DAG so changes to the kapps' config files are picked up
+			dagObj, err := plan.BuildDagForSelected(stackObj, c.workspaceDir, kappIds, []string{}, false)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			err = dagObj.Execute(constants.DagActionTemplate, stackObj, false, true, true,
+				true, c.ignoreErrors, c.dryRun)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			_, err = printer.Fprintln("[green]Templates successfully rendered")
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			return nil
+		})
+	}
+
 	return nil
 }
@@ -32,6 +32,7 @@ type validateConfig struct {
 	region          string
 	includeSelector []string
 	excludeSelector []string
+	kappVarFlags    cmd.KappVarFlags
 }
 
 func newValidateCommand() *cobra.Command {
@@ -41,7 +42,8 @@ func newValidateCommand() *cobra.Command {
 	command := &cobra.Command{
 		Use:   usage,
 		Short: fmt.Sprintf("Validate you have all the required binaries required by each kapp"),
-		Long:  `Loads all kapps and makes sure the binaries they declare in their 'requires' blocks are in your path`,
+		Long: `Loads all kapps and makes sure the binaries they declare in their 'requires' blocks are in your path
+and that their vars conform to their 'vars_schema' (if they have one)`,
 		RunE: func(command *cobra.Command, args []string) error {
 			err := cmd.ValidateNumArgs(args, 3, usage)
 			if err != nil {
@@ -67,19 +69,26 @@ func newValidateCommand() *cobra.Command {
 	f.StringArrayVarP(&c.excludeSelector, "exclude", "x", []string{},
 		fmt.Sprintf("exclude individual kapps (can specify multiple, formatted manifest-id:kapp-id or 'manifest-id:%s' for all)",
 			constants.WildcardCharacter))
+	c.kappVarFlags.AddFlags(f)
 	return command
 }
 
 func (c *validateConfig) run() error {
 
+	kappVarOverrides, err := c.kappVarFlags.Parse()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	// CLI overrides - will be merged with any loaded from a stack config file
 	cliStackConfig := &structs.StackFile{
-		Provider:    c.provider,
-		Provisioner: c.provisioner,
-		Profile:     c.profile,
-		Cluster:     c.cluster,
-		Region:      c.region,
-		Account:     c.account,
+		Provider:         c.provider,
+		Provisioner:      c.provisioner,
+		Profile:          c.profile,
+		Cluster:          c.cluster,
+		Region:           c.region,
+		Account:          c.account,
+		KappVarOverrides: kappVarOverrides,
 	}
 
 	stackObj, err := stack.BuildStack(c.stackName, c.stackFile, cliStackConfig)
@@ -108,6 +117,7 @@ func (c *validateConfig) run() error {
 // Validates kapps and that the provisioner binary exists
 func Validate(stackObj interfaces.IStack, dagObj *plan.Dag) error {
 	numMissing := 0
+	numInvalid := 0
 	commandsSeen := make([]string, 0)
 
 	_, err := printer.Fprintf("[yellow]Validating kapps & provisioner...[default]\n")
@@ -136,6 +146,17 @@ func Validate(stackObj interfaces.IStack, dagObj *plan.Dag) error {
 		if err != nil {
 			return errors.WithStack(err)
 		}
+
+		// make sure the kapp's vars conform to its vars schema
+		err = stackObj.ValidateVars(installable, nil)
+		if err != nil {
+			numInvalid++
+			log.Logger.Errorf("Invalid vars for kapp '%s': %v", installable.FullyQualifiedId(), err)
+			_, err = printer.Fprintf("  [red][bold]Invalid vars![reset][red] %s\n", err.Error())
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
 	}
 
 	// validate the provisioner binary if it's set (the `none` provisioner doesn't have one)
@@ -147,10 +168,19 @@ func Validate(stackObj interfaces.IStack, dagObj *plan.Dag) error {
 		}
 	}
 
-	if numMissing > 0 {
-		_, err := printer.Fprintf("\n[red]%d requirement(s) missing!\n", numMissing)
-		if err != nil {
-			return errors.WithStack(err)
+	if numMissing > 0 || numInvalid > 0 {
+		if numMissing > 0 {
+			_, err := printer.Fprintf("\n[red]%d requirement(s) missing!\n", numMissing)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
+		if numInvalid > 0 {
+			_, err := printer.Fprintf("\n[red]%d kapp(s) have invalid vars!\n", numInvalid)
+			if err != nil {
+				return errors.WithStack(err)
+			}
 		}
 
 		return program.SilentError{}
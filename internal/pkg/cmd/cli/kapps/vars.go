@@ -24,9 +24,13 @@ type varsConfig struct {
 	region          string
 	includeParents  bool
 	noOutputs       bool
+	explain         bool
 	includeSelector []string
 	excludeSelector []string
 	suppress        []string
+	showSecrets     bool
+	refreshOutputs  bool
+	kappVarFlags    cmd.KappVarFlags
 }
 
 func newVarsCommand() *cobra.Command {
@@ -53,6 +57,8 @@ templated sugarkube.yaml file.`,
 	f := command.Flags()
 	f.BoolVar(&c.includeParents, "parents", false, "process all parents of all selected kapps as well")
 	f.BoolVar(&c.noOutputs, "no-outputs", false, "don't load outputs from parents")
+	f.BoolVar(&c.explain, "explain", false, "show where each variable came from and its value before templating")
+	f.BoolVar(&c.showSecrets, "show-secrets", false, "show the values of secrets (e.g. from SOPS-encrypted vars files) instead of masking them")
 	f.StringVar(&c.provider, "provider", "", "name of provider, e.g. aws, local, etc.")
 	f.StringVar(&c.provisioner, "provisioner", "", "name of provisioner, e.g. kops, minikube, etc.")
 	f.StringVar(&c.profile, "profile", "", "launch profile, e.g. dev, test, prod, etc.")
@@ -67,19 +73,28 @@ templated sugarkube.yaml file.`,
 			constants.WildcardCharacter))
 	f.StringArrayVarP(&c.suppress, "suppress", "s", []string{},
 		"paths to variables to suppress from the output to simplify it (e.g. 'provision.specs')")
+	f.BoolVar(&c.refreshOutputs, "refresh-outputs", false, "run output steps again instead of reusing outputs saved in the registry by previous runs")
+	c.kappVarFlags.AddFlags(f)
 	return command
 }
 
 func (c *varsConfig) run() error {
 
+	kappVarOverrides, err := c.kappVarFlags.Parse()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	// CLI overrides - will be merged with any loaded from a stack config file
 	cliStackConfig := &structs.StackFile{
-		Provider:    c.provider,
-		Provisioner: c.provisioner,
-		Profile:     c.profile,
-		Cluster:     c.cluster,
-		Region:      c.region,
-		Account:     c.account,
+		Provider:         c.provider,
+		Provisioner:      c.provisioner,
+		Profile:          c.profile,
+		Cluster:          c.cluster,
+		Region:           c.region,
+		Account:          c.account,
+		RefreshOutputs:   c.refreshOutputs,
+		KappVarOverrides: kappVarOverrides,
 	}
 
 	stackObj, err := stack.BuildStack(c.stackName, c.stackFile, cliStackConfig)
@@ -97,7 +112,8 @@ func (c *varsConfig) run() error {
 		return errors.WithStack(err)
 	}
 
-	err = dagObj.ExecuteGetVars(constants.DagActionVars, stackObj, !c.noOutputs, c.suppress)
+	err = dagObj.ExecuteGetVars(constants.DagActionVars, stackObj, !c.noOutputs, c.suppress, c.explain,
+		c.showSecrets)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -0,0 +1,99 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kapps
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
+	"github.com/sugarkube/sugarkube/internal/pkg/plan"
+	"github.com/sugarkube/sugarkube/internal/pkg/printer"
+	"github.com/sugarkube/sugarkube/internal/pkg/watcher"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const watchInterval = time.Second
+
+// how long to wait after the last change before rerunning kapps, so saving several files at once only
+// triggers a single run
+const watchDebounce = 2 * time.Second
+
+// Watches the cache and config file directories of the marked kapps in the DAG plus the stack's kapp vars
+// and template directories. When anything changes `rerun` is called with the fully-qualified IDs of the
+// affected kapps. Blocks until the process is terminated.
+func watchKapps(stackObj interfaces.IStack, dagObj *plan.Dag, rerun func(kappIds []string) error) error {
+	watcherObj := watcher.New(watchInterval, watchDebounce)
+
+	allIds := make([]string, 0)
+
+	for _, installableObj := range dagObj.GetInstallables() {
+		kappId := installableObj.FullyQualifiedId()
+		allIds = append(allIds, kappId)
+
+		for _, dir := range []string{installableObj.GetCacheDir(), installableObj.GetConfigFileDir()} {
+			if dir == "" {
+				continue
+			}
+
+			err := watcherObj.Add(dir, kappId)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+
+	// vars and template files may apply to any kapp so changes to them rerun all kapps
+	stackConfig := stackObj.GetConfig()
+	sharedDirs := make([]string, 0)
+	sharedDirs = append(sharedDirs, stackConfig.KappVarsDirs()...)
+	sharedDirs = append(sharedDirs, stackConfig.TemplateDirs()...)
+	for _, dir := range sharedDirs {
+		err := watcherObj.Add(filepath.Join(stackConfig.GetDir(), dir), allIds...)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	_, err := printer.Fprintf("\n[yellow]Watching %d kapp(s) for changes. Press Ctrl-C to stop.\n", len(allIds))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return watcherObj.Watch(nil, func(kappIds []string) error {
+		_, err := printer.Fprintf("\n[yellow]Detected changes affecting: [bold]%s\n\n", strings.Join(kappIds, ", "))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		err = rerun(kappIds)
+		if err != nil {
+			// keep watching. Users will probably fix the problem and save again
+			_, err = printer.Fprintf("[red]Error rerunning kapps: %v\n", err)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
+		_, err = printer.Fprintf("\n[yellow]Watching for changes...\n")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		return nil
+	})
+}
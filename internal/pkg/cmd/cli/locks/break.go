@@ -0,0 +1,78 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package locks
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
+	"github.com/sugarkube/sugarkube/internal/pkg/lock"
+	"github.com/sugarkube/sugarkube/internal/pkg/printer"
+)
+
+type breakCommand struct {
+	stackName string
+	dir       string
+}
+
+func newBreakCommand() *cobra.Command {
+	c := &breakCommand{}
+
+	usage := "break [flags] [stack-name] [workspace-dir]"
+	command := &cobra.Command{
+		Use:   usage,
+		Short: fmt.Sprintf("Forcibly remove the lock on a stack"),
+		Long: `Removes the lock on a stack, releasing any configured remote lock too. Only do 
+this if you're sure the process holding the lock has died, otherwise you risk 
+corrupting the workspace or cluster.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			err := cmd.ValidateNumArgs(args, 2, usage)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			c.stackName = args[0]
+			c.dir = args[1]
+			return c.run()
+		},
+	}
+
+	return command
+}
+
+func (c *breakCommand) run() error {
+	holder, err := lock.Break(c.dir, c.stackName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if holder == nil {
+		_, err = printer.Fprintf("Stack '[bold]%s[reset]' wasn't locked\n", c.stackName)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+
+	_, err = printer.Fprintf("[yellow]Broke lock on stack '[bold]%s[reset][yellow]' held by %s\n",
+		c.stackName, holder)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
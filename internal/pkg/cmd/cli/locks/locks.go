@@ -0,0 +1,44 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package locks
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+func NewLockCommands() *cobra.Command {
+
+	command := &cobra.Command{
+		Use:   "lock [command]",
+		Short: fmt.Sprintf("Work with stack locks"),
+		Long: `Inspect and break the locks sugarkube takes while running commands that 
+change a workspace or cluster.
+
+Locks are stored in the workspace directory for 'kapps' and 'workspace' commands,
+and in the directory containing the stack file for 'cluster' commands.`,
+	}
+
+	command.AddCommand(
+		newStatusCommand(),
+		newBreakCommand(),
+	)
+
+	command.Aliases = []string{"locks"}
+
+	return command
+}
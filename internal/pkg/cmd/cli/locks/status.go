@@ -0,0 +1,83 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package locks
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
+	"github.com/sugarkube/sugarkube/internal/pkg/lock"
+	"github.com/sugarkube/sugarkube/internal/pkg/printer"
+)
+
+type statusCommand struct {
+	stackName string
+	dir       string
+}
+
+func newStatusCommand() *cobra.Command {
+	c := &statusCommand{}
+
+	usage := "status [flags] [stack-name] [workspace-dir]"
+	command := &cobra.Command{
+		Use:   usage,
+		Short: fmt.Sprintf("Show who holds the lock on a stack"),
+		Long: `Shows which user, host and process holds the lock on a stack, and the command 
+being run. For locks taken by 'cluster' commands, pass the directory containing 
+the stack file instead of a workspace directory.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			err := cmd.ValidateNumArgs(args, 2, usage)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			c.stackName = args[0]
+			c.dir = args[1]
+			return c.run()
+		},
+	}
+
+	return command
+}
+
+func (c *statusCommand) run() error {
+	holder, err := lock.Status(c.dir, c.stackName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if holder == nil {
+		_, err = printer.Fprintf("[green]Stack '[bold]%s[reset][green]' isn't locked\n", c.stackName)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+
+	_, err = printer.Fprintf("[yellow]Stack '[bold]%s[reset][yellow]' is locked:\n", c.stackName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = printer.Fprintf("  User:     %s\n  Host:     %s\n  PID:      %d\n  Command:  %s\n"+
+		"  Acquired: %s\n", holder.User, holder.Host, holder.Pid, holder.Command, holder.Acquired)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package registries
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
+	"github.com/sugarkube/sugarkube/internal/pkg/lock"
+	"github.com/sugarkube/sugarkube/internal/pkg/printer"
+	"github.com/sugarkube/sugarkube/internal/pkg/program"
+)
+
+type deleteCommand struct {
+	stackOptions
+	key string
+}
+
+func newDeleteCommand() *cobra.Command {
+	c := &deleteCommand{}
+
+	usage := "delete [flags] [stack-file] [stack-name] [workspace-dir] [key]"
+	command := &cobra.Command{
+		Use:   usage,
+		Short: fmt.Sprintf("Delete a value from the registry"),
+		Long: `Deletes a key and everything under it from a stack's registry. Delete a kapp's 
+outputs (e.g. 'outputs.manifest__kapp') to make sugarkube run its output steps 
+again on the next run.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			err := cmd.ValidateNumArgs(args, 4, usage)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			c.parseArgs(args)
+			c.key = args[3]
+			return c.run()
+		},
+	}
+
+	c.addFlags(command.Flags())
+	return command
+}
+
+func (c *deleteCommand) run() error {
+	runLock, err := lock.Acquire(c.workspaceDir, c.stackName, false, 0)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = runLock.Release() }()
+
+	stackObj, err := c.loadStack()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	registryObj := stackObj.GetRegistry()
+
+	if _, ok := registryObj.Get(c.key); !ok {
+		return program.SimpleError{Message: fmt.Sprintf("Key '%s' isn't in the registry", c.key)}
+	}
+
+	registryObj.Delete(c.key)
+
+	err = stackObj.SaveRegistry()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = printer.Fprintf("[green]Deleted '[bold]%s[reset][green]' from the registry\n", c.key)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
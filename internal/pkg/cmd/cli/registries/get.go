@@ -0,0 +1,126 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package registries
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
+	"github.com/sugarkube/sugarkube/internal/pkg/printer"
+	"github.com/sugarkube/sugarkube/internal/pkg/program"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
+	"github.com/sugarkube/sugarkube/internal/pkg/registry"
+	"io"
+	"os"
+	"time"
+)
+
+type getCommand struct {
+	stackOptions
+	key         string
+	format      string
+	showSecrets bool
+}
+
+func newGetCommand() *cobra.Command {
+	c := &getCommand{}
+
+	usage := "get [flags] [stack-file] [stack-name] [workspace-dir] [key]"
+	command := &cobra.Command{
+		Use:   usage,
+		Short: fmt.Sprintf("Print a value from the registry"),
+		Long: `Prints the value of a key in a stack's registry along with the kapp that set it
+and when. Sensitive values are masked unless '--show-secrets' is passed.
+
+Example:
+
+  sugarkube registry get stacks.yaml dev1 workspaces/dev1 outputs.prelaunch__terraform_bucket`,
+		RunE: func(command *cobra.Command, args []string) error {
+			err := cmd.ValidateNumArgs(args, 4, usage)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			c.parseArgs(args)
+			c.key = args[3]
+			return c.run(command.OutOrStdout())
+		},
+	}
+
+	f := command.Flags()
+	c.addFlags(f)
+	f.StringVarP(&c.format, "format", "f", formatYaml,
+		fmt.Sprintf("output format, either '%s' or '%s'", formatYaml, formatJson))
+	f.BoolVar(&c.showSecrets, "show-secrets", false, "show sensitive values instead of masking them")
+	return command
+}
+
+func (c *getCommand) run(out io.Writer) error {
+	err := validateFormat(c.format, formatYaml, formatJson)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// send progress messages to stderr so only the value is written to stdout
+	previousOutput := printer.SetOutput(os.Stderr)
+	defer printer.SetOutput(previousOutput)
+
+	stackObj, err := c.loadStack()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	registryObj := stackObj.GetRegistry()
+
+	value, ok := registryObj.Get(c.key)
+	if !ok {
+		return program.SimpleError{Message: fmt.Sprintf("Key '%s' isn't in the registry", c.key)}
+	}
+
+	for _, entry := range registry.Entries(registryObj) {
+		if registry.HasPrefix(c.key, entry.Key) {
+			_, err = printer.Fprintf("%s\n", describeSource(entry))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+
+	if !c.showSecrets {
+		value = redact.Value(value)
+	}
+
+	serialised, err := marshal(value, c.format)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = fmt.Fprintln(out, serialised)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// Describes what set a registry entry and when
+func describeSource(entry registry.Entry) string {
+	if entry.Kapp == "" {
+		return fmt.Sprintf("'%s' was set at %s", entry.Key, entry.Time.Format(time.RFC3339))
+	}
+
+	return fmt.Sprintf("'%s' was set by kapp '%s' at %s", entry.Key, entry.Kapp,
+		entry.Time.Format(time.RFC3339))
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package registries
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
+	"github.com/sugarkube/sugarkube/internal/pkg/printer"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
+	"github.com/sugarkube/sugarkube/internal/pkg/registry"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+type listCommand struct {
+	stackOptions
+	prefix      string
+	format      string
+	showSecrets bool
+}
+
+func newListCommand() *cobra.Command {
+	c := &listCommand{}
+
+	usage := "list [flags] [stack-file] [stack-name] [workspace-dir]"
+	command := &cobra.Command{
+		Use:   usage,
+		Short: fmt.Sprintf("List the values in the registry"),
+		Long: `Lists the keys values were set with in a stack's registry, which kapp set them 
+and when. Pass '--format json' or '--format yaml' to include values. Sensitive 
+values are masked unless '--show-secrets' is passed.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			err := cmd.ValidateNumArgs(args, 3, usage)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			c.parseArgs(args)
+			return c.run(command.OutOrStdout())
+		},
+	}
+
+	f := command.Flags()
+	c.addFlags(f)
+	f.StringVar(&c.prefix, "prefix", "", "only list keys under this key, e.g. 'outputs.manifest__kapp'")
+	f.StringVarP(&c.format, "format", "f", formatTable,
+		fmt.Sprintf("output format, either '%s', '%s' or '%s'", formatTable, formatYaml, formatJson))
+	f.BoolVar(&c.showSecrets, "show-secrets", false, "show sensitive values instead of masking them")
+	return command
+}
+
+func (c *listCommand) run(out io.Writer) error {
+	err := validateFormat(c.format, formatTable, formatYaml, formatJson)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// send progress messages to stderr so only entries are written to stdout
+	previousOutput := printer.SetOutput(os.Stderr)
+	defer printer.SetOutput(previousOutput)
+
+	stackObj, err := c.loadStack()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	registryObj := stackObj.GetRegistry()
+
+	entries := make([]registry.Entry, 0)
+	for _, entry := range registry.Entries(registryObj) {
+		if !registry.HasPrefix(entry.Key, c.prefix) {
+			continue
+		}
+
+		if !c.showSecrets {
+			entry.Value = redact.Value(entry.Value)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if c.format != formatTable {
+		serialised, err := marshal(entries, c.format)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		_, err = fmt.Fprintln(out, serialised)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, err = fmt.Fprintln(writer, "KEY\tKAPP\tSET AT")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, entry := range entries {
+		kapp := entry.Kapp
+		if kapp == "" {
+			kapp = "-"
+		}
+
+		_, err = fmt.Fprintf(writer, "%s\t%s\t%s\n", entry.Key, kapp, entry.Time.Format(time.RFC3339))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return writer.Flush()
+}
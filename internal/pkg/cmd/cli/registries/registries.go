@@ -0,0 +1,169 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package registries
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
+	"github.com/sugarkube/sugarkube/internal/pkg/constants"
+	"github.com/sugarkube/sugarkube/internal/pkg/convert"
+	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
+	"github.com/sugarkube/sugarkube/internal/pkg/program"
+	"github.com/sugarkube/sugarkube/internal/pkg/registry"
+	"github.com/sugarkube/sugarkube/internal/pkg/stack"
+	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"gopkg.in/yaml.v2"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const formatTable = "table"
+const formatJson = "json"
+const formatYaml = "yaml"
+
+func NewRegistryCommands() *cobra.Command {
+
+	command := &cobra.Command{
+		Use:   "registry [command]",
+		Short: fmt.Sprintf("Work with the registry of kapp outputs"),
+		Long: fmt.Sprintf(`Inspect and change a stack's registry. The registry holds the outputs of kapps 
+(under '%s'), the path to the kubeconfig file and other values set while 
+running commands. It's saved in the workspace for each stack and cluster so 
+outputs don't need to be generated again on later runs. Keys are paths separated 
+by '%s', e.g. '%s.manifest__kapp.output'.
+
+Set 'registry.persist: false' in the sugarkube config file to disable saving the 
+registry, or 'registry.encrypt: true' to encrypt it with the passphrase in 
+the %s environment variable.`, constants.RegistryKeyOutputs,
+			constants.RegistryFieldSeparator, constants.RegistryKeyOutputs, registry.PassphraseEnvVar),
+	}
+
+	command.AddCommand(
+		newGetCommand(),
+		newListCommand(),
+		newSetCommand(),
+		newDeleteCommand(),
+	)
+
+	command.Aliases = []string{"registries"}
+
+	return command
+}
+
+// Options for loading the stack whose registry to work with
+type stackOptions struct {
+	stackFile    string
+	stackName    string
+	workspaceDir string
+	provider     string
+	provisioner  string
+	profile      string
+	account      string
+	cluster      string
+	region       string
+}
+
+func (o *stackOptions) addFlags(f *pflag.FlagSet) {
+	f.StringVar(&o.provider, "provider", "", "name of provider, e.g. aws, local, etc.")
+	f.StringVar(&o.provisioner, "provisioner", "", "name of provisioner, e.g. kops, minikube, etc.")
+	f.StringVar(&o.profile, "profile", "", "launch profile, e.g. dev, test, prod, etc.")
+	f.StringVarP(&o.cluster, "cluster", "c", "", "name of cluster to launch, e.g. dev1, dev2, etc.")
+	f.StringVarP(&o.account, "account", "a", "", "string identifier for the account to launch in (for providers that support it)")
+	f.StringVarP(&o.region, "region", "r", "", "name of region (for providers that support it)")
+}
+
+// Sets the stack file, stack name and workspace dir from the first 3 args
+func (o *stackOptions) parseArgs(args []string) {
+	o.stackFile = args[0]
+	o.stackName = args[1]
+	o.workspaceDir = args[2]
+}
+
+// Loads the stack and the registry persisted for it in the workspace. Changes to the stack's
+// registry are saved to the workspace by calling SaveRegistry.
+func (o *stackOptions) loadStack() (interfaces.IStack, error) {
+	if !config.CurrentConfig.Registry.Persist {
+		return nil, program.SimpleError{Message: "The registry isn't saved because " +
+			"'registry.persist' is false in the sugarkube config file"}
+	}
+
+	// CLI args override configured args, so merge them in
+	cliStackConfig := &structs.StackFile{
+		Provider:    o.provider,
+		Provisioner: o.provisioner,
+		Profile:     o.profile,
+		Cluster:     o.cluster,
+		Region:      o.region,
+		Account:     o.account,
+	}
+
+	stackObj, err := stack.BuildStack(o.stackName, o.stackFile, cliStackConfig)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	absWorkspaceDir, err := filepath.Abs(o.workspaceDir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if _, err := os.Stat(absWorkspaceDir); err != nil {
+		return nil, program.SimpleError{Message: fmt.Sprintf("Workspace dir '%s' doesn't exist",
+			absWorkspaceDir)}
+	}
+
+	err = stackObj.PersistRegistry(absWorkspaceDir)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return stackObj, nil
+}
+
+// Returns an error if the format isn't one of those given
+func validateFormat(format string, allowed ...string) error {
+	for _, candidate := range allowed {
+		if format == candidate {
+			return nil
+		}
+	}
+
+	return program.SimpleError{Message: fmt.Sprintf("Invalid format '%s'. Must be one of: %s",
+		format, strings.Join(allowed, ", "))}
+}
+
+// Serialises a value as JSON or YAML
+func marshal(value interface{}, format string) (string, error) {
+	if format == formatJson {
+		data, err := json.MarshalIndent(convert.StringifyKeys(value), "", "  ")
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		return string(data), nil
+	}
+
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
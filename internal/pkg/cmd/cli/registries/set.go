@@ -0,0 +1,98 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package registries
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
+	"github.com/sugarkube/sugarkube/internal/pkg/lock"
+	"github.com/sugarkube/sugarkube/internal/pkg/printer"
+	"gopkg.in/yaml.v2"
+)
+
+type setCommand struct {
+	stackOptions
+	key   string
+	value string
+}
+
+func newSetCommand() *cobra.Command {
+	c := &setCommand{}
+
+	usage := "set [flags] [stack-file] [stack-name] [workspace-dir] [key] [value]"
+	command := &cobra.Command{
+		Use:   usage,
+		Short: fmt.Sprintf("Set a value in the registry"),
+		Long: `Sets the value of a key in a stack's registry. The value is parsed as YAML so 
+maps and lists can be set. Outputs set this way are used instead of running a 
+kapp's output steps until the kapp is installed again.
+
+Example:
+
+  sugarkube registry set stacks.yaml dev1 workspaces/dev1 outputs.prelaunch__terraform_bucket.name my-bucket`,
+		RunE: func(command *cobra.Command, args []string) error {
+			err := cmd.ValidateNumArgs(args, 5, usage)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			c.parseArgs(args)
+			c.key = args[3]
+			c.value = args[4]
+			return c.run()
+		},
+	}
+
+	c.addFlags(command.Flags())
+	return command
+}
+
+func (c *setCommand) run() error {
+	var value interface{}
+	err := yaml.Unmarshal([]byte(c.value), &value)
+	if err != nil {
+		return errors.Wrapf(err, "Error parsing value '%s' as YAML", c.value)
+	}
+
+	runLock, err := lock.Acquire(c.workspaceDir, c.stackName, false, 0)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer func() { _ = runLock.Release() }()
+
+	stackObj, err := c.loadStack()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = stackObj.GetRegistry().Set(c.key, value)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = stackObj.SaveRegistry()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = printer.Fprintf("[green]Set '[bold]%s[reset][green]' in the registry\n", c.key)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
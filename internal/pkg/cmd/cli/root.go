@@ -24,6 +24,9 @@ import (
 	"github.com/spf13/viper"
 	"github.com/sugarkube/sugarkube/internal/pkg/cmd/cli/cluster"
 	"github.com/sugarkube/sugarkube/internal/pkg/cmd/cli/kapps"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd/cli/locks"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd/cli/registries"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd/cli/secrets"
 	"github.com/sugarkube/sugarkube/internal/pkg/cmd/cli/workspace"
 	"github.com/sugarkube/sugarkube/internal/pkg/config"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
@@ -109,6 +112,9 @@ func NewCommand(name string) *cobra.Command {
 		cluster.NewClusterCommands(),
 		kapps.NewKappsCommands(),
 		workspace.NewWorkspaceCommands(),
+		locks.NewLockCommands(),
+		secrets.NewSecretsCommands(),
+		registries.NewRegistryCommands(),
 	)
 
 	return rootCommand
@@ -183,6 +189,7 @@ func init() {
 
 	noColor := false
 	verbose := false
+	strict := false
 
 	rootCommand.PersistentFlags().StringVarP(&logLevel, "log-level", "l", "info", "log level. One of none|trace|debug|info|warn|error|fatal")
 	rootCommand.PersistentFlags().StringVar(&logFilePath, "log-file", "", "log to the given file")
@@ -192,6 +199,7 @@ func init() {
 	rootCommand.PersistentFlags().BoolVarP(&jsonLogs, "json-logs", "j", false, "whether to emit JSON-formatted logs")
 	rootCommand.PersistentFlags().BoolVar(&noColor, "no-color", false, "disable coloured output")
 	rootCommand.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "show more output")
+	rootCommand.PersistentFlags().BoolVar(&strict, "strict", false, "fail if templates refer to undefined variables")
 
 	// bind viper to CLI args
 	bindings := map[string]string{
@@ -199,6 +207,7 @@ func init() {
 		"json_logs": "json-logs",
 		"no_color":  "no-color",
 		"verbose":   "verbose",
+		"strict":    "strict",
 	}
 
 	viperConfig := config.ViperConfig
@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package secrets
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
+	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/program"
+	"github.com/sugarkube/sugarkube/internal/pkg/vars"
+	"os"
+	"os/exec"
+)
+
+type editCommand struct {
+	path string
+}
+
+func newEditCommand() *cobra.Command {
+	c := &editCommand{}
+
+	usage := "edit [flags] [file]"
+	command := &cobra.Command{
+		Use:   usage,
+		Short: fmt.Sprintf("Edit an encrypted vars file"),
+		Long: `Decrypts a SOPS-encrypted vars file, opens it in your editor ($EDITOR) and 
+encrypts it again when you've finished. If the file doesn't exist it's created 
+and encrypted for the recipients (e.g. age or PGP keys) configured in the 
+nearest .sops.yaml file.
+
+This runs 'sops', which must be installed.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			err := cmd.ValidateNumArgs(args, 1, usage)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			c.path = args[0]
+			return c.run()
+		},
+	}
+
+	return command
+}
+
+func (c *editCommand) run() error {
+	command := exec.Command(vars.SopsCommand, c.path)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	log.Logger.Infof("Editing encrypted file '%s' with %s", c.path, vars.SopsCommand)
+
+	err := command.Run()
+	if err != nil {
+		if exitError, ok := err.(*exec.ExitError); ok {
+			return program.SimpleError{Message: fmt.Sprintf("%s exited with code %d",
+				vars.SopsCommand, exitError.ExitCode())}
+		}
+		return errors.Wrapf(err, "Error running %s. Make sure it's installed", vars.SopsCommand)
+	}
+
+	return nil
+}
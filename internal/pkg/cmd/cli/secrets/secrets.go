@@ -0,0 +1,45 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package secrets
+
+import (
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+func NewSecretsCommands() *cobra.Command {
+
+	command := &cobra.Command{
+		Use:   "secrets [command]",
+		Short: fmt.Sprintf("Work with encrypted vars files"),
+		Long: `Work with secrets. Kapp and provider vars dirs can contain SOPS-encrypted YAML 
+or JSON files, which are decrypted in memory when vars are loaded. Templates can 
+also load secrets from Vault, environment variables, files or a local encrypted 
+keystore with the 'secret' function, e.g. 'secret "vault://secret/app#password"'.
+Secrets are masked in logs and in the output of 'kapps vars' unless 
+'--show-secrets' is passed.`,
+	}
+
+	command.AddCommand(
+		newEditCommand(),
+		newSetCommand(),
+	)
+
+	command.Aliases = []string{"secret"}
+
+	return command
+}
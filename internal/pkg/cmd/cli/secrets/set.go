@@ -0,0 +1,95 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secrets
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
+	"github.com/sugarkube/sugarkube/internal/pkg/printer"
+	"github.com/sugarkube/sugarkube/internal/pkg/program"
+	"github.com/sugarkube/sugarkube/internal/pkg/secrets"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+type setCommand struct {
+	path string
+	key  string
+}
+
+func newSetCommand() *cobra.Command {
+	c := &setCommand{}
+
+	usage := "set [flags] [path#key]"
+	command := &cobra.Command{
+		Use:   usage,
+		Short: fmt.Sprintf("Store a secret in the local keystore"),
+		Long: fmt.Sprintf(`Stores a secret read from stdin in the local encrypted keystore so templates 
+can load it with e.g. 'secret "keystore://app/db#password"'. The keystore is 
+encrypted with the passphrase in %s and is created if it 
+doesn't exist. Its location can be set with 'secrets.keystore.path' in the 
+sugarkube config file.
+
+Example:
+
+  echo -n 'hunter2' | sugarkube secrets set app/db#password`, secrets.KeystorePassphraseEnvVar),
+		RunE: func(command *cobra.Command, args []string) error {
+			err := cmd.ValidateNumArgs(args, 1, usage)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			elements := strings.SplitN(args[0], "#", 2)
+			if len(elements) != 2 || elements[0] == "" || elements[1] == "" {
+				return program.SimpleError{Message: fmt.Sprintf("Invalid secret '%s'. It "+
+					"should be formatted 'path#key'", args[0])}
+			}
+			c.path = elements[0]
+			c.key = elements[1]
+			return c.run()
+		},
+	}
+
+	return command
+}
+
+func (c *setCommand) run() error {
+	value, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = secrets.SetKeystoreSecret(c.path, c.key, strings.TrimSuffix(string(value), "\n"))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	keystorePath, err := secrets.KeystorePath()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = printer.Fprintf("[green]Stored '[bold]%s#%s[reset][green]' in keystore '%s'\n",
+		c.path, c.key, keystorePath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
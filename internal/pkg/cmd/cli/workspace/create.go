@@ -23,12 +23,14 @@ import (
 	"github.com/sugarkube/sugarkube/internal/pkg/cacher"
 	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
 	"github.com/sugarkube/sugarkube/internal/pkg/constants"
+	"github.com/sugarkube/sugarkube/internal/pkg/lock"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
 	"github.com/sugarkube/sugarkube/internal/pkg/plan"
 	"github.com/sugarkube/sugarkube/internal/pkg/printer"
 	"github.com/sugarkube/sugarkube/internal/pkg/stack"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
 	"path/filepath"
+	"time"
 )
 
 type createCommand struct {
@@ -45,6 +47,9 @@ type createCommand struct {
 	skipTemplates   bool
 	includeSelector []string
 	excludeSelector []string
+	waitForLock     bool
+	lockTimeout     uint32
+	refreshOutputs  bool
 }
 
 func newCreateCommand() *cobra.Command {
@@ -84,6 +89,9 @@ templates defined by kapps.`,
 		fmt.Sprintf("exclude individual kapps (can specify multiple, formatted 'manifest-id:kapp-id' or 'manifest-id:%s' for all)",
 			constants.WildcardCharacter))
 
+	f.BoolVar(&c.waitForLock, "wait", false, "wait for the lock if another process is already working on this stack")
+	f.Uint32Var(&c.lockTimeout, "lock-timeout", 0, "max number of seconds to wait for the lock with --wait (0 waits forever)")
+	f.BoolVar(&c.refreshOutputs, "refresh-outputs", false, "run output steps again instead of reusing outputs saved in the registry by previous runs")
 	return command
 }
 
@@ -93,14 +101,26 @@ func (c *createCommand) run() error {
 
 	// CLI args override configured args, so merge them in
 	cliStackConfig := &structs.StackFile{
-		Provider:    c.provider,
-		Provisioner: c.provisioner,
-		Profile:     c.profile,
-		Cluster:     c.cluster,
-		Region:      c.region,
-		Account:     c.account,
+		Provider:       c.provider,
+		Provisioner:    c.provisioner,
+		Profile:        c.profile,
+		Cluster:        c.cluster,
+		Region:         c.region,
+		Account:        c.account,
+		RefreshOutputs: c.refreshOutputs,
 	}
 
+	var runLock *lock.Lock
+	if !c.dryRun {
+		var err error
+		runLock, err = lock.Acquire(c.workspaceDir, c.stackName, c.waitForLock,
+			time.Duration(c.lockTimeout)*time.Second)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	defer func() { _ = runLock.Release() }()
+
 	stackObj, err := stack.BuildStack(c.stackName, c.stackFile, cliStackConfig)
 	if err != nil {
 		return errors.WithStack(err)
@@ -0,0 +1,385 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package workspace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/sugarkube/sugarkube/internal/pkg/acquirer"
+	"github.com/sugarkube/sugarkube/internal/pkg/cacher"
+	"github.com/sugarkube/sugarkube/internal/pkg/cmd"
+	"github.com/sugarkube/sugarkube/internal/pkg/constants"
+	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
+	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/printer"
+	"github.com/sugarkube/sugarkube/internal/pkg/program"
+	"github.com/sugarkube/sugarkube/internal/pkg/stack"
+	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const outputFormatTable = "table"
+const outputFormatJson = "json"
+
+type statusCommand struct {
+	stackName       string
+	stackFile       string
+	provider        string
+	provisioner     string
+	profile         string
+	account         string
+	cluster         string
+	region          string
+	workspaceDir    string
+	outputFormat    string
+	includeSelector []string
+	excludeSelector []string
+}
+
+// The status of a kapp in a workspace
+type kappStatus struct {
+	Id                string                  `json:"id"`
+	Cached            bool                    `json:"cached"`
+	Sources           map[string]sourceStatus `json:"sources"`
+	TemplatesRendered *bool                   `json:"templatesRendered"` // nil if the kapp has no templates apart from sensitive ones
+	OutputsExist      *bool                   `json:"outputsExist"`      // nil if the kapp has no outputs
+	Missing           []string                `json:"missing,omitempty"` // paths to missing templates/outputs
+}
+
+type sourceStatus struct {
+	acquirer.SourceStatus
+	Error string `json:"error,omitempty"`
+}
+
+func newStatusCommand() *cobra.Command {
+	c := &statusCommand{}
+
+	usage := "status [flags] [stack-file] [stack-name] [workspace-dir]"
+	command := &cobra.Command{
+		Use:   usage,
+		Short: fmt.Sprintf("Show the status of kapps in a workspace"),
+		Long: `Shows whether each kapp in a workspace has been cached, where its sources came from, 
+which branch/tag and commit is checked out compared to what was requested, whether the 
+checkout has uncommitted changes, and whether its templates and outputs exist.`,
+		RunE: func(command *cobra.Command, args []string) error {
+			err := cmd.ValidateNumArgs(args, 3, usage)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			c.stackFile = args[0]
+			c.stackName = args[1]
+			c.workspaceDir = args[2]
+			return c.run(command.OutOrStdout())
+		},
+	}
+
+	f := command.Flags()
+	f.StringVar(&c.provider, "provider", "", "name of provider, e.g. aws, local, etc.")
+	f.StringVar(&c.provisioner, "provisioner", "", "name of provisioner, e.g. kops, minikube, etc.")
+	f.StringVar(&c.profile, "profile", "", "launch profile, e.g. dev, test, prod, etc.")
+	f.StringVarP(&c.cluster, "cluster", "c", "", "name of cluster to launch, e.g. dev1, dev2, etc.")
+	f.StringVarP(&c.account, "account", "a", "", "string identifier for the account to launch in (for providers that support it)")
+	f.StringVarP(&c.region, "region", "r", "", "name of region (for providers that support it)")
+	f.StringVarP(&c.outputFormat, "output", "o", outputFormatTable,
+		fmt.Sprintf("output format, either '%s' or '%s'", outputFormatTable, outputFormatJson))
+	f.StringArrayVarP(&c.includeSelector, "include", "i", []string{},
+		fmt.Sprintf("only process specified kapps (can specify multiple, formatted 'manifest-id:kapp-id' or 'manifest-id:%s' for all)",
+			constants.WildcardCharacter))
+	f.StringArrayVarP(&c.excludeSelector, "exclude", "x", []string{},
+		fmt.Sprintf("exclude individual kapps (can specify multiple, formatted 'manifest-id:kapp-id' or 'manifest-id:%s' for all)",
+			constants.WildcardCharacter))
+	return command
+}
+
+func (c *statusCommand) run(out io.Writer) error {
+
+	if c.outputFormat != outputFormatTable && c.outputFormat != outputFormatJson {
+		return program.SimpleError{Message: fmt.Sprintf("Invalid output format '%s'. Must be "+
+			"either '%s' or '%s'", c.outputFormat, outputFormatTable, outputFormatJson)}
+	}
+
+	// CLI args override configured args, so merge them in
+	cliStackConfig := &structs.StackFile{
+		Provider:    c.provider,
+		Provisioner: c.provisioner,
+		Profile:     c.profile,
+		Cluster:     c.cluster,
+		Region:      c.region,
+		Account:     c.account,
+	}
+
+	// send progress messages to stderr so only JSON is written to stdout
+	if c.outputFormat == outputFormatJson {
+		previousOutput := printer.SetOutput(os.Stderr)
+		defer printer.SetOutput(previousOutput)
+	}
+
+	stackObj, err := stack.BuildStack(c.stackName, c.stackFile, cliStackConfig)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	absWorkspaceDir, err := filepath.Abs(c.workspaceDir)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if _, err := os.Stat(absWorkspaceDir); err != nil {
+		return program.SimpleError{Message: fmt.Sprintf("Workspace dir '%s' doesn't exist",
+			absWorkspaceDir)}
+	}
+
+	selectedInstallables, err := stack.SelectInstallables(stackObj.GetConfig().Manifests(),
+		c.includeSelector, c.excludeSelector)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	statuses := make([]kappStatus, 0)
+
+	for _, installableObj := range selectedInstallables {
+		status, err := getKappStatus(stackObj, installableObj, absWorkspaceDir)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		statuses = append(statuses, status)
+	}
+
+	if c.outputFormat == outputFormatJson {
+		jsonData, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		_, err = fmt.Fprintln(out, string(jsonData))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		return nil
+	}
+
+	return printStatusTable(statuses)
+}
+
+// Returns the status of a kapp and each of its sources in the workspace
+func getKappStatus(stackObj interfaces.IStack, installableObj interfaces.IInstallable,
+	workspaceDir string) (kappStatus, error) {
+	status := kappStatus{
+		Id:      installableObj.FullyQualifiedId(),
+		Sources: map[string]sourceStatus{},
+		Missing: []string{},
+	}
+
+	err := installableObj.SetWorkspaceDir(workspaceDir)
+	if err != nil {
+		return status, errors.WithStack(err)
+	}
+
+	cacheDir := installableObj.GetCacheDir()
+	if _, err := os.Stat(cacheDir); err == nil {
+		status.Cached = true
+	}
+
+	acquirers, err := installableObj.Acquirers()
+	if err != nil {
+		return status, errors.WithStack(err)
+	}
+
+	for key, acquirerObj := range acquirers {
+		sourceDir, err := cacher.SourceDir(cacheDir, acquirerObj)
+		if err != nil {
+			return status, errors.WithStack(err)
+		}
+
+		// report errors against the source rather than aborting since the point of this command is
+		// to help diagnose problems with workspaces
+		sourceStatusObj, err := acquirer.Status(acquirerObj, sourceDir)
+		source := sourceStatus{SourceStatus: sourceStatusObj}
+		if err != nil {
+			log.Logger.Warnf("Error getting the status of source '%s' for kapp '%s': %+v",
+				key, installableObj.FullyQualifiedId(), err)
+			source.Error = err.Error()
+		}
+
+		status.Sources[key] = source
+	}
+
+	if !status.Cached {
+		return status, nil
+	}
+
+	err = installableObj.LoadConfigFile(workspaceDir)
+	if err != nil {
+		log.Logger.Warnf("Error loading the config file for kapp '%s': %v",
+			installableObj.FullyQualifiedId(), err)
+		return status, nil
+	}
+
+	// template the descriptor so we can find where templates and outputs should be. This can fail
+	// if e.g. paths refer to outputs of other kapps, in which case we'll check the raw paths.
+	templatedVars, err := stackObj.GetTemplatedVars(installableObj, map[string]interface{}{})
+	if err == nil {
+		err = installableObj.TemplateDescriptor(templatedVars)
+	}
+	if err != nil {
+		log.Logger.Debugf("Error templating the descriptor for kapp '%s'. Will check "+
+			"untemplated paths: %v", installableObj.FullyQualifiedId(), err)
+	}
+
+	descriptor := installableObj.GetDescriptor()
+
+	rendered := true
+	numTemplates := 0
+	for _, template := range descriptor.Templates {
+		// sensitive templates only exist while the kapp is being executed
+		if template.Sensitive {
+			continue
+		}
+
+		numTemplates++
+		exists := pathExists(installableObj, template.Dest)
+		if !exists {
+			status.Missing = append(status.Missing, template.Dest)
+		}
+		rendered = rendered && exists
+	}
+
+	if numTemplates > 0 {
+		status.TemplatesRendered = &rendered
+	}
+
+	if len(descriptor.Outputs) > 0 {
+		outputsExist := true
+		for _, output := range descriptor.Outputs {
+			exists := pathExists(installableObj, output.Path)
+			if !exists {
+				status.Missing = append(status.Missing, output.Path)
+			}
+			outputsExist = outputsExist && exists
+		}
+		status.OutputsExist = &outputsExist
+	}
+
+	sort.Strings(status.Missing)
+
+	return status, nil
+}
+
+// Returns whether a path exists. Relative paths are relative to the kapp's config file directory.
+func pathExists(installableObj interfaces.IInstallable, path string) bool {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(installableObj.GetConfigFileDir(), path)
+	}
+
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Prints a row for each source of each kapp
+func printStatusTable(statuses []kappStatus) error {
+	var buf bytes.Buffer
+	writer := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	_, err := fmt.Fprintln(writer, "KAPP\tCACHED\tSOURCE\tACQUIRER\tURI\tREQUESTED\tCHECKED OUT\t"+
+		"SHA\tDIRTY\tTEMPLATES\tOUTPUTS")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, status := range statuses {
+		sourceKeys := make([]string, 0, len(status.Sources))
+		for key := range status.Sources {
+			sourceKeys = append(sourceKeys, key)
+		}
+		sort.Strings(sourceKeys)
+
+		if len(sourceKeys) == 0 {
+			// still print a row for kapps without sources
+			sourceKeys = append(sourceKeys, "")
+		}
+
+		for _, key := range sourceKeys {
+			source := status.Sources[key]
+
+			columns := []string{
+				status.Id,
+				strconv.FormatBool(status.Cached),
+				orDash(key),
+				orDash(source.Acquirer),
+				orDash(source.Uri),
+				orDash(source.Requested),
+				orDash(source.CheckedOut),
+				orDash(shortSha(source.Sha)),
+				strconv.FormatBool(source.Dirty),
+				formatOptionalBool(status.TemplatesRendered),
+				formatOptionalBool(status.OutputsExist),
+			}
+
+			if source.Error != "" {
+				columns[6] = "error"
+			}
+
+			_, err = fmt.Fprintln(writer, strings.Join(columns, "\t"))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = printer.Fprint(buf.String())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func shortSha(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
+
+func formatOptionalBool(value *bool) string {
+	if value == nil {
+		return "-"
+	}
+	return strconv.FormatBool(*value)
+}
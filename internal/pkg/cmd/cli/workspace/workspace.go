@@ -19,6 +19,11 @@ package workspace
 import (
 	"fmt"
 	"github.com/spf13/cobra"
+	"github.com/sugarkube/sugarkube/internal/pkg/lock"
+	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"os"
+	"os/signal"
+	"syscall"
 )
 
 func NewWorkspaceCommands() *cobra.Command {
@@ -27,10 +32,24 @@ func NewWorkspaceCommands() *cobra.Command {
 		Use:   "workspace [command]",
 		Short: fmt.Sprintf("Work with workspaces"),
 		Long:  `Create and refresh workspaces`,
+		PersistentPreRun: func(command *cobra.Command, args []string) {
+			log.Logger.Debug("Setting up signal handler")
+			// catch termination via CTRL-C so we can release any locks
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+			go func() {
+				<-signals
+				log.Logger.Info("Caught termination signal. Will try to gracefully terminate...")
+				lock.ReleaseAll()
+				log.Logger.Info("Graceful shutdown complete")
+				os.Exit(1)
+			}()
+		},
 	}
 
 	command.AddCommand(
 		newCreateCommand(),
+		newStatusCommand(),
 	)
 
 	command.Aliases = []string{"cache", "ws"} // for backwards compatibility after renaming cache -> workspace and laziness
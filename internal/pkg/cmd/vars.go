@@ -0,0 +1,79 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"github.com/pkg/errors"
+	"github.com/spf13/pflag"
+	"github.com/sugarkube/sugarkube/internal/pkg/vars"
+	"strings"
+)
+
+// Flags for setting kapp vars on the command line
+type KappVarFlags struct {
+	// values of `--set` and `--set-string` in the order they were given
+	Overrides   []vars.Override
+	ValuesFiles []string
+}
+
+// Adds the flags to a flag set
+func (k *KappVarFlags) AddFlags(f *pflag.FlagSet) {
+	f.Var(&overrideFlag{overrides: &k.Overrides}, "set", "set a kapp var, formatted "+
+		"'manifest-id:kapp-id.path.to.key=value'. Values are parsed as YAML so maps and lists can be "+
+		"given (can specify multiple)")
+	f.Var(&overrideFlag{overrides: &k.Overrides, isString: true}, "set-string", "set a kapp var "+
+		"to a string, formatted 'manifest-id:kapp-id.path.to.key=value' (can specify multiple)")
+	f.StringArrayVar(&k.ValuesFiles, "values", []string{}, "YAML file of kapp vars keyed by fully-qualified "+
+		"kapp ID (can specify multiple)")
+}
+
+// Returns the kapp vars set by the flags keyed by fully-qualified kapp ID
+func (k KappVarFlags) Parse() (map[string]map[string]interface{}, error) {
+	overrides, err := vars.ParseOverrides(k.ValuesFiles, k.Overrides)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return overrides, nil
+}
+
+// Adds the values of `--set` and `--set-string` to the same list so later values override earlier
+// ones regardless of which flag they were given with
+type overrideFlag struct {
+	overrides *[]vars.Override
+	isString  bool
+}
+
+func (o *overrideFlag) String() string {
+	values := make([]string, 0)
+	for _, override := range *o.overrides {
+		if override.IsString == o.isString {
+			values = append(values, override.Value)
+		}
+	}
+
+	return "[" + strings.Join(values, ",") + "]"
+}
+
+func (o *overrideFlag) Set(value string) error {
+	*o.overrides = append(*o.overrides, vars.Override{Value: value, IsString: o.isString})
+	return nil
+}
+
+func (o *overrideFlag) Type() string {
+	return "stringArray"
+}
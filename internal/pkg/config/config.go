@@ -20,6 +20,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
 	"os"
 	"os/user"
 	"path"
@@ -28,11 +29,14 @@ import (
 
 const ConfigFileName = "sugarkube-conf"
 
+// Prefix of environment variables that set config values
+const EnvPrefix = "SUGARKUBE"
+
 var CurrentConfig *Config
 var ViperConfig *viper.Viper
 
 func init() {
-	ViperConfig = initViper("SUGARKUBE")
+	ViperConfig = initViper(EnvPrefix)
 }
 
 func initViper(appName string) *viper.Viper {
@@ -46,6 +50,9 @@ func initViper(appName string) *viper.Viper {
 	v.SetDefault("log_level", "info")
 	v.SetDefault("num_workers", "5")
 	v.SetDefault("verbose", false)
+	v.SetDefault("strict", false)
+	v.SetDefault("sensitive_key_patterns", redact.DefaultKeyPatterns)
+	v.SetDefault("registry.persist", true)
 
 	v.SetConfigName(ConfigFileName)
 
@@ -84,6 +91,11 @@ func Load(viperConfig *viper.Viper) error {
 		return errors.Wrapf(err, "Error unmarshalling config")
 	}
 
+	err = redact.SetKeyPatterns(newConfig.SensitiveKeyPatterns)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	log.Logger.Debugf("Loaded config struct: %#v", newConfig)
 
 	CurrentConfig = newConfig
@@ -39,9 +39,11 @@ func TestLoadConfig(t *testing.T) {
 	last := uint8(99)
 
 	expectedConfig := &Config{
-		JsonLogs:   false,
-		LogLevel:   "warn",
-		NumWorkers: 5,
+		JsonLogs:             false,
+		LogLevel:             "warn",
+		NumWorkers:           5,
+		SensitiveKeyPatterns: []string{"*password*", "*token*"},
+		Registry:             RegistryConfig{Persist: true},
 		RunUnits: structs.RunUnit{
 			Clean: []structs.RunStep{
 				{
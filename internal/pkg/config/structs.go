@@ -24,6 +24,50 @@ type Config struct {
 	LogLevel   string `mapstructure:"log_level"`
 	NumWorkers int    `mapstructure:"num_workers"` // an uncontroversial name that avoids British/American spelling differences (vs 'parallelisation', etc)
 	Verbose    bool
+	Strict     bool                          `mapstructure:"strict"` // fail when templates refer to undefined variables
 	Programs   map[string]structs.KappConfig `mapstructure:"programs"`
 	RunUnits   structs.RunUnit               `yaml:"run_units" mapstructure:"run_units"` // global run units
+	Lock       LockConfig                    `mapstructure:"lock"`
+	// if set, sensitive templates are written to this directory (e.g. a tmpfs mount like /dev/shm)
+	// and symlinked to from their destination
+	SensitiveTemplatesDir string        `mapstructure:"sensitive_templates_dir"`
+	Secrets               SecretsConfig `mapstructure:"secrets"`
+	// values of vars, outputs and env vars with keys matching these glob patterns are masked in all output
+	SensitiveKeyPatterns []string       `mapstructure:"sensitive_key_patterns"`
+	Registry             RegistryConfig `mapstructure:"registry"`
+}
+
+// Config for persisting each stack's registry in workspaces between runs
+type RegistryConfig struct {
+	Persist bool `mapstructure:"persist"`
+	Encrypt bool `mapstructure:"encrypt"` // encrypt with the passphrase in SUGARKUBE_REGISTRY_PASSPHRASE
+}
+
+// Config for the backends secrets can be loaded from with the `secret` template function
+type SecretsConfig struct {
+	Vault    VaultConfig    `mapstructure:"vault"`
+	Keystore KeystoreConfig `mapstructure:"keystore"`
+}
+
+// Tokens are read from VAULT_TOKEN or ~/.vault-token instead of being configured here
+type VaultConfig struct {
+	Address   string `mapstructure:"address"`   // defaults to VAULT_ADDR
+	Namespace string `mapstructure:"namespace"` // defaults to VAULT_NAMESPACE (Vault Enterprise only)
+	Timeout   int    `mapstructure:"timeout"`   // max number of seconds to wait for each request
+}
+
+type KeystoreConfig struct {
+	Path string `mapstructure:"path"` // defaults to ~/.sugarkube/keystore
+}
+
+type LockConfig struct {
+	Remote RemoteLockConfig `mapstructure:"remote"`
+}
+
+// Commands to run to take/release a lock shared between machines. Details of the lock holder are
+// passed as env vars prefixed with SUGARKUBE_LOCK_
+type RemoteLockConfig struct {
+	Acquire string `mapstructure:"acquire"` // should exit non-zero if the lock is already held
+	Release string `mapstructure:"release"`
+	Timeout int    `mapstructure:"timeout"` // max number of seconds each command may run for
 }
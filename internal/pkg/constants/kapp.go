@@ -18,6 +18,7 @@ package constants
 
 const KappConfigFileName = "sugarkube.yaml"
 const KappVarsKappKey = "kapp"
+const KappVarsKappsKey = "kapps" // vars of other kapps are namespaced under this key
 const KappVarsVarsKey = "vars"
 const KappVarsTemplatesKey = "templates"
 const KappGeneratedPlaceholder = "<generated>"
@@ -17,12 +17,15 @@
 package convert
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/sugarkube/sugarkube/internal/pkg/acquirer"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
 	"reflect"
+	"strconv"
 )
 
 // Return an error if the type of an input can't easily be converted
@@ -137,3 +140,77 @@ func KappDescriptorWithListsToMap(descriptor structs.KappDescriptorWithLists) (
 		Outputs:    outputs,
 	}, nil
 }
+
+// Returns a copy of a value with the keys of all nested maps converted to strings
+func StringifyKeys(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		output := make(map[string]interface{}, len(typed))
+		for key, child := range typed {
+			output[fmt.Sprintf("%v", key)] = StringifyKeys(child)
+		}
+		return output
+	case map[string]interface{}:
+		output := make(map[string]interface{}, len(typed))
+		for key, child := range typed {
+			output[key] = StringifyKeys(child)
+		}
+		return output
+	case []interface{}:
+		output := make([]interface{}, len(typed))
+		for i, child := range typed {
+			output[i] = StringifyKeys(child)
+		}
+		return output
+	}
+
+	return value
+}
+
+// Unmarshals JSON without losing the precision of numbers (by default they're decoded as float64
+// so e.g. 123456789012 would become 1.23456789012e+11). Use `JsonNumbers` to convert numbers in
+// the result to int64 or float64.
+func UnmarshalJson(contents []byte, out interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.UseNumber()
+
+	err := decoder.Decode(out)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// Returns a copy of a value unmarshalled by `UnmarshalJson` with numbers converted to int64 if
+// they're integers, otherwise float64
+func JsonNumbers(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case json.Number:
+		intValue, err := strconv.ParseInt(typed.String(), 10, 64)
+		if err == nil {
+			return intValue
+		}
+
+		floatValue, err := typed.Float64()
+		if err == nil {
+			return floatValue
+		}
+
+		return typed.String()
+	case map[string]interface{}:
+		output := make(map[string]interface{}, len(typed))
+		for key, child := range typed {
+			output[key] = JsonNumbers(child)
+		}
+		return output
+	case []interface{}:
+		output := make([]interface{}, len(typed))
+		for i, child := range typed {
+			output[i] = JsonNumbers(child)
+		}
+		return output
+	}
+
+	return value
+}
@@ -212,3 +212,16 @@ func TestMapInterfaceInterfaceToMapStringInterface(t *testing.T) {
 		}
 	}
 }
+
+func TestJsonNumbers(t *testing.T) {
+	var result interface{}
+	err := UnmarshalJson([]byte(`{"int": 123456789012, "float": 1.5, "list": [1, "a"], "big": 1e400}`), &result)
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"int":   int64(123456789012),
+		"float": 1.5,
+		"list":  []interface{}{int64(1), "a"},
+		"big":   "1e400",
+	}, JsonNumbers(result))
+}
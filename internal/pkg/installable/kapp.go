@@ -18,7 +18,6 @@ package installable
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/sugarkube/sugarkube/internal/pkg/acquirer"
@@ -28,6 +27,7 @@ import (
 	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
 	"github.com/sugarkube/sugarkube/internal/pkg/printer"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
 	"github.com/sugarkube/sugarkube/internal/pkg/templater"
 	"github.com/sugarkube/sugarkube/internal/pkg/utils"
@@ -44,11 +44,19 @@ type Kapp struct {
 	configFileDir    string                           // path to the directory containing the kapp's sugarkube.yaml file
 	mergedDescriptor structs.KappDescriptorWithMaps   // the final descriptor after merging all the descriptor layers. This is a template until its rendered by TemplateDescriptor
 	descriptorLayers []structs.KappDescriptorWithMaps // config templates where values from later configs will take precedence over earlier ones
+	varsLayers       []varsLayer                      // the vars declared by each descriptor layer, in the same order as descriptorLayers
 	kappCacheDir     string                           // the top-level directory for this kapp in the workspace, i.e. the directory containing the kapp's .sugarkube directory
 	localRegistry    interfaces.IRegistry             // a registry local to the kapp that contains the results of merging
 	// each of its parents' registries, tailored depending on whether parent was in the same manifest
 }
 
+// The vars declared by a descriptor layer. We need to keep these separately because vars are propagated
+// to later descriptor layers when they're merged.
+type varsLayer struct {
+	origin string
+	vars   map[string]interface{}
+}
+
 // Returns the non-fully qualified ID
 func (k Kapp) Id() string {
 	return k.mergedDescriptor.Id
@@ -160,10 +168,18 @@ func (k *Kapp) AddDescriptor(config structs.KappDescriptorWithMaps, prepend bool
 		config.Templates[k] = template
 	}
 
+	layerVars := varsLayer{origin: config.Origin}
+	err = utils.DeepCopy(config.Vars, &layerVars.vars)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
 	if prepend {
 		k.descriptorLayers = append([]structs.KappDescriptorWithMaps{configCopy}, configLayers...)
+		k.varsLayers = append([]varsLayer{layerVars}, k.varsLayers...)
 	} else {
 		k.descriptorLayers = append(configLayers, configCopy)
+		k.varsLayers = append(k.varsLayers, layerVars)
 	}
 
 	log.Logger.Tracef("Kapp '%s' has %d descriptor layers", k.FullyQualifiedId(), len(k.descriptorLayers))
@@ -236,6 +252,21 @@ func (k *Kapp) AddDescriptor(config structs.KappDescriptorWithMaps, prepend bool
 				if currentOutput.Path == "" && previousOutput.Path != "" {
 					currentOutput.Path = previousOutput.Path
 				}
+				if currentOutput.RegistryPath == "" && previousOutput.RegistryPath != "" {
+					currentOutput.RegistryPath = previousOutput.RegistryPath
+				}
+				if currentOutput.Select == "" && previousOutput.Select != "" {
+					currentOutput.Select = previousOutput.Select
+				}
+				if len(currentOutput.Rename) == 0 && len(previousOutput.Rename) > 0 {
+					currentOutput.Rename = previousOutput.Rename
+				}
+				if len(currentOutput.Schema) == 0 && len(previousOutput.Schema) > 0 {
+					currentOutput.Schema = previousOutput.Schema
+				}
+				if currentOutput.DryRunValue == nil && previousOutput.DryRunValue != nil {
+					currentOutput.DryRunValue = previousOutput.DryRunValue
+				}
 				if currentOutput.Format == "" && previousOutput.Format != "" {
 					currentOutput.Format = previousOutput.Format
 				}
@@ -342,6 +373,9 @@ func (k *Kapp) mergeDescriptorLayers() error {
 		}
 	}
 
+	// the merged descriptor doesn't come from any single layer
+	mergedDescriptor.Origin = ""
+
 	k.mergedDescriptor = mergedDescriptor
 	log.Logger.Debugf("Set new merged descriptor for kapp '%s' to '%+v'", k.FullyQualifiedId(), mergedDescriptor)
 
@@ -440,6 +474,8 @@ func (k *Kapp) LoadConfigFile(workspaceDir string) error {
 		return errors.WithStack(err)
 	}
 
+	descriptorWithMaps.Origin = vars.Origin{Source: vars.SourceDescriptorVars, Detail: configFilePath}.String()
+
 	log.Logger.Tracef("Adding descriptor to kapp '%s' for its %s file", k.FullyQualifiedId(),
 		constants.KappConfigFileName)
 
@@ -476,6 +512,8 @@ func (k *Kapp) LoadConfigFile(workspaceDir string) error {
 
 			descriptor := structs.KappDescriptorWithMaps{
 				KappConfig: programDescriptor,
+				Origin: vars.Origin{Source: vars.SourceProgramDefaults,
+					Detail: fmt.Sprintf("'%s' in the sugarkube config file", requirement)}.String(),
 			}
 
 			log.Logger.Tracef("Adding descriptor to kapp '%s' for requirement '%s'",
@@ -505,7 +543,10 @@ func (k Kapp) GetLocalRegistry() interfaces.IRegistry {
 
 // Sets the local registry for the kapp
 func (k *Kapp) SetLocalRegistry(registry interfaces.IRegistry) {
-	log.Logger.Tracef("Setting local registry for kapp '%s' to: %#v", k.FullyQualifiedId(), registry)
+	if registry != nil {
+		log.Logger.Tracef("Setting local registry for kapp '%s' to: %#v", k.FullyQualifiedId(),
+			registry.AsMap())
+	}
 	k.localRegistry = registry
 }
 
@@ -518,7 +559,13 @@ func (k *Kapp) TemplateDescriptor(templateVars map[string]interface{}) error {
 		return errors.WithStack(err)
 	}
 
-	configTemplate, err := yaml.Marshal(k.mergedDescriptor)
+	// the vars template is rendered separately when templating vars, and rendering it here as
+	// part of the descriptor could produce invalid YAML, so leave it alone
+	varsTemplate := k.mergedDescriptor.VarsTemplate
+	descriptorToTemplate := k.mergedDescriptor
+	descriptorToTemplate.VarsTemplate = ""
+
+	configTemplate, err := yaml.Marshal(descriptorToTemplate)
 	if err != nil {
 		return errors.WithStack(err)
 	}
@@ -534,7 +581,11 @@ func (k *Kapp) TemplateDescriptor(templateVars map[string]interface{}) error {
 	var outBuf bytes.Buffer
 	err = templater.TemplateString(templateString, &outBuf, templateVars)
 	if err != nil {
-		return errors.WithStack(err)
+		if missingKeyErr, ok := errors.Cause(err).(templater.MissingKeyError); ok {
+			return errors.Wrapf(err, "Error templating field '%s' of the descriptor for kapp '%s'",
+				templater.FieldAtLine(templateString, missingKeyErr.Line), k.FullyQualifiedId())
+		}
+		return errors.Wrapf(err, "Error templating the descriptor for kapp '%s'", k.FullyQualifiedId())
 	}
 
 	log.Logger.Tracef("Rendered merged kapp descriptor\n%#v\nto:\n%s",
@@ -547,13 +598,38 @@ func (k *Kapp) TemplateDescriptor(templateVars map[string]interface{}) error {
 			outBuf.String())
 	}
 
+	configObj.VarsTemplate = varsTemplate
+
 	k.mergedDescriptor = configObj
 	return nil
 }
 
 // Returns a map of all variables for the kapp
 func (k Kapp) Vars(stack interfaces.IStack) (map[string]interface{}, error) {
-	kappVars, err := k.getVarsFromFiles(stack.GetConfig())
+	return k.mergeVars(stack, nil)
+}
+
+// Returns the same as `Vars`, recording which layer each value came from in the provenance
+func (k Kapp) ExplainVars(stack interfaces.IStack, provenance vars.Provenance) (map[string]interface{}, error) {
+	return k.mergeVars(stack, provenance)
+}
+
+// Merges all variables for the kapp. If provenance isn't nil the source of each value is recorded in it.
+func (k Kapp) mergeVars(stack interfaces.IStack, provenance vars.Provenance) (map[string]interface{}, error) {
+	kappVarsPrefix := []string{constants.KappVarsKappKey, constants.KappVarsVarsKey}
+
+	varsFiles, err := k.findVarsFiles(stack.GetConfig())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	kappVars := map[string]interface{}{}
+	err = vars.MergePaths(&kappVars, varsFiles...)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	err = provenance.RecordFiles(kappVarsPrefix, vars.SourceKappVarsFile, varsFiles...)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -562,6 +638,8 @@ func (k Kapp) Vars(stack interfaces.IStack) (map[string]interface{}, error) {
 
 	kappIntrinsicData := k.getIntrinsicData()
 	kappIntrinsicDataConverted = convert.MapStringStringToMapStringInterface(kappIntrinsicData)
+	provenance.Record([]string{constants.KappVarsKappKey}, kappIntrinsicDataConverted,
+		vars.SourceKappIntrinsic, "")
 
 	// merge kapp.Vars with the vars from files so kapp.Vars take precedence. Todo - document the order of precedence
 	err = vars.Merge(&kappVars, k.mergedDescriptor.Vars)
@@ -569,6 +647,43 @@ func (k Kapp) Vars(stack interfaces.IStack) (map[string]interface{}, error) {
 		return nil, errors.WithStack(err)
 	}
 
+	// the merged descriptor doesn't say which layer each var came from so record each layer in turn
+	for _, layer := range k.varsLayers {
+		source := layer.origin
+		if source == "" {
+			source = vars.SourceDescriptorVars
+		}
+		provenance.Record(kappVarsPrefix, layer.vars, source, "")
+	}
+
+	// vars set by environment variables take precedence over everything except vars set on the
+	// command line
+	kappVarEnvOverrides := stack.GetConfig().KappVarEnvOverrides(k.FullyQualifiedId())
+	envOverrides, err := vars.EnvOverridesToMap(kappVarEnvOverrides)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	provenance.RecordEnvOverrides(kappVarsPrefix, kappVarEnvOverrides)
+
+	overrides := stack.GetConfig().KappVarOverrides(k.FullyQualifiedId())
+	provenance.Record(kappVarsPrefix, overrides, vars.SourceCliOverride, "")
+
+	for _, fragment := range []map[string]interface{}{envOverrides, overrides} {
+		if len(fragment) == 0 {
+			continue
+		}
+
+		kappVars, err = vars.Normalise(kappVars)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		err = vars.Merge(&kappVars, fragment)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
 	// namespace kapp variables. This is safer than letting kapp variables overwrite arbitrary values (e.g.
 	// so they can't change the target stack, whether the kapp's approved, etc.), but may be too restrictive
 	// in certain situations. We'll have to see
@@ -589,6 +704,8 @@ func (k Kapp) Vars(stack interfaces.IStack) (map[string]interface{}, error) {
 	}
 
 	kappIntrinsicDataConverted[constants.KappVarsTemplatesKey] = templatesMap
+	provenance.Record([]string{constants.KappVarsKappKey, constants.KappVarsTemplatesKey}, templatesMap,
+		vars.SourceKappTemplates, "")
 
 	namespacedKappMap := map[string]interface{}{
 		constants.KappVarsKappKey: kappIntrinsicDataConverted,
@@ -597,12 +714,17 @@ func (k Kapp) Vars(stack interfaces.IStack) (map[string]interface{}, error) {
 	if k.localRegistry != nil {
 		// merge the local registry with the template vars so outputs are available to templates
 		log.Logger.Tracef("Merging local registry for kapp '%s' with kapp vars. Local registry is: %#v",
-			k.FullyQualifiedId(), k.localRegistry)
+			k.FullyQualifiedId(), k.localRegistry.AsMap())
+
+		// copy the registry's snapshot because merging may modify its submaps
+		registryCopy := utils.CopyNested(k.localRegistry.AsMap()).(map[string]interface{})
 
-		err = vars.Merge(&namespacedKappMap, k.localRegistry.AsMap())
+		err = vars.Merge(&namespacedKappMap, registryCopy)
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
+
+		provenance.RecordRegistry(registryCopy)
 	}
 
 	log.Logger.Tracef("Returning vars for kapp '%s': %v", k.FullyQualifiedId(), namespacedKappMap)
@@ -619,24 +741,6 @@ func (k Kapp) getIntrinsicData() map[string]string {
 	}
 }
 
-// Finds all vars files for the given kapp and returns the result of merging
-// all the data.
-func (k Kapp) getVarsFromFiles(stackConfig interfaces.IStackConfig) (map[string]interface{}, error) {
-	dirs, err := k.findVarsFiles(stackConfig)
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-
-	values := map[string]interface{}{}
-
-	err = vars.MergePaths(&values, dirs...)
-	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-
-	return values, nil
-}
-
 // This searches a directory tree from a given root path for files whose values
 // should be merged together for a kapp. If a kapp instance is supplied, additional files
 // will be searched for, in addition to stack-specific ones.
@@ -696,10 +800,8 @@ func (k Kapp) findVarsFiles(stackConfig interfaces.IStackConfig) ([]string, erro
 			}
 
 			if !info.IsDir() {
-				ext := filepath.Ext(path)
-
-				if strings.ToLower(ext) != ".yaml" {
-					log.Logger.Debugf("Ignoring non-yaml file: %s", path)
+				if !vars.IsVarsFile(path) {
+					log.Logger.Debugf("Ignoring file that isn't a vars file: %s", path)
 					return nil
 				}
 
@@ -718,35 +820,73 @@ func (k Kapp) findVarsFiles(stackConfig interfaces.IStackConfig) ([]string, erro
 	log.Logger.Debugf("Kapp var paths for kapp '%s' are: %s", kappId,
 		strings.Join(paths, ", "))
 
+	vars.WarnAboutConflicts(paths)
+
 	return paths, nil
 }
 
-// Renders templates for the kapp and returns the paths they were written to
+// Renders templates for the kapp apart from sensitive ones. The paths sensitive templates will be
+// rendered to are still added to the kapp's descriptor so other values can refer to them.
 func (k *Kapp) RenderTemplates(templateVars map[string]interface{}, stackConfig interfaces.IStackConfig,
 	dryRun bool) error {
+	return k.renderTemplates(templateVars, stackConfig, false, dryRun)
+}
+
+// Renders the kapp's sensitive templates. These are written with permissions that only allow the
+// current user to read them and should be deleted by calling DeleteSensitiveTemplates as soon as
+// the kapp has been executed.
+func (k *Kapp) RenderSensitiveTemplates(templateVars map[string]interface{},
+	stackConfig interfaces.IStackConfig, dryRun bool) error {
+	return k.renderTemplates(templateVars, stackConfig, true, dryRun)
+}
+
+// Deletes any sensitive templates rendered for the kapp
+func (k *Kapp) DeleteSensitiveTemplates() error {
+	return deleteSensitiveFiles(k.FullyQualifiedId())
+}
+
+// Renders either the kapp's sensitive templates or all the others
+func (k *Kapp) renderTemplates(templateVars map[string]interface{}, stackConfig interfaces.IStackConfig,
+	sensitive bool, dryRun bool) error {
 
 	dryRunPrefix := ""
 	if dryRun {
 		dryRunPrefix = "[Dry run] "
 	}
 
+	templateKind := "templates"
+	if sensitive {
+		templateKind = "sensitive templates"
+	}
+
 	// make sure the cache dir exists
 	if _, err := os.Stat(k.GetCacheDir()); err != nil {
 		return errors.New(fmt.Sprintf("Cache dir '%s' doesn't exist",
 			k.GetCacheDir()))
 	}
 
-	if len(k.mergedDescriptor.Templates) == 0 {
-		log.Logger.Infof("%sNo templates to render for kapp '%s'", dryRunPrefix, k.FullyQualifiedId())
+	numTemplates := 0
+	for _, templateDefinition := range k.mergedDescriptor.Templates {
+		if !sensitive || templateDefinition.Sensitive {
+			numTemplates++
+		}
+	}
+
+	if numTemplates == 0 {
+		log.Logger.Infof("%sNo %s to render for kapp '%s'", dryRunPrefix, templateKind, k.FullyQualifiedId())
 		return nil
 	}
 
-	log.Logger.Infof("%sRendering templates for kapp '%s'", dryRunPrefix, k.FullyQualifiedId())
+	log.Logger.Infof("%sRendering %s for kapp '%s'", dryRunPrefix, templateKind, k.FullyQualifiedId())
 
 	// build a list of rendered templates so we can add a new config descriptor that will contain the rendered paths
 	renderedTemplates := make(map[string]structs.Template, 0)
 
 	for templateId, templateDefinition := range k.mergedDescriptor.Templates {
+		if sensitive && !templateDefinition.Sensitive {
+			continue
+		}
+
 		// make sure that if any conditions are declared that they're all true
 		if len(templateDefinition.Conditions) > 0 {
 			allOk, err := utils.All(templateDefinition.Conditions)
@@ -772,7 +912,8 @@ func (k *Kapp) RenderTemplates(templateVars map[string]interface{}, stackConfig
 		// run the source path through the templater in case it contains variables
 		templateSource, err := templater.RenderTemplate(rawTemplateSource, templateVars)
 		if err != nil {
-			return errors.WithStack(err)
+			return errors.Wrapf(err, "Error rendering the source path of template '%s' for kapp '%s'",
+				templateId, k.FullyQualifiedId())
 		}
 
 		if !filepath.IsAbs(templateSource) {
@@ -824,7 +965,8 @@ func (k *Kapp) RenderTemplates(templateVars map[string]interface{}, stackConfig
 		// run the dest path through the templater in case it contains variables
 		destPath, err := templater.RenderTemplate(rawDestPath, templateVars)
 		if err != nil {
-			return errors.WithStack(err)
+			return errors.Wrapf(err, "Error rendering the dest path of template '%s' for kapp '%s'",
+				templateId, k.FullyQualifiedId())
 		}
 
 		if !filepath.IsAbs(destPath) {
@@ -845,11 +987,27 @@ func (k *Kapp) RenderTemplates(templateVars map[string]interface{}, stackConfig
 			return errors.New(fmt.Sprintf("Can't write template '%s' to non-existent directory: %s", templateId, destDir))
 		}
 
+		template := structs.Template{
+			Source:       templateDefinition.Source,
+			Dest:         templateDefinition.Dest,
+			RenderedPath: destPath,
+			Sensitive:    templateDefinition.Sensitive,
+		}
+
+		renderedTemplates[templateId] = template
+
+		if templateDefinition.Sensitive && !sensitive {
+			log.Logger.Infof("%sNot rendering sensitive template '%s' for kapp '%s' until "+
+				"the kapp is executed", dryRunPrefix, templateId, k.FullyQualifiedId())
+			continue
+		}
+
 		var outBuf bytes.Buffer
 
 		err = templater.TemplateFile(templateSource, &outBuf, templateVars)
 		if err != nil {
-			return errors.WithStack(err)
+			return errors.Wrapf(err, "Error rendering template '%s' for kapp '%s'", templateId,
+				k.FullyQualifiedId())
 		}
 
 		log.Logger.Infof("%sWriting rendered template '%s' for kapp "+
@@ -857,20 +1015,20 @@ func (k *Kapp) RenderTemplates(templateVars map[string]interface{}, stackConfig
 		log.Logger.Tracef("%sTemplate rendered as:\n%s", dryRunPrefix, outBuf.String())
 
 		if !dryRun {
-			err := ioutil.WriteFile(destPath, outBuf.Bytes(), 0644)
+			if sensitive {
+				err = writeSensitiveFile(k.FullyQualifiedId(), destPath, outBuf.Bytes())
+			} else {
+				err = ioutil.WriteFile(destPath, outBuf.Bytes(), 0644)
+			}
 			if err != nil {
 				return errors.WithStack(err)
 			}
 		}
+	}
 
-		template := structs.Template{
-			Source:       templateDefinition.Source,
-			Dest:         templateDefinition.Dest,
-			RenderedPath: destPath,
-			Sensitive:    templateDefinition.Sensitive,
-		}
-
-		renderedTemplates[templateId] = template
+	// the paths of sensitive templates were added when the other templates were rendered
+	if sensitive {
+		return nil
 	}
 
 	descriptor := structs.KappDescriptorWithMaps{
@@ -933,6 +1091,24 @@ func (k Kapp) GetOutputs(ignoreMissing bool, dryRun bool) (map[string]interface{
 
 		// ignore missing and empty files if a flag is given.
 		if fileInfo, err := os.Stat(path); err != nil || fileInfo.Size() == 0 {
+			// output steps aren't run during dry runs so publish synthetic values if we can
+			if dryRun && (output.DryRunValue != nil || len(output.Schema) > 0) {
+				dryRunValue, err := dryRunOutput(output)
+				if err != nil {
+					return nil, errors.Wrapf(err, "Error generating a dry run value for output '%s' "+
+						"of kapp '%s'", output.Id, k.FullyQualifiedId())
+				}
+
+				_, err = printer.Fprintf("[yellow]%sUsing a synthetic value for missing output '%s' of "+
+					"kapp '[bold]%s[reset][yellow]'\n", dryRunPrefix, output.Id, k.FullyQualifiedId())
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+
+				outputs[output.Id] = dryRunValue
+				continue
+			}
+
 			if ignoreMissing {
 				_, err := printer.Fprintf("[yellow]Ignoring missing output '%s' for kapp "+
 					"'[bold]%s[reset][yellow]'\n", path, k.FullyQualifiedId())
@@ -949,44 +1125,40 @@ func (k Kapp) GetOutputs(ignoreMissing bool, dryRun bool) (map[string]interface{
 		log.Logger.Infof("%sLoading output '%s' from kapp '%s' at '%s' as %s", dryRunPrefix,
 			output.Id, k.FullyQualifiedId(), path, output.Format)
 
-		var parsedOutput interface{}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
 
-		switch strings.ToLower(output.Format) {
-		case "json":
-			rawJson, err := ioutil.ReadFile(path)
-			if err != nil {
-				return nil, errors.WithStack(err)
-			}
+		parsedOutput, sensitiveValues, err := parseOutput(output.Format, contents)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error parsing output '%s' of kapp '%s' at '%s'",
+				output.Id, k.FullyQualifiedId(), path)
+		}
 
-			err = json.Unmarshal(rawJson, &parsedOutput)
-			if err != nil {
-				return nil, errors.WithStack(err)
-			}
-		case "yaml":
-			err = utils.LoadYamlFile(path, &parsedOutput)
-			if err != nil {
-				return nil, errors.WithStack(err)
-			}
+		// mask values the output itself declares are sensitive, e.g. sensitive terraform outputs
+		redact.AddValues(sensitiveValues)
 
-			parsedOutput, err = convert.MapInterfaceInterfaceToMapStringInterface(parsedOutput.(map[interface{}]interface{}))
-			if err != nil {
-				return nil, errors.WithStack(err)
-			}
-		case "text":
-			byteOutput, err := ioutil.ReadFile(path)
-			if err != nil {
-				return nil, errors.WithStack(err)
-			}
-			parsedOutput = string(byteOutput)
-		default:
-			return nil, errors.New(fmt.Sprintf("Unsupported output format '%s' for kapp '%s'",
-				output.Format, k.FullyQualifiedId()))
+		// only publish the data downstream kapps need
+		parsedOutput, err = transformOutput(output, parsedOutput)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error selecting data from output '%s' of kapp '%s'",
+				output.Id, k.FullyQualifiedId())
+		}
+
+		err = validateOutput(output, parsedOutput)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Output '%s' of kapp '%s' doesn't conform to its schema",
+				output.Id, k.FullyQualifiedId())
 		}
 
 		outputs[output.Id] = parsedOutput
+		redact.AddMatchingValues(map[string]interface{}{output.Id: parsedOutput})
 
-		// if it's sensitive, delete it
+		// if it's sensitive, mask its values and delete it
 		if output.Sensitive {
+			redact.AddValues(parsedOutput)
+
 			log.Logger.Infof("%sDeleting sensitive output file: %s", dryRunPrefix, path)
 			if !dryRun {
 				err = os.Remove(path)
@@ -0,0 +1,342 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package installable
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/hashicorp/hcl"
+	"github.com/magiconair/properties"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/convert"
+	"github.com/sugarkube/sugarkube/internal/pkg/schema"
+	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"gopkg.in/yaml.v2"
+	"strconv"
+	"strings"
+)
+
+// Supported output formats
+const (
+	outputFormatJson       = "json"
+	outputFormatYaml       = "yaml"
+	outputFormatText       = "text"
+	outputFormatDotenv     = "dotenv"
+	outputFormatTerraform  = "terraform"
+	outputFormatTfvars     = "tfvars"
+	outputFormatHcl        = "hcl"
+	outputFormatIni        = "ini"
+	outputFormatProperties = "properties"
+	outputFormatBase64     = "base64"
+)
+
+// Parses the contents of an output file in the given format. Also returns any values the output
+// itself declares are sensitive, e.g. sensitive terraform outputs.
+func parseOutput(format string, contents []byte) (interface{}, []interface{}, error) {
+	switch strings.ToLower(format) {
+	case outputFormatJson:
+		var parsed interface{}
+		err := convert.UnmarshalJson(contents, &parsed)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		return convert.JsonNumbers(parsed), nil, nil
+	case outputFormatYaml:
+		var parsed interface{}
+		err := yaml.UnmarshalStrict(contents, &parsed)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+
+		if parsedMap, ok := parsed.(map[interface{}]interface{}); ok {
+			converted, err := convert.MapInterfaceInterfaceToMapStringInterface(parsedMap)
+			if err != nil {
+				return nil, nil, errors.WithStack(err)
+			}
+			return converted, nil, nil
+		}
+		return parsed, nil, nil
+	case outputFormatText:
+		return string(contents), nil, nil
+	case outputFormatDotenv:
+		parsed, err := parseDotenv(contents)
+		return parsed, nil, err
+	case outputFormatTerraform:
+		return parseTerraformOutput(contents)
+	case outputFormatTfvars, outputFormatHcl:
+		parsed, err := parseHcl(contents)
+		return parsed, nil, err
+	case outputFormatIni:
+		parsed, err := parseIni(contents)
+		return parsed, nil, err
+	case outputFormatProperties:
+		parsed, err := parseProperties(contents)
+		return parsed, nil, err
+	case outputFormatBase64:
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(contents)))
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "Error decoding base64")
+		}
+		return string(decoded), nil, nil
+	}
+
+	return nil, nil, errors.New(fmt.Sprintf("Unsupported output format '%s'", format))
+}
+
+// Parses lines of 'KEY=value' pairs. Lines may start with 'export'. Values may be single-quoted
+// (taken literally), double-quoted (with escape sequences) or unquoted (with trailing comments
+// stripped).
+func parseDotenv(contents []byte) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" {
+			return nil, errors.New(fmt.Sprintf("Line %d isn't of the form KEY=value: %s", lineNum, line))
+		}
+
+		value := strings.TrimSpace(parts[1])
+		switch {
+		case len(value) > 1 && strings.HasPrefix(value, "'") && strings.HasSuffix(value, "'"):
+			value = value[1 : len(value)-1]
+		case len(value) > 1 && strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\""):
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Error parsing the value on line %d", lineNum)
+			}
+			value = unquoted
+		default:
+			if index := strings.Index(value, " #"); index >= 0 {
+				value = strings.TrimSpace(value[:index])
+			}
+		}
+
+		result[key] = value
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return result, nil
+}
+
+// Parses the output of 'terraform output -json', unwrapping each output from its
+// '{"value": ..., "type": ..., "sensitive": ...}' wrapper. Values of sensitive outputs are returned
+// so they can be masked.
+func parseTerraformOutput(contents []byte) (map[string]interface{}, []interface{}, error) {
+	wrapped := map[string]struct {
+		Value     interface{}     `json:"value"`
+		Type      json.RawMessage `json:"type"`
+		Sensitive bool            `json:"sensitive"`
+	}{}
+
+	err := convert.UnmarshalJson(contents, &wrapped)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "Error parsing terraform outputs. Were they "+
+			"written by 'terraform output -json'?")
+	}
+
+	result := make(map[string]interface{}, len(wrapped))
+	sensitiveValues := make([]interface{}, 0)
+
+	for name, output := range wrapped {
+		if output.Type == nil {
+			return nil, nil, errors.New(fmt.Sprintf("Terraform output '%s' has no type. Were outputs "+
+				"written by 'terraform output -json'?", name))
+		}
+
+		value := convert.JsonNumbers(output.Value)
+		result[name] = value
+		if output.Sensitive {
+			sensitiveValues = append(sensitiveValues, value)
+		}
+	}
+
+	return result, sensitiveValues, nil
+}
+
+// Parses HCL, e.g. terraform tfvars files
+func parseHcl(contents []byte) (interface{}, error) {
+	var parsed interface{}
+	err := hcl.Unmarshal(contents, &parsed)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error parsing HCL")
+	}
+
+	return normaliseHcl(parsed), nil
+}
+
+// The HCL decoder returns objects as lists of maps (since blocks may be repeated). This converts
+// lists containing a single map back to the map.
+func normaliseHcl(data interface{}) interface{} {
+	switch typed := data.(type) {
+	case map[string]interface{}:
+		for key, value := range typed {
+			typed[key] = normaliseHcl(value)
+		}
+		return typed
+	case []map[string]interface{}:
+		if len(typed) == 1 {
+			return normaliseHcl(typed[0])
+		}
+
+		result := make([]interface{}, len(typed))
+		for i, value := range typed {
+			result[i] = normaliseHcl(value)
+		}
+		return result
+	case []interface{}:
+		for i, value := range typed {
+			typed[i] = normaliseHcl(value)
+		}
+		return typed
+	}
+
+	return data
+}
+
+// Parses INI files. Keys before the first section are added at the top level and keys in sections
+// are added to a map for each section. All values are strings.
+func parseIni(contents []byte) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	section := result
+
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			existing, ok := result[name]
+			if !ok {
+				section = map[string]interface{}{}
+				result[name] = section
+				continue
+			}
+
+			section, ok = existing.(map[string]interface{})
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("Section '%s' on line %d has the same name as "+
+					"a key", name, lineNum))
+			}
+			continue
+		}
+
+		index := strings.IndexAny(line, "=:")
+		if index <= 0 {
+			return nil, errors.New(fmt.Sprintf("Line %d isn't a section or of the form "+
+				"key=value: %s", lineNum, line))
+		}
+
+		key := strings.TrimSpace(line[:index])
+		value := strings.TrimSpace(line[index+1:])
+		if len(value) > 1 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		section[key] = value
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return result, nil
+}
+
+// Parses Java properties files. All values are strings.
+func parseProperties(contents []byte) (map[string]interface{}, error) {
+	loader := properties.Loader{Encoding: properties.UTF8, DisableExpansion: true}
+	parsed, err := loader.LoadBytes(contents)
+	if err != nil {
+		return nil, errors.Wrap(err, "Error parsing properties")
+	}
+
+	result := make(map[string]interface{}, parsed.Len())
+	for key, value := range parsed.Map() {
+		result[key] = value
+	}
+
+	return result, nil
+}
+
+// Returns the value to publish for a missing output during a dry run. This is its `dry_run_value`
+// if it has one, otherwise a value generated from its schema.
+func dryRunOutput(output structs.Output) (interface{}, error) {
+	value := output.DryRunValue
+	if value == nil {
+		generated, err := schema.Generate(output.Schema)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid output schema")
+		}
+		return generated, nil
+	}
+
+	value = convert.StringifyKeys(value)
+
+	err := validateOutput(output, value)
+	if err != nil {
+		return nil, errors.Wrap(err, "The dry_run_value doesn't conform to the output's schema")
+	}
+
+	return value, nil
+}
+
+// Validates the data published for an output against its schema, if it has one
+func validateOutput(output structs.Output, value interface{}) error {
+	if len(output.Schema) == 0 {
+		return nil
+	}
+
+	validationErrors, err := schema.Validate(output.Schema, convert.StringifyKeys(value))
+	if err != nil {
+		return errors.Wrap(err, "Invalid output schema")
+	}
+
+	if len(validationErrors) == 0 {
+		return nil
+	}
+
+	messages := make([]string, 0, len(validationErrors))
+	for _, validationError := range validationErrors {
+		messages = append(messages, fmt.Sprintf("  * %s", validationError.Error()))
+	}
+
+	return errors.New(fmt.Sprintf("Invalid values:\n%s", strings.Join(messages, "\n")))
+}
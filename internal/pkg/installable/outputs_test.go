@@ -0,0 +1,311 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package installable
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
+	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		contents string
+		expected interface{}
+	}{
+		{
+			name:     "json",
+			format:   "json",
+			contents: `{"a": {"b": [1, "x"]}, "account": 123456789012, "ratio": 0.5}`,
+			expected: map[string]interface{}{
+				"a":       map[string]interface{}{"b": []interface{}{int64(1), "x"}},
+				"account": int64(123456789012),
+				"ratio":   0.5,
+			},
+		},
+		{
+			name:     "yaml",
+			format:   "YAML",
+			contents: "a: 1\nb: x\n",
+			expected: map[string]interface{}{"a": 1, "b": "x"},
+		},
+		{
+			name:     "text",
+			format:   "text",
+			contents: "some text\n",
+			expected: "some text\n",
+		},
+		{
+			name:   "dotenv",
+			format: "dotenv",
+			contents: `# a comment
+REGION=eu-west-1
+export BUCKET = my-bucket  # trailing comment
+SINGLE='literal \n # value'
+DOUBLE="line1\nline2"
+EMPTY=
+`,
+			expected: map[string]interface{}{
+				"REGION": "eu-west-1",
+				"BUCKET": "my-bucket",
+				"SINGLE": "literal \\n # value",
+				"DOUBLE": "line1\nline2",
+				"EMPTY":  "",
+			},
+		},
+		{
+			name:   "terraform",
+			format: "terraform",
+			contents: `{
+  "vpc_id": {"sensitive": false, "type": "string", "value": "vpc-123"},
+  "subnets": {"sensitive": false, "type": ["list", "string"], "value": ["a", "b"]},
+  "account_id": {"sensitive": false, "type": "number", "value": 123456789012}
+}`,
+			expected: map[string]interface{}{
+				"vpc_id":     "vpc-123",
+				"subnets":    []interface{}{"a", "b"},
+				"account_id": int64(123456789012),
+			},
+		},
+		{
+			name:   "tfvars",
+			format: "tfvars",
+			contents: `region = "eu-west-1"
+count = 3
+zones = ["a", "b"]
+tags = {
+  Name = "x"
+}
+`,
+			expected: map[string]interface{}{
+				"region": "eu-west-1",
+				"count":  3,
+				"zones":  []interface{}{"a", "b"},
+				"tags":   map[string]interface{}{"Name": "x"},
+			},
+		},
+		{
+			name:   "ini",
+			format: "ini",
+			contents: `; a comment
+top = level
+[database]
+host = db.example.com
+port: 5432
+name = "app"
+`,
+			expected: map[string]interface{}{
+				"top": "level",
+				"database": map[string]interface{}{
+					"host": "db.example.com",
+					"port": "5432",
+					"name": "app",
+				},
+			},
+		},
+		{
+			name:   "properties",
+			format: "properties",
+			contents: `# a comment
+db.host=db.example.com
+db.url = jdbc:${db.host}
+greeting: hello \
+  world
+`,
+			expected: map[string]interface{}{
+				"db.host":  "db.example.com",
+				"db.url":   "jdbc:${db.host}",
+				"greeting": "hello world",
+			},
+		},
+		{
+			name:     "base64",
+			format:   "base64",
+			contents: "c2VjcmV0IHZhbHVl\n",
+			expected: "secret value",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, _, err := parseOutput(test.format, []byte(test.contents))
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestParseOutputErrors(t *testing.T) {
+	tests := []struct {
+		format   string
+		contents string
+	}{
+		{"unknown", "x"},
+		{"dotenv", "NOT_AN_ASSIGNMENT"},
+		{"terraform", `{"vpc_id": "vpc-123"}`},
+		{"ini", "[section]\nnot a pair"},
+		{"base64", "not base64!"},
+		{"tfvars", "region = ["},
+	}
+
+	for _, test := range tests {
+		_, _, err := parseOutput(test.format, []byte(test.contents))
+		assert.NotNil(t, err, test.format)
+	}
+}
+
+func TestGetTerraformOutputsMasksSensitiveValues(t *testing.T) {
+	defer redact.Reset()
+
+	tempDir, err := ioutil.TempDir("", "outputs-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	err = ioutil.WriteFile(filepath.Join(tempDir, "outputs.json"), []byte(`{
+  "endpoint": {"sensitive": false, "type": "string", "value": "db.example.com"},
+  "db_admin_secret": {"sensitive": true, "type": "string", "value": "terraform-sensitive-value"}
+}`), 0644)
+	assert.Nil(t, err)
+
+	installableObj, err := New("manifest1", []structs.KappDescriptorWithMaps{
+		{
+			Id: "kappA",
+			Outputs: map[string]structs.Output{
+				"tf": {Id: "tf", Path: "outputs.json", Format: "terraform"},
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	kappObj := installableObj.(*Kapp)
+	kappObj.configFileDir = tempDir
+
+	outputs, err := kappObj.GetOutputs(false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"endpoint":        "db.example.com",
+		"db_admin_secret": "terraform-sensitive-value",
+	}, outputs["tf"])
+
+	assert.Equal(t, "******", redact.String("terraform-sensitive-value"))
+	assert.Equal(t, "db.example.com", redact.String("db.example.com"))
+}
+
+// Returns a kapp in a temporary directory with the given outputs
+func newKappWithOutputs(t *testing.T, outputs map[string]structs.Output) (*Kapp, string) {
+	tempDir, err := ioutil.TempDir("", "outputs-")
+	assert.Nil(t, err)
+
+	installableObj, err := New("manifest1", []structs.KappDescriptorWithMaps{
+		{
+			Id:      "kappA",
+			Outputs: outputs,
+		},
+	})
+	assert.Nil(t, err)
+
+	kappObj := installableObj.(*Kapp)
+	kappObj.configFileDir = tempDir
+
+	return kappObj, tempDir
+}
+
+func TestGetOutputsDryRun(t *testing.T) {
+	vpcSchema := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"id"},
+		"properties": map[string]interface{}{
+			"id":      map[string]interface{}{"type": "string", "examples": []interface{}{"vpc-123"}},
+			"subnets": map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+		},
+	}
+
+	kappObj, tempDir := newKappWithOutputs(t, map[string]structs.Output{
+		"explicit": {
+			Id:          "explicit",
+			Path:        "explicit.json",
+			Format:      "json",
+			Schema:      vpcSchema,
+			DryRunValue: map[interface{}]interface{}{"id": "vpc-dry-run"},
+		},
+		"generated": {Id: "generated", Path: "generated.json", Format: "json", Schema: vpcSchema},
+		"plain":     {Id: "plain", Path: "plain.json", Format: "json"},
+	})
+	defer os.RemoveAll(tempDir)
+
+	outputs, err := kappObj.GetOutputs(true, true)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"explicit":  map[string]interface{}{"id": "vpc-dry-run"},
+		"generated": map[string]interface{}{"id": "vpc-123", "subnets": []interface{}{"dry-run"}},
+		"plain":     nil,
+	}, outputs)
+
+	// synthetic values are only used during dry runs
+	outputs, err = kappObj.GetOutputs(true, false)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"explicit":  nil,
+		"generated": nil,
+		"plain":     nil,
+	}, outputs)
+
+	// outputs that exist are used during dry runs
+	err = ioutil.WriteFile(filepath.Join(tempDir, "explicit.json"), []byte(`{"id": "vpc-real"}`), 0644)
+	assert.Nil(t, err)
+	outputs, err = kappObj.GetOutputs(true, true)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "vpc-real"}, outputs["explicit"])
+}
+
+func TestGetOutputsValidatesSchema(t *testing.T) {
+	kappObj, tempDir := newKappWithOutputs(t, map[string]structs.Output{
+		"vpc": {
+			Id:     "vpc",
+			Path:   "vpc.json",
+			Format: "json",
+			Schema: map[string]interface{}{
+				"type":     "object",
+				"required": []interface{}{"id"},
+			},
+		},
+	})
+	defer os.RemoveAll(tempDir)
+
+	err := ioutil.WriteFile(filepath.Join(tempDir, "vpc.json"), []byte(`{"cidr": "10.0.0.0/16"}`), 0644)
+	assert.Nil(t, err)
+
+	_, err = kappObj.GetOutputs(false, false)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "Output 'vpc' of kapp 'manifest1:kappA' doesn't conform to its schema")
+		assert.Contains(t, err.Error(), "id")
+	}
+}
+
+func TestDryRunValueMustConformToSchema(t *testing.T) {
+	_, err := dryRunOutput(structs.Output{
+		Schema:      map[string]interface{}{"type": "string"},
+		DryRunValue: 5,
+	})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package installable
+
+import (
+	"fmt"
+	"github.com/itchyny/gojq"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/convert"
+	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"k8s.io/client-go/util/jsonpath"
+	"sort"
+	"strings"
+)
+
+// select expressions starting with this are JSONPath expressions. Others are jq expressions.
+const jsonPathRoot = "$"
+
+// Returns the data an output's `select` expression selects from its parsed contents, with keys
+// renamed according to its `rename` map
+func transformOutput(output structs.Output, data interface{}) (interface{}, error) {
+	var err error
+
+	if output.Select != "" {
+		data, err = selectOutput(output.Select, data)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	if len(output.Rename) > 0 {
+		data, err = renameKeys(data, output.Rename)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return data, nil
+}
+
+// Evaluates a JSONPath (e.g. `$.vpc.id`) or jq (e.g. `.vpc | {id, cidr}`) expression against
+// data. If the expression selects several values a list of them is returned.
+func selectOutput(expression string, data interface{}) (interface{}, error) {
+	// JSONPath and jq both need maps with string keys
+	data = convert.StringifyKeys(data)
+
+	var results []interface{}
+	var err error
+
+	if strings.HasPrefix(strings.TrimSpace(expression), jsonPathRoot) {
+		results, err = selectJsonPath(expression, data)
+	} else {
+		results, err = selectJq(expression, data)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	switch len(results) {
+	case 0:
+		return nil, errors.New(fmt.Sprintf("Expression '%s' didn't select anything", expression))
+	case 1:
+		return results[0], nil
+	}
+
+	return results, nil
+}
+
+// Returns the values a JSONPath expression selects
+func selectJsonPath(expression string, data interface{}) ([]interface{}, error) {
+	parser := jsonpath.New("select")
+	err := parser.Parse(fmt.Sprintf("{%s}", strings.TrimSpace(expression)))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error parsing JSONPath expression '%s'", expression)
+	}
+
+	resultSets, err := parser.FindResults(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error evaluating JSONPath expression '%s'", expression)
+	}
+
+	results := make([]interface{}, 0)
+	for _, resultSet := range resultSets {
+		for _, result := range resultSet {
+			results = append(results, result.Interface())
+		}
+	}
+
+	return results, nil
+}
+
+// Returns the values a jq expression outputs
+func selectJq(expression string, data interface{}) ([]interface{}, error) {
+	query, err := gojq.Parse(expression)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error parsing jq expression '%s'", expression)
+	}
+
+	results := make([]interface{}, 0)
+	iter := query.Run(data)
+	for {
+		result, ok := iter.Next()
+		if !ok {
+			break
+		}
+
+		if err, ok := result.(error); ok {
+			return nil, errors.Wrapf(err, "Error evaluating jq expression '%s'", expression)
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// Returns a copy of a map with keys renamed. The rename map is keyed by current key names.
+func renameKeys(data interface{}, rename map[string]string) (interface{}, error) {
+	dataMap, ok := convert.StringifyKeys(data).(map[string]interface{})
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Only maps can have keys renamed but got a %T", data))
+	}
+
+	oldKeys := make([]string, 0, len(rename))
+	for oldKey := range rename {
+		oldKeys = append(oldKeys, oldKey)
+	}
+	sort.Strings(oldKeys)
+
+	result := make(map[string]interface{}, len(dataMap))
+	for key, value := range dataMap {
+		if _, ok := rename[key]; !ok {
+			result[key] = value
+		}
+	}
+
+	for _, oldKey := range oldKeys {
+		value, ok := dataMap[oldKey]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Can't rename missing key '%s'", oldKey))
+		}
+
+		newKey := rename[oldKey]
+		if _, exists := result[newKey]; exists {
+			return nil, errors.New(fmt.Sprintf("Can't rename key '%s' to '%s' because '%s' "+
+				"already exists", oldKey, newKey, newKey))
+		}
+
+		result[newKey] = value
+	}
+
+	return result, nil
+}
@@ -0,0 +1,190 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package installable
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// data like the outputs of a terraform VPC module
+func vpcOutputs() map[string]interface{} {
+	return map[string]interface{}{
+		"vpc": map[interface{}]interface{}{
+			"id":   "vpc-123",
+			"cidr": "10.0.0.0/16",
+		},
+		"subnets": []interface{}{
+			map[string]interface{}{"id": "subnet-a", "public": true},
+			map[string]interface{}{"id": "subnet-b", "public": false},
+		},
+		"route_tables": []interface{}{"rtb-1", "rtb-2"},
+	}
+}
+
+func TestTransformOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   structs.Output
+		expected interface{}
+	}{
+		{
+			name:     "none",
+			output:   structs.Output{},
+			expected: vpcOutputs(),
+		},
+		{
+			name:     "jsonpath",
+			output:   structs.Output{Select: "$.vpc.id"},
+			expected: "vpc-123",
+		},
+		{
+			name:     "jsonpath_multiple",
+			output:   structs.Output{Select: "$.subnets[*].id"},
+			expected: []interface{}{"subnet-a", "subnet-b"},
+		},
+		{
+			name:     "jq",
+			output:   structs.Output{Select: "{vpc_id: .vpc.id, public_subnets: [.subnets[] | select(.public) | .id]}"},
+			expected: map[string]interface{}{"vpc_id": "vpc-123", "public_subnets": []interface{}{"subnet-a"}},
+		},
+		{
+			name:     "jq_multiple",
+			output:   structs.Output{Select: ".route_tables[]"},
+			expected: []interface{}{"rtb-1", "rtb-2"},
+		},
+		{
+			name: "jsonpath_rename",
+			output: structs.Output{
+				Select: "$.vpc",
+				Rename: map[string]string{"id": "vpc_id", "cidr": "vpc_cidr"},
+			},
+			expected: map[string]interface{}{"vpc_id": "vpc-123", "vpc_cidr": "10.0.0.0/16"},
+		},
+		{
+			name:   "rename",
+			output: structs.Output{Rename: map[string]string{"route_tables": "routes"}},
+			expected: map[string]interface{}{
+				"vpc":     map[string]interface{}{"id": "vpc-123", "cidr": "10.0.0.0/16"},
+				"subnets": vpcOutputs()["subnets"],
+				"routes":  []interface{}{"rtb-1", "rtb-2"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := transformOutput(test.output, vpcOutputs())
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestTransformOutputErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      structs.Output
+		expectedErr string
+	}{
+		{
+			name:        "missing_jsonpath",
+			output:      structs.Output{Select: "$.missing"},
+			expectedErr: "Error evaluating JSONPath expression '$.missing'",
+		},
+		{
+			name:        "invalid_jq",
+			output:      structs.Output{Select: ".vpc | {"},
+			expectedErr: "Error parsing jq expression",
+		},
+		{
+			name:        "jq_error",
+			output:      structs.Output{Select: ".vpc.id | error(\"bad\")"},
+			expectedErr: "Error evaluating jq expression",
+		},
+		{
+			name:        "empty",
+			output:      structs.Output{Select: "empty"},
+			expectedErr: "didn't select anything",
+		},
+		{
+			name:        "rename_non_map",
+			output:      structs.Output{Select: "$.vpc.id", Rename: map[string]string{"id": "vpc_id"}},
+			expectedErr: "Only maps can have keys renamed",
+		},
+		{
+			name:        "rename_missing",
+			output:      structs.Output{Rename: map[string]string{"missing": "x"}},
+			expectedErr: "Can't rename missing key 'missing'",
+		},
+		{
+			name:        "rename_clash",
+			output:      structs.Output{Rename: map[string]string{"vpc": "subnets"}},
+			expectedErr: "because 'subnets' already exists",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := transformOutput(test.output, vpcOutputs())
+			if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), test.expectedErr)
+			}
+		})
+	}
+}
+
+func TestGetOutputsSelectsData(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "outputs-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	err = ioutil.WriteFile(filepath.Join(tempDir, "outputs.json"), []byte(`{
+  "vpc_id": {"sensitive": false, "type": "string", "value": "vpc-123"},
+  "subnet_ids": {"sensitive": false, "type": ["list", "string"], "value": ["subnet-a", "subnet-b"]}
+}`), 0644)
+	assert.Nil(t, err)
+
+	installableObj, err := New("manifest1", []structs.KappDescriptorWithMaps{
+		{
+			Id: "kappA",
+			Outputs: map[string]structs.Output{
+				"network": {
+					Id:     "network",
+					Path:   "outputs.json",
+					Format: "terraform",
+					Select: "{vpc_id, subnet: .subnet_ids[0]}",
+					Rename: map[string]string{"vpc_id": "id"},
+				},
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	kappObj := installableObj.(*Kapp)
+	kappObj.configFileDir = tempDir
+
+	outputs, err := kappObj.GetOutputs(false, false)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"network": map[string]interface{}{"id": "vpc-123", "subnet": "subnet-a"},
+	}, outputs)
+}
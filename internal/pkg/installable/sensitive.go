@@ -0,0 +1,149 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package installable
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
+	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+)
+
+// permissions sensitive templates are written with
+const sensitiveFileMode = 0600
+
+// keep track of all sensitive files written by this process so they can be deleted if we're
+// terminated. Files are mapped to the fully-qualified ID of the kapp they were rendered for.
+var sensitiveFiles = make(map[string]string)
+var sensitiveFilesMutex sync.Mutex
+
+// Writes a sensitive template readable only by the current user. If a sensitive templates dir is
+// configured (e.g. a tmpfs mount like /dev/shm) the data is written to a file in it and the dest
+// path is a symlink to that file so the data never touches the workspace's disk.
+func writeSensitiveFile(kappId string, destPath string, data []byte) error {
+	// remove any existing file so it's recreated with the right permissions
+	err := removeSensitiveFile(destPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	sensitiveDir := ""
+	if config.CurrentConfig != nil {
+		sensitiveDir = config.CurrentConfig.SensitiveTemplatesDir
+	}
+
+	if sensitiveDir == "" {
+		trackSensitiveFile(kappId, destPath)
+		return writeFile(destPath, data)
+	}
+
+	tempFile, err := ioutil.TempFile(sensitiveDir, "sugarkube-")
+	if err != nil {
+		return errors.Wrapf(err, "Error creating a file for a sensitive template in '%s'", sensitiveDir)
+	}
+	trackSensitiveFile(kappId, tempFile.Name())
+
+	err = tempFile.Close()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = writeFile(tempFile.Name(), data)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	trackSensitiveFile(kappId, destPath)
+	err = os.Symlink(tempFile.Name(), destPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	log.Logger.Debugf("Wrote sensitive template '%s' to '%s'", destPath, tempFile.Name())
+
+	return nil
+}
+
+// Writes data to a file with permissions for sensitive data
+func writeFile(path string, data []byte) error {
+	err := ioutil.WriteFile(path, data, sensitiveFileMode)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// the mode passed to WriteFile is subject to the umask
+	return errors.WithStack(os.Chmod(path, sensitiveFileMode))
+}
+
+func trackSensitiveFile(kappId string, path string) {
+	sensitiveFilesMutex.Lock()
+	defer sensitiveFilesMutex.Unlock()
+	sensitiveFiles[path] = kappId
+}
+
+// Deletes a file or symlink if it exists
+func removeSensitiveFile(path string) error {
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+
+	sensitiveFilesMutex.Lock()
+	delete(sensitiveFiles, path)
+	sensitiveFilesMutex.Unlock()
+
+	return nil
+}
+
+// Deletes sensitive files written for the kapp with the given fully-qualified ID, or for all kapps
+// if it's empty
+func deleteSensitiveFiles(kappId string) error {
+	sensitiveFilesMutex.Lock()
+	paths := make([]string, 0)
+	for path, owner := range sensitiveFiles {
+		if kappId == "" || owner == kappId {
+			paths = append(paths, path)
+		}
+	}
+	sensitiveFilesMutex.Unlock()
+
+	sort.Strings(paths)
+
+	var firstErr error
+
+	// try to delete all files even if some can't be deleted
+	for _, path := range paths {
+		log.Logger.Infof("Deleting sensitive file '%s'", path)
+		err := removeSensitiveFile(path)
+		if err != nil {
+			log.Logger.Warnf("Error deleting sensitive file '%s': %v", path, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// Deletes all sensitive templates rendered by this process, e.g. when we're terminated by a signal
+func DeleteAllSensitiveTemplates() {
+	_ = deleteSensitiveFiles("")
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package installable
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
+	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Returns a kapp in a temporary directory with a normal and a sensitive template
+func newKappWithSensitiveTemplate(t *testing.T) (*Kapp, string) {
+	tempDir, err := ioutil.TempDir("", "sensitive-")
+	assert.Nil(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(tempDir, "config.tpl"), []byte("region: {{ .region }}"), 0644)
+	assert.Nil(t, err)
+	err = ioutil.WriteFile(filepath.Join(tempDir, "secret.tpl"), []byte("password: {{ .password }}"), 0644)
+	assert.Nil(t, err)
+
+	installableObj, err := New("manifest1", []structs.KappDescriptorWithMaps{
+		{
+			Id: "kappA",
+			KappConfig: structs.KappConfig{
+				Templates: map[string]structs.Template{
+					"config": {Source: "config.tpl", Dest: "config.yaml"},
+					"secret": {Source: "secret.tpl", Dest: "secret.yaml", Sensitive: true},
+				},
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	kappObj := installableObj.(*Kapp)
+	kappObj.kappCacheDir = tempDir
+	kappObj.configFileDir = tempDir
+
+	return kappObj, tempDir
+}
+
+func TestRenderSensitiveTemplates(t *testing.T) {
+	kappObj, tempDir := newKappWithSensitiveTemplate(t)
+	defer os.RemoveAll(tempDir)
+
+	templateVars := map[string]interface{}{"region": "eu-west-1", "password": "hunter2"}
+	secretPath := filepath.Join(tempDir, "secret.yaml")
+
+	// sensitive templates aren't rendered with the others but their paths are known
+	err := kappObj.RenderTemplates(templateVars, nil, false)
+	assert.Nil(t, err)
+
+	assert.FileExists(t, filepath.Join(tempDir, "config.yaml"))
+	_, err = os.Stat(secretPath)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, secretPath, kappObj.GetDescriptor().Templates["secret"].RenderedPath)
+
+	err = kappObj.RenderSensitiveTemplates(templateVars, nil, false)
+	assert.Nil(t, err)
+
+	info, err := os.Stat(secretPath)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	contents, err := ioutil.ReadFile(secretPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "password: hunter2", string(contents))
+
+	err = kappObj.DeleteSensitiveTemplates()
+	assert.Nil(t, err)
+
+	_, err = os.Stat(secretPath)
+	assert.True(t, os.IsNotExist(err))
+	assert.FileExists(t, filepath.Join(tempDir, "config.yaml"))
+}
+
+func TestRenderSensitiveTemplatesToDir(t *testing.T) {
+	kappObj, tempDir := newKappWithSensitiveTemplate(t)
+	defer os.RemoveAll(tempDir)
+
+	sensitiveDir, err := ioutil.TempDir("", "sensitive-dir-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(sensitiveDir)
+
+	previousConfig := config.CurrentConfig
+	config.CurrentConfig = &config.Config{SensitiveTemplatesDir: sensitiveDir}
+	defer func() { config.CurrentConfig = previousConfig }()
+
+	err = kappObj.RenderTemplates(map[string]interface{}{"region": "eu-west-1"}, nil, false)
+	assert.Nil(t, err)
+
+	err = kappObj.RenderSensitiveTemplates(map[string]interface{}{"password": "hunter2"}, nil, false)
+	assert.Nil(t, err)
+
+	secretPath := filepath.Join(tempDir, "secret.yaml")
+	target, err := os.Readlink(secretPath)
+	assert.Nil(t, err)
+	assert.Equal(t, sensitiveDir, filepath.Dir(target))
+
+	contents, err := ioutil.ReadFile(secretPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "password: hunter2", string(contents))
+
+	// files for all kapps are deleted when we're terminated
+	DeleteAllSensitiveTemplates()
+
+	_, err = os.Lstat(secretPath)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(target)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/sugarkube/sugarkube/internal/pkg/acquirer"
 	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"github.com/sugarkube/sugarkube/internal/pkg/vars"
 )
 
 type MockInstallable struct {
@@ -67,12 +68,21 @@ func (m MockInstallable) GetEnvVars() map[string]interface{} {
 func (m MockInstallable) Vars(stack interfaces.IStack) (map[string]interface{}, error) {
 	return nil, nil
 }
+func (m MockInstallable) ExplainVars(stack interfaces.IStack, provenance vars.Provenance) (map[string]interface{}, error) {
+	return nil, nil
+}
 func (m MockInstallable) AddDescriptor(config structs.KappDescriptorWithMaps, prepend bool) error {
 	return nil
 }
 func (m MockInstallable) RenderTemplates(templateVars map[string]interface{}, stackConfig interfaces.IStackConfig, dryRun bool) error {
 	return nil
 }
+func (m MockInstallable) RenderSensitiveTemplates(templateVars map[string]interface{}, stackConfig interfaces.IStackConfig, dryRun bool) error {
+	return nil
+}
+func (m MockInstallable) DeleteSensitiveTemplates() error {
+	return nil
+}
 func (m MockInstallable) GetOutputs(ignoreMissing bool, dryRun bool) (map[string]interface{}, error) {
 	return nil, nil
 }
@@ -305,15 +305,194 @@ func mergeRunUnits(runUnits map[string]structs.RunUnit, action string,
 	return steps, nil
 }
 
-func (r RunUnitInstaller) getRunSteps(installableObj interfaces.IInstallable,
-	stackObj interfaces.IStack, action string, dryRun bool) ([]structs.RunStep, error) {
+// names of run unit fields containing run steps
+var runUnitActions = []string{constants.PlanInstall, constants.ApplyInstall, constants.PlanDelete,
+	constants.ApplyDelete, constants.Output, constants.Clean}
+
+// Returns the action whose run steps `call` refers to. See `Call` for the syntax.
+func CallAction(installableObj interfaces.IInstallable, call string) (string, error) {
+	parts := strings.Split(call, constants.CallSeparator)
+	if len(parts) > 2 {
+		return "", fmt.Errorf("Invalid run step reference '%s'. It should be formatted "+
+			"'action', 'action/step' or 'run-unit/step'", call)
+	}
 
-	installerVars := map[string]interface{}{
+	action := parts[0]
+
+	if !utils.InStringArray(runUnitActions, action) {
+		if len(parts) != 2 {
+			return "", fmt.Errorf("'%s' isn't an action. A step must be given when referring to a "+
+				"run unit, e.g. '%s/step'", action, action)
+		}
+
+		// find which action of the run unit contains the step
+		runUnit, ok := installableObj.GetDescriptor().RunUnits[action]
+		if !ok {
+			return "", fmt.Errorf("Kapp '%s' has no action or run unit called '%s'",
+				installableObj.FullyQualifiedId(), action)
+		}
+
+		action = ""
+		for _, candidate := range runUnitActions {
+			if step, _ := findStepInRunUnits(map[string]structs.RunUnit{parts[0]: runUnit},
+				candidate, parts[1]); step != nil {
+				action = candidate
+				break
+			}
+		}
+
+		if action == "" {
+			return "", fmt.Errorf("Unable to find run step '%s' in run unit '%s' of kapp '%s'",
+				parts[1], parts[0], installableObj.FullyQualifiedId())
+		}
+	}
+
+	return action, nil
+}
+
+// Returns the extra vars run steps for an action are templated with
+func RunStepVars(action string, dryRun bool) map[string]interface{} {
+	return map[string]interface{}{
 		"action":  action,
 		"dry-run": dryRun,
 	}
+}
+
+// Returns the templated run steps referred to by `call`, which uses the same syntax as the `call` field
+// of run steps, i.e. either an action (e.g. 'output') or an action and a step (e.g. 'output/tf-output').
+// A run unit can also be given instead of an action (e.g. 'terraform/tf-output') in which case each
+// of the run unit's actions is searched for the step.
+func (r RunUnitInstaller) Call(installableObj interfaces.IInstallable, stackObj interfaces.IStack,
+	call string, dryRun bool) ([]structs.RunStep, error) {
+
+	action, err := CallAction(installableObj, call)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	parts := strings.Split(call, constants.CallSeparator)
+
+	// this handles conditions on run units and merge priorities
+	if len(parts) == 1 {
+		return r.getRunSteps(installableObj, stackObj, action, dryRun)
+	}
+
+	templatedVars, err := stackObj.GetTemplatedVars(installableObj, RunStepVars(action, dryRun))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	err = installableObj.TemplateDescriptor(templatedVars)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	runUnits := installableObj.GetDescriptor().RunUnits
+
+	// only search the named run unit if one was given
+	if action != parts[0] {
+		runUnits = map[string]structs.RunUnit{parts[0]: runUnits[parts[0]]}
+	}
+
+	callStep := structs.RunStep{
+		Call: strings.Join([]string{action, parts[1]}, constants.CallSeparator),
+	}
+
+	runSteps, err := interpolateCalls([]structs.RunStep{callStep}, runUnits, maxInterpolationRecursions)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	log.Logger.Debugf("Calculated run steps for '%s' on '%s': %#v", call, installableObj.FullyQualifiedId(),
+		runSteps)
+
+	return runSteps, nil
+}
+
+// Returns the working directory and env vars that run steps in the named run unit of a kapp would
+// be executed with. If no run unit is named, env vars from all run units whose conditions are true
+// are merged, and the working directory is only taken from a run unit if there's just one.
+func RunUnitEnvironment(installableObj interfaces.IInstallable, stackObj interfaces.IStack,
+	unitName string, dryRun bool) (string, map[string]string, error) {
+
+	installerVars := map[string]interface{}{
+		"dry-run": dryRun,
+	}
 
 	templatedVars, err := stackObj.GetTemplatedVars(installableObj, installerVars)
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+
+	err = installableObj.TemplateDescriptor(templatedVars)
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+
+	runUnits := installableObj.GetDescriptor().RunUnits
+	selectedUnits := make(map[string]structs.RunUnit)
+
+	if unitName != "" {
+		runUnit, ok := runUnits[unitName]
+		if !ok {
+			return "", nil, fmt.Errorf("Kapp '%s' doesn't have a run unit called '%s'",
+				installableObj.FullyQualifiedId(), unitName)
+		}
+		selectedUnits[unitName] = runUnit
+	} else {
+		for name, runUnit := range runUnits {
+			allOk, err := utils.All(runUnit.Conditions)
+			if err != nil {
+				return "", nil, errors.WithStack(err)
+			}
+
+			if allOk {
+				selectedUnits[name] = runUnit
+			}
+		}
+	}
+
+	names := make([]string, 0)
+	for name := range selectedUnits {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	workingDir := installableObj.GetCacheDir()
+	envVars := make(map[string]string)
+
+	for _, name := range names {
+		runUnit := selectedUnits[name]
+
+		if len(selectedUnits) == 1 && runUnit.WorkingDir != "" {
+			workingDir = runUnit.WorkingDir
+		}
+
+		for k, v := range runUnit.EnvVars {
+			if _, ok := envVars[k]; !ok {
+				envVars[k] = v
+			}
+		}
+	}
+
+	// make sure tools like kubectl talk to the stack's cluster
+	if _, ok := envVars[constants.KubeConfigEnvVar]; !ok {
+		kubeConfig, ok := templatedVars[constants.RegistryKeyKubeConfig]
+		if ok && kubeConfig != nil && kubeConfig != "" {
+			envVars[constants.KubeConfigEnvVar] = fmt.Sprintf("%v", kubeConfig)
+		}
+	}
+
+	log.Logger.Debugf("Run units %s for kapp '%s' have working dir '%s' and env vars: %#v",
+		strings.Join(names, ", "), installableObj.FullyQualifiedId(), workingDir, envVars)
+
+	return workingDir, envVars, nil
+}
+
+func (r RunUnitInstaller) getRunSteps(installableObj interfaces.IInstallable,
+	stackObj interfaces.IStack, action string, dryRun bool) ([]structs.RunStep, error) {
+
+	templatedVars, err := stackObj.GetTemplatedVars(installableObj, RunStepVars(action, dryRun))
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
@@ -509,3 +509,85 @@ func TestMergedRunStepsForKapp(t *testing.T) {
 	}
 	assert.Equal(t, expectedCleanRunStepNames, cleanRunStepNames, "Unexpected clean steps")
 }
+
+func TestRunUnitEnvironment(t *testing.T) {
+	configFile := path.Join(testDir, "test-sugarkube-conf.yaml")
+	config.ViperConfig.SetConfigFile(configFile)
+
+	err := config.Load(config.ViperConfig)
+	assert.Nil(t, err)
+
+	kapp, err := installable.New("sample-manifest", []structs.KappDescriptorWithMaps{{Id: "sample-kapp"}})
+	assert.Nil(t, err)
+
+	err = kapp.LoadConfigFile(path.Join(testDir, "sample-workspace"))
+	assert.Nil(t, err)
+
+	stackObj := mock.GetMockStack(t, testDir, "large", "", "local",
+		"minikube", "local", "large", "fake-region", []string{"./stacks/"})
+
+	// a single run unit
+	workingDir, envVars, err := RunUnitEnvironment(kapp, stackObj, "proga", true)
+	assert.Nil(t, err)
+	assert.Equal(t, "/tmp", workingDir)
+	// env vars from the config file are merged in too (viper lowercases their keys)
+	assert.Equal(t, map[string]string{"user": "sk", "FOOD": "carrots"}, envVars)
+
+	// all run units are merged, so the working dir is the kapp's cache dir
+	workingDir, envVars, err = RunUnitEnvironment(kapp, stackObj, "", true)
+	assert.Nil(t, err)
+	assert.Equal(t, kapp.GetCacheDir(), workingDir)
+	assert.Equal(t, map[string]string{"user": "sk", "FOOD": "carrots"}, envVars)
+
+	_, _, err = RunUnitEnvironment(kapp, stackObj, "missing", true)
+	assert.NotNil(t, err)
+}
+
+func TestCall(t *testing.T) {
+	configFile := path.Join(testDir, "test-sugarkube-conf.yaml")
+	config.ViperConfig.SetConfigFile(configFile)
+
+	err := config.Load(config.ViperConfig)
+	assert.Nil(t, err)
+
+	kapp, err := installable.New("sample-manifest", []structs.KappDescriptorWithMaps{{Id: "sample-kapp"}})
+	assert.Nil(t, err)
+
+	err = kapp.LoadConfigFile(path.Join(testDir, "sample-workspace"))
+	assert.Nil(t, err)
+
+	stackObj := mock.GetMockStack(t, testDir, "large", "", "local",
+		"minikube", "local", "large", "fake-region", []string{"./stacks/"})
+
+	installerImpl := RunUnitInstaller{}
+
+	tests := []struct {
+		call          string
+		expectedNames []string
+		expectedError bool
+	}{
+		{call: "plan_install", expectedNames: []string{"print-yo", "print-yes", "last-one"}},
+		{call: "plan_install/print-yes", expectedNames: []string{"print-yes"}},
+		{call: "proga/print-yo", expectedNames: []string{"print-yo"}},
+		{call: "prog2/do-stuff-first", expectedNames: []string{"do-stuff-first"}},
+		{call: "proga/missing", expectedError: true},
+		{call: "proga", expectedError: true},
+		{call: "missing/print-yo", expectedError: true},
+		{call: "plan_install/print-yo/extra", expectedError: true},
+	}
+
+	for _, test := range tests {
+		runSteps, err := installerImpl.Call(kapp, stackObj, test.call, true)
+		if test.expectedError {
+			assert.NotNil(t, err, "Expected an error for '%s'", test.call)
+			continue
+		}
+
+		assert.Nil(t, err)
+		names := make([]string, 0)
+		for _, step := range runSteps {
+			names = append(names, step.Name)
+		}
+		assert.Equal(t, test.expectedNames, names, "Unexpected run steps for '%s'", test.call)
+	}
+}
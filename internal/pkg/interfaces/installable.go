@@ -19,6 +19,7 @@ package interfaces
 import (
 	"github.com/sugarkube/sugarkube/internal/pkg/acquirer"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"github.com/sugarkube/sugarkube/internal/pkg/vars"
 )
 
 // this encapsulates different package formats that sugarkube can install in
@@ -39,9 +40,13 @@ type IInstallable interface {
 	Acquirers() (map[string]acquirer.Acquirer, error)
 	TemplateDescriptor(templateVars map[string]interface{}) error
 	Vars(stack IStack) (map[string]interface{}, error)
+	ExplainVars(stack IStack, provenance vars.Provenance) (map[string]interface{}, error)
 	AddDescriptor(config structs.KappDescriptorWithMaps, prepend bool) error
 	RenderTemplates(templateVars map[string]interface{}, stackConfig IStackConfig,
 		dryRun bool) error
+	RenderSensitiveTemplates(templateVars map[string]interface{}, stackConfig IStackConfig,
+		dryRun bool) error
+	DeleteSensitiveTemplates() error
 	GetOutputs(ignoreMissing bool, dryRun bool) (map[string]interface{}, error)
 	HasActions() bool
 	HasOutputs() bool
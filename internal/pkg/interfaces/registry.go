@@ -16,10 +16,32 @@
 
 package interfaces
 
+import "time"
+
+// Records what set a registry key and when. Kapp is empty for values that weren't set from a
+// kapp's outputs (e.g. values set with `registry set`).
+type RegistrySource struct {
+	Kapp string    `yaml:"kapp,omitempty" json:"kapp,omitempty"`
+	Time time.Time `yaml:"time" json:"time"`
+	// values set during dry runs may be synthetic so they're never persisted
+	DryRun bool `yaml:"-" json:"-"`
+}
+
+// A change to a key in a registry
+type RegistryEvent struct {
+	Key     string
+	Value   interface{}
+	Source  RegistrySource
+	Deleted bool
+}
+
 type IRegistry interface {
 	Set(key string, value interface{}) error
+	SetWithSource(key string, value interface{}, source RegistrySource) error
+	Sources() map[string]RegistrySource
 	Get(key string) (interface{}, bool)
 	Delete(key string)
 	AsMap() map[string]interface{}
 	Copy() (IRegistry, error)
+	Subscribe(listener func(event RegistryEvent)) (unsubscribe func())
 }
@@ -16,6 +16,8 @@
 
 package interfaces
 
+import "github.com/sugarkube/sugarkube/internal/pkg/vars"
+
 type IClusterStatus interface {
 	IsOnline() bool
 	SetIsOnline(bool)
@@ -40,6 +42,10 @@ type IStackConfig interface {
 	SetOnlineTimeout(timeout uint32)
 	GetProviderVarsDirs() []string
 	KappVarsDirs() []string
+	KappVarOverrides(fullyQualifiedId string) map[string]interface{}
+	KappVarEnvOverrides(fullyQualifiedId string) []vars.EnvOverride
+	StackVarEnvOverrides() []vars.EnvOverride
+	RefreshOutputs() bool
 	TemplateDirs() []string
 	GetDir() string
 	Manifests() []IManifest
@@ -56,6 +62,10 @@ type IStack interface {
 	GetRegistry() IRegistry
 	GetTemplatedVars(installableObj IInstallable,
 		extraVars map[string]interface{}) (map[string]interface{}, error)
+	ExplainVars(installableObj IInstallable) (map[string]interface{}, vars.Provenance, error)
+	ValidateVars(installableObj IInstallable, templatedVars map[string]interface{}) error
 	RefreshProviderVars() error
 	LoadInstallables(workspaceDir string) error
+	PersistRegistry(workspaceDir string) error
+	SaveRegistry() error
 }
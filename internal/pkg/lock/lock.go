@@ -0,0 +1,425 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lock
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/program"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// locks are stored in this directory relative to the workspace (or other) directory being locked
+const lockDir = ".sugarkube/locks"
+const lockFileExtension = ".lock"
+
+// how often to retry acquiring a lock when waiting for it
+var pollInterval = time.Second
+
+// Details of who holds a lock
+type Holder struct {
+	Stack    string    `yaml:"stack"`
+	User     string    `yaml:"user"`
+	Host     string    `yaml:"host"`
+	Pid      int       `yaml:"pid"`
+	Command  string    `yaml:"command"`
+	Acquired time.Time `yaml:"acquired"`
+}
+
+func (h Holder) String() string {
+	return fmt.Sprintf("%s@%s (pid %d) since %s running '%s'", h.User, h.Host, h.Pid,
+		h.Acquired.Format(time.RFC3339), h.Command)
+}
+
+// An advisory lock preventing multiple sugarkube processes mutating the same workspace/stack at once
+type Lock struct {
+	path   string
+	holder Holder
+	remote Remote
+	held   bool
+}
+
+// keep track of all locks held by this process so they can be released if we're terminated
+var heldLocks = make(map[*Lock]bool)
+var heldLocksMutex sync.Mutex
+
+// Returns the path to the lock file for a stack in the given directory
+func Path(dir string, stackName string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return filepath.Join(absDir, lockDir, stackName+lockFileExtension), nil
+}
+
+// Creates a new lock for the named stack in the given directory. If remote is not nil it'll be
+// acquired after the local lock.
+func New(dir string, stackName string, remote Remote) (*Lock, error) {
+	path, err := Path(dir, stackName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return &Lock{
+		path:   path,
+		holder: newHolder(stackName),
+		remote: remote,
+	}, nil
+}
+
+// Creates and acquires a lock for the named stack in the given directory, using a remote lock
+// if one is configured
+func Acquire(dir string, stackName string, wait bool, timeout time.Duration) (*Lock, error) {
+	lockObj, err := New(dir, stackName, remoteFromConfig())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	err = lockObj.Acquire(wait, timeout)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return lockObj, nil
+}
+
+// Acquires the lock. If it's held by another process an error is returned unless `wait` is
+// true in which case we'll poll until the lock is released or the timeout elapses. A timeout
+// of 0 means wait forever.
+func (l *Lock) Acquire(wait bool, timeout time.Duration) error {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	logged := false
+
+	for {
+		acquired, holder, err := l.tryAcquire()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if acquired {
+			break
+		}
+
+		if !wait {
+			return program.SimpleError{Message: fmt.Sprintf("Stack '%s' is locked by %s. Pass --wait "+
+				"to wait for the lock to be released, or if you're sure the holder has died run "+
+				"`sugarkube lock break`. Lock file: %s", l.holder.Stack, holder, l.path)}
+		}
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return program.SimpleError{Message: fmt.Sprintf("Timed out after %s waiting for the lock "+
+				"on stack '%s' held by %s", timeout, l.holder.Stack, holder)}
+		}
+
+		if !logged {
+			log.Logger.Infof("Waiting for lock on stack '%s' held by %s", l.holder.Stack, holder)
+			logged = true
+		}
+
+		time.Sleep(pollInterval)
+	}
+
+	if l.remote != nil {
+		err := l.remote.Acquire(l.holder)
+		if err != nil {
+			// don't keep the local lock if we couldn't get the remote one
+			releaseErr := os.Remove(l.path)
+			if releaseErr != nil {
+				log.Logger.Warnf("Error removing lock file '%s': %v", l.path, releaseErr)
+			}
+			return errors.WithStack(err)
+		}
+	}
+
+	l.held = true
+
+	heldLocksMutex.Lock()
+	heldLocks[l] = true
+	heldLocksMutex.Unlock()
+
+	log.Logger.Debugf("Acquired lock '%s'", l.path)
+
+	return nil
+}
+
+// Tries to create the lock file. If it already exists the current holder is returned. Stale
+// locks held by processes on this host that no longer exist are broken automatically.
+func (l *Lock) tryAcquire() (bool, *Holder, error) {
+	err := os.MkdirAll(filepath.Dir(l.path), 0755)
+	if err != nil {
+		return false, nil, errors.WithStack(err)
+	}
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err == nil {
+		defer file.Close()
+
+		data, err := yaml.Marshal(l.holder)
+		if err != nil {
+			return false, nil, errors.WithStack(err)
+		}
+
+		_, err = file.Write(data)
+		if err != nil {
+			return false, nil, errors.Wrapf(err, "Error writing lock file '%s'", l.path)
+		}
+
+		return true, nil, nil
+	}
+
+	if !os.IsExist(err) {
+		return false, nil, errors.Wrapf(err, "Error creating lock file '%s'", l.path)
+	}
+
+	holder, err := readHolder(l.path)
+	if err != nil {
+		return false, nil, errors.WithStack(err)
+	}
+
+	// the lock may have been released in the meantime
+	if holder == nil {
+		return false, nil, nil
+	}
+
+	if isStale(*holder) {
+		log.Logger.Warnf("Breaking stale lock on stack '%s' held by %s since that process no "+
+			"longer exists", holder.Stack, holder)
+		err = breakStale(l.path, *holder)
+		if err != nil {
+			return false, nil, errors.WithStack(err)
+		}
+		return l.tryAcquire()
+	}
+
+	return false, holder, nil
+}
+
+// Breaks a stale lock. Other processes may be trying to break the same lock, so rather than
+// deleting it (which could delete a lock another process has just taken) we atomically move
+// it aside and only delete it if it's still the stale lock. Otherwise it's put back.
+func breakStale(path string, stale Holder) error {
+	stalePath := fmt.Sprintf("%s.%d.%d", path, os.Getpid(), time.Now().UnixNano())
+
+	err := os.Rename(path, stalePath)
+	if err != nil {
+		// another process got there first
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "Error moving stale lock file '%s'", path)
+	}
+
+	holder, err := readHolder(stalePath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if holder == nil || sameHolder(*holder, stale) {
+		err = os.Remove(stalePath)
+		if err != nil && !os.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+		return nil
+	}
+
+	// we moved a lock another process took after breaking the stale one, so restore it. Linking
+	// fails instead of overwriting a lock that's been taken again since.
+	log.Logger.Debugf("Lock '%s' was taken by %s while breaking it, restoring it", path, holder)
+	err = os.Link(stalePath, path)
+	if err != nil {
+		return errors.Wrapf(err, "Error restoring lock file '%s' held by %s", path, holder)
+	}
+
+	err = os.Remove(stalePath)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// Returns whether two holders are the same
+func sameHolder(a Holder, b Holder) bool {
+	return a.Stack == b.Stack && a.User == b.User && a.Host == b.Host && a.Pid == b.Pid &&
+		a.Command == b.Command && a.Acquired.Equal(b.Acquired)
+}
+
+// Releases the lock. It's safe to call this on a nil lock or one that isn't held.
+func (l *Lock) Release() error {
+	if l == nil || !l.held {
+		return nil
+	}
+
+	heldLocksMutex.Lock()
+	delete(heldLocks, l)
+	heldLocksMutex.Unlock()
+
+	l.held = false
+
+	if l.remote != nil {
+		err := l.remote.Release(l.holder)
+		if err != nil {
+			log.Logger.Warnf("Error releasing remote lock for stack '%s': %v", l.holder.Stack, err)
+		}
+	}
+
+	err := os.Remove(l.path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Logger.Warnf("Error removing lock file '%s': %v", l.path, err)
+		return errors.WithStack(err)
+	}
+
+	log.Logger.Debugf("Released lock '%s'", l.path)
+
+	return nil
+}
+
+// Releases all locks held by this process, e.g. when we're terminated by a signal
+func ReleaseAll() {
+	heldLocksMutex.Lock()
+	locks := make([]*Lock, 0, len(heldLocks))
+	for lockObj := range heldLocks {
+		locks = append(locks, lockObj)
+	}
+	heldLocksMutex.Unlock()
+
+	for _, lockObj := range locks {
+		_ = lockObj.Release()
+	}
+}
+
+// Returns details of the holder of the lock for the named stack in the given directory, or nil
+// if it isn't locked
+func Status(dir string, stackName string) (*Holder, error) {
+	path, err := Path(dir, stackName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	holder, err := readHolder(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return holder, nil
+}
+
+// Forcibly removes the lock for the named stack in the given directory, returning details of the
+// previous holder (or nil if it wasn't locked). Any configured remote lock is released too.
+func Break(dir string, stackName string) (*Holder, error) {
+	path, err := Path(dir, stackName)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	holder, err := readHolder(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.WithStack(err)
+	}
+
+	remote := remoteFromConfig()
+	if remote != nil {
+		remoteHolder := newHolder(stackName)
+		if holder != nil {
+			remoteHolder = *holder
+		}
+		err = remote.Release(remoteHolder)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return holder, nil
+}
+
+// Reads the holder of a lock file. Returns nil if the file doesn't exist.
+func readHolder(path string) (*Holder, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "Error reading lock file '%s'", path)
+	}
+
+	holder := Holder{}
+	err = yaml.Unmarshal(data, &holder)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error parsing lock file '%s'", path)
+	}
+
+	return &holder, nil
+}
+
+// Returns details of this process
+func newHolder(stackName string) Holder {
+	username := "unknown"
+	usr, err := user.Current()
+	if err == nil {
+		username = usr.Username
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return Holder{
+		Stack:    stackName,
+		User:     username,
+		Host:     hostname,
+		Pid:      os.Getpid(),
+		Command:  strings.Join(os.Args, " "),
+		Acquired: time.Now().UTC(),
+	}
+}
+
+// A lock is stale if it was taken by a process on this host that's no longer running
+func isStale(holder Holder) bool {
+	hostname, err := os.Hostname()
+	if err != nil || hostname != holder.Host || holder.Pid <= 0 {
+		return false
+	}
+
+	process, err := os.FindProcess(holder.Pid)
+	if err != nil {
+		return true
+	}
+
+	err = process.Signal(syscall.Signal(0))
+	// EPERM means the process exists but is owned by someone else
+	return err != nil && err != syscall.EPERM
+}
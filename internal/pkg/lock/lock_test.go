@@ -0,0 +1,236 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lock
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
+	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/program"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func init() {
+	log.ConfigureLogger("trace", false, os.Stderr)
+	pollInterval = 10 * time.Millisecond
+}
+
+// writes a lock file as if it was held by another process
+func writeLockFile(t *testing.T, dir string, stackName string, holder Holder) {
+	path, err := Path(dir, stackName)
+	assert.Nil(t, err)
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+
+	data, err := yaml.Marshal(holder)
+	assert.Nil(t, err)
+	assert.Nil(t, ioutil.WriteFile(path, data, 0644))
+}
+
+func TestAcquireRelease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sugarkube-lock-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	lockObj, err := Acquire(dir, "test-stack", false, 0)
+	assert.Nil(t, err)
+
+	holder, err := Status(dir, "test-stack")
+	assert.Nil(t, err)
+	assert.NotNil(t, holder)
+	assert.Equal(t, os.Getpid(), holder.Pid)
+	assert.Equal(t, "test-stack", holder.Stack)
+
+	// a different stack in the same workspace isn't locked
+	holder, err = Status(dir, "other-stack")
+	assert.Nil(t, err)
+	assert.Nil(t, holder)
+
+	// a second lock on the same stack fails
+	_, err = Acquire(dir, "test-stack", false, 0)
+	assert.NotNil(t, err)
+
+	assert.Nil(t, lockObj.Release())
+
+	holder, err = Status(dir, "test-stack")
+	assert.Nil(t, err)
+	assert.Nil(t, holder)
+
+	// releasing twice is harmless
+	assert.Nil(t, lockObj.Release())
+}
+
+func TestAcquireHeldElsewhere(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sugarkube-lock-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	writeLockFile(t, dir, "test-stack", Holder{
+		Stack:   "test-stack",
+		User:    "someone",
+		Host:    "another-host.example.com",
+		Pid:     1234,
+		Command: "sugarkube kapps install",
+	})
+
+	_, err = Acquire(dir, "test-stack", false, 0)
+	assert.NotNil(t, err)
+	assert.IsType(t, program.SimpleError{}, errors.Cause(err))
+	assert.Contains(t, err.Error(), "someone@another-host.example.com (pid 1234)")
+
+	start := time.Now()
+	_, err = Acquire(dir, "test-stack", true, 50*time.Millisecond)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Timed out")
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+
+	holder, err := Break(dir, "test-stack")
+	assert.Nil(t, err)
+	assert.Equal(t, "someone", holder.User)
+
+	lockObj, err := Acquire(dir, "test-stack", false, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, lockObj.Release())
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sugarkube-lock-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	writeLockFile(t, dir, "test-stack", Holder{
+		Stack: "test-stack",
+		Host:  "another-host.example.com",
+		Pid:   1234,
+	})
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		_, _ = Break(dir, "test-stack")
+	}()
+
+	lockObj, err := Acquire(dir, "test-stack", true, 5*time.Second)
+	assert.Nil(t, err)
+	assert.Nil(t, lockObj.Release())
+}
+
+func TestStaleLockIsBroken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sugarkube-lock-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	hostname, err := os.Hostname()
+	assert.Nil(t, err)
+
+	// a process that's exited
+	writeLockFile(t, dir, "test-stack", Holder{
+		Stack: "test-stack",
+		Host:  hostname,
+		Pid:   1 << 22,
+	})
+
+	lockObj, err := Acquire(dir, "test-stack", false, 0)
+	assert.Nil(t, err)
+	assert.Nil(t, lockObj.Release())
+}
+
+// if another process broke a stale lock and took it before we could break it, we mustn't delete
+// its lock
+func TestBreakStaleRestoresNewLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sugarkube-lock-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	live := Holder{Stack: "test-stack", Host: "other-host", Pid: 1234, Acquired: time.Now().UTC()}
+	writeLockFile(t, dir, "test-stack", live)
+
+	path, err := Path(dir, "test-stack")
+	assert.Nil(t, err)
+
+	err = breakStale(path, Holder{Stack: "test-stack", Host: "other-host", Pid: 1 << 22})
+	assert.Nil(t, err)
+
+	holder, err := Status(dir, "test-stack")
+	assert.Nil(t, err)
+	assert.NotNil(t, holder)
+	assert.True(t, sameHolder(live, *holder))
+
+	// no files are left behind
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+
+	// but stale locks are removed
+	err = breakStale(path, live)
+	assert.Nil(t, err)
+
+	holder, err = Status(dir, "test-stack")
+	assert.Nil(t, err)
+	assert.Nil(t, holder)
+}
+
+func TestReleaseAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sugarkube-lock-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = Acquire(dir, "stack1", false, 0)
+	assert.Nil(t, err)
+	_, err = Acquire(dir, "stack2", false, 0)
+	assert.Nil(t, err)
+
+	ReleaseAll()
+
+	for _, stackName := range []string{"stack1", "stack2"} {
+		holder, err := Status(dir, stackName)
+		assert.Nil(t, err)
+		assert.Nil(t, holder)
+	}
+}
+
+func TestRemoteLockFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sugarkube-lock-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	lockObj, err := New(dir, "test-stack", &commandRemote{config: config.RemoteLockConfig{
+		Acquire: "false",
+	}})
+	assert.Nil(t, err)
+
+	err = lockObj.Acquire(false, 0)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Failed to acquire the remote lock")
+
+	// the local lock shouldn't be left behind
+	holder, err := Status(dir, "test-stack")
+	assert.Nil(t, err)
+	assert.Nil(t, holder)
+
+	lockObj, err = New(dir, "test-stack", &commandRemote{config: config.RemoteLockConfig{
+		Acquire: "true",
+		Release: "true",
+	}})
+	assert.Nil(t, err)
+	assert.Nil(t, lockObj.Acquire(false, 0))
+	assert.Nil(t, lockObj.Release())
+}
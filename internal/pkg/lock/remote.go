@@ -0,0 +1,98 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package lock
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/mattn/go-shellwords"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
+	"github.com/sugarkube/sugarkube/internal/pkg/program"
+	"github.com/sugarkube/sugarkube/internal/pkg/utils"
+	"strconv"
+)
+
+// A remote lock shared between machines (e.g. a DynamoDB item, consul key, etc.) that's taken in
+// addition to the local lock file
+type Remote interface {
+	Acquire(holder Holder) error
+	Release(holder Holder) error
+}
+
+// A remote lock implemented by shelling out to user-configured commands. Details of the holder
+// are passed to the commands as env vars. The acquire command should exit non-zero if the lock
+// is held by someone else.
+type commandRemote struct {
+	config config.RemoteLockConfig
+}
+
+// Returns the remote lock configured in the sugarkube config file, or nil if there isn't one
+func remoteFromConfig() Remote {
+	if config.CurrentConfig == nil || config.CurrentConfig.Lock.Remote.Acquire == "" {
+		return nil
+	}
+
+	return &commandRemote{config: config.CurrentConfig.Lock.Remote}
+}
+
+func (r commandRemote) Acquire(holder Holder) error {
+	err := r.run(r.config.Acquire, holder)
+	if err != nil {
+		return program.SimpleError{Message: fmt.Sprintf("Failed to acquire the remote lock "+
+			"for stack '%s': %v", holder.Stack, err)}
+	}
+	return nil
+}
+
+func (r commandRemote) Release(holder Holder) error {
+	if r.config.Release == "" {
+		return nil
+	}
+
+	return r.run(r.config.Release, holder)
+}
+
+func (r commandRemote) run(command string, holder Holder) error {
+	args, err := shellwords.Parse(command)
+	if err != nil {
+		return errors.Wrapf(err, "Error parsing remote lock command '%s'", command)
+	}
+
+	if len(args) == 0 {
+		return errors.New("Remote lock command is empty")
+	}
+
+	envVars := map[string]string{
+		"SUGARKUBE_LOCK_STACK":   holder.Stack,
+		"SUGARKUBE_LOCK_USER":    holder.User,
+		"SUGARKUBE_LOCK_HOST":    holder.Host,
+		"SUGARKUBE_LOCK_PID":     strconv.Itoa(holder.Pid),
+		"SUGARKUBE_LOCK_COMMAND": holder.Command,
+		"SUGARKUBE_LOCK_HOLDER":  holder.String(),
+	}
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	err = utils.ExecCommand(args[0], args[1:], envVars, &stdoutBuf, &stderrBuf, "",
+		r.config.Timeout, 0, false)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
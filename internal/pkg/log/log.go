@@ -19,6 +19,7 @@ package log
 import (
 	"github.com/onrik/logrus/filename"
 	"github.com/sirupsen/logrus"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
 	"io"
 	"io/ioutil"
 )
@@ -57,7 +58,7 @@ func newLogger(logLevel string, jsonLogs bool, out io.Writer) *logrus.Logger {
 		}
 	}
 
-	l.Formatter = formatter
+	l.Formatter = redactingFormatter{formatter}
 	l.Out = out
 
 	setLevel(l, logLevel)
@@ -70,6 +71,20 @@ func newLogger(logLevel string, jsonLogs bool, out io.Writer) *logrus.Logger {
 	return l
 }
 
+// Masks sensitive values in log entries
+type redactingFormatter struct {
+	logrus.Formatter
+}
+
+func (f redactingFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	formatted, err := f.Formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(redact.String(string(formatted))), nil
+}
+
 // Set the log level
 func setLevel(l *logrus.Logger, level string) {
 	switch level {
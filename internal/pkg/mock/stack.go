@@ -19,6 +19,7 @@ package mock
 import (
 	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
 	"github.com/sugarkube/sugarkube/internal/pkg/registry"
+	"github.com/sugarkube/sugarkube/internal/pkg/vars"
 	"testing"
 )
 
@@ -79,6 +80,22 @@ func (c Config) KappVarsDirs() []string {
 	return nil
 }
 
+func (c Config) KappVarOverrides(fullyQualifiedId string) map[string]interface{} {
+	return nil
+}
+
+func (c Config) RefreshOutputs() bool {
+	return false
+}
+
+func (c Config) KappVarEnvOverrides(fullyQualifiedId string) []vars.EnvOverride {
+	return nil
+}
+
+func (c Config) StackVarEnvOverrides() []vars.EnvOverride {
+	return nil
+}
+
 func (c Config) TemplateDirs() []string {
 	return nil
 }
@@ -133,6 +150,16 @@ func (m MockStack) GetTemplatedVars(installableObj interfaces.IInstallable,
 	return m.TemplatedVars, nil
 }
 
+func (m MockStack) ExplainVars(installableObj interfaces.IInstallable) (map[string]interface{},
+	vars.Provenance, error) {
+	return m.TemplatedVars, vars.Provenance{}, nil
+}
+
+func (m MockStack) ValidateVars(installableObj interfaces.IInstallable,
+	templatedVars map[string]interface{}) error {
+	return nil
+}
+
 func (m *MockStack) RefreshProviderVars() error {
 	return nil
 }
@@ -141,6 +168,14 @@ func (m *MockStack) LoadInstallables(workspaceDir string) error {
 	return nil
 }
 
+func (m *MockStack) PersistRegistry(workspaceDir string) error {
+	return nil
+}
+
+func (m *MockStack) SaveRegistry() error {
+	return nil
+}
+
 func GetMockStackConfig(t *testing.T, dir string, name string, account string, provider string,
 	provisioner string, profile string, cluster string, region string, providerVarsDirs []string) interfaces.IStackConfig {
 
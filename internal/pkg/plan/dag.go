@@ -23,6 +23,7 @@ import (
 	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
 	"github.com/sugarkube/sugarkube/internal/pkg/printer"
+	"github.com/sugarkube/sugarkube/internal/pkg/registry"
 	"github.com/sugarkube/sugarkube/internal/pkg/stack"
 	"github.com/sugarkube/sugarkube/internal/pkg/utils"
 	"gonum.org/v1/gonum/graph"
@@ -102,6 +103,11 @@ func Create(stackObj interfaces.IStack, selectedInstallableIds []string, include
 		return nil, errors.WithStack(err)
 	}
 
+	err = dag.checkRegistryPaths()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	log.Logger.Debugf("Finished creating DAG")
 
 	return dag, nil
@@ -120,6 +126,12 @@ func build(descriptors map[string]nodeDescriptor, stackObj interfaces.IStack) (*
 	for descriptorId, descriptor := range descriptors {
 		installableObj := descriptor.installableObj
 
+		// find references to other kapps' vars before templating the descriptor resolves them
+		referencedIds, err := stack.ReferencedKapps(stackObj, installableObj)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
 		// template the installable's descriptor
 		templatedVars, err := stackObj.GetTemplatedVars(installableObj, map[string]interface{}{})
 		if err != nil {
@@ -186,10 +198,32 @@ func build(descriptors map[string]nodeDescriptor, stackObj interfaces.IStack) (*
 				graphObj.SetEdge(edge)
 			}
 		}
+
+		// referring to the vars of another kapp implies a dependency on it
+		for _, referencedId := range referencedIds {
+			referencedDescriptor, ok := descriptors[referencedId]
+			if !ok {
+				return nil, fmt.Errorf("descriptor '%s' refers to the vars of a graph "+
+					"descriptor that doesn't exist: %s", descriptorId, referencedId)
+			}
+
+			parentNode := addNode(graphObj, nodesByName, referencedId,
+				referencedDescriptor.installableObj, true)
+
+			if parentNode.node == descriptorNode.node {
+				continue
+			}
+
+			if !graphObj.HasEdgeFromTo(parentNode.ID(), descriptorNode.ID()) {
+				log.Logger.Debugf("Creating implied edge from '%s' to '%s' because it refers "+
+					"to its vars", referencedId, descriptorId)
+				graphObj.SetEdge(graphObj.NewEdge(parentNode, descriptorNode))
+			}
+		}
 	}
 
 	if !isAcyclic(graphObj) {
-		return nil, fmt.Errorf("Cyclical dependencies detected")
+		return nil, fmt.Errorf("Cyclical dependencies detected: %s", describeCycle(graphObj))
 	}
 
 	dag := Dag{
@@ -246,6 +280,119 @@ func isAcyclic(graphObj *simple.DirectedGraph) bool {
 	return err == nil
 }
 
+// Returns a description of a cycle in the graph, e.g. 'a -> b -> a'
+func describeCycle(graphObj *simple.DirectedGraph) string {
+	cycles := topo.DirectedCyclesIn(graphObj)
+	if len(cycles) == 0 {
+		return ""
+	}
+
+	// pick the shortest cycle since it's likely to be the easiest to understand
+	cycle := cycles[0]
+	for _, candidate := range cycles {
+		if len(candidate) < len(cycle) {
+			cycle = candidate
+		}
+	}
+
+	names := make([]string, 0, len(cycle))
+	for _, node := range cycle {
+		names = append(names, node.(NamedNode).name)
+	}
+
+	return strings.Join(names, " -> ")
+}
+
+// A custom registry path an output of a node will be published to
+type publishedPath struct {
+	path     string
+	node     NamedNode
+	outputId string
+}
+
+// Returns an error if outputs declare invalid registry paths, or if kapps that will publish outputs to
+// the same registry path aren't ordered by a dependency, since the value would then depend on which
+// kapp happened to finish last
+func (g *Dag) checkRegistryPaths() error {
+	nodesByName := g.nodesByName()
+
+	nodeNames := make([]string, 0, len(nodesByName))
+	for name := range nodesByName {
+		nodeNames = append(nodeNames, name)
+	}
+	sort.Strings(nodeNames)
+
+	published := make([]publishedPath, 0)
+
+	for _, nodeName := range nodeNames {
+		node := nodesByName[nodeName]
+		// kapps with failed conditions won't publish their outputs
+		if !node.conditionsValid {
+			continue
+		}
+
+		outputs := node.installableObj.GetDescriptor().Outputs
+		outputIds := make([]string, 0, len(outputs))
+		for outputId := range outputs {
+			outputIds = append(outputIds, outputId)
+		}
+		sort.Strings(outputIds)
+
+		for _, outputId := range outputIds {
+			output := outputs[outputId]
+			if output.RegistryPath == "" {
+				continue
+			}
+
+			err := registry.ValidatePath(output.RegistryPath)
+			if err != nil {
+				return errors.Wrapf(err, "Invalid registry path for output '%s' of kapp '%s'",
+					outputId, node.name)
+			}
+
+			conditionsPassed, err := utils.All(output.Conditions)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			if !conditionsPassed {
+				continue
+			}
+
+			for _, other := range published {
+				if !registry.HasPrefix(output.RegistryPath, other.path) &&
+					!registry.HasPrefix(other.path, output.RegistryPath) {
+					continue
+				}
+
+				if other.node.name == node.name {
+					return fmt.Errorf("Outputs '%s' and '%s' of kapp '%s' both publish to registry "+
+						"path '%s'", other.outputId, outputId, node.name, output.RegistryPath)
+				}
+
+				if topo.PathExistsIn(g.graph, other.node, node) || topo.PathExistsIn(g.graph, node, other.node) {
+					log.Logger.Debugf("Kapps '%s' and '%s' both publish to registry path '%s' but "+
+						"are ordered by dependencies", other.node.name, node.name, output.RegistryPath)
+					continue
+				}
+
+				return fmt.Errorf("Kapps '%s' (output '%s') and '%s' (output '%s') both publish to "+
+					"registry path '%s' but neither depends on the other, so the value would depend on "+
+					"which finished last. Make one depend on the other or disable one with conditions",
+					other.node.name, other.outputId, node.name, outputId, output.RegistryPath)
+			}
+
+			published = append(published, publishedPath{
+				path:     output.RegistryPath,
+				node:     node,
+				outputId: outputId,
+			})
+		}
+	}
+
+	return nil
+}
+
 // Returns a list of all marked installables in the DAG (in any order).
 func (g *Dag) GetInstallables() []interfaces.IInstallable {
 	log.Logger.Debug("Putting all installables in the DAG into a list")
@@ -442,9 +589,9 @@ func (g *Dag) walk(down bool, processCh chan<- NamedNode, doneCh chan NamedNode)
 				// this will be false if the channel has been closed, otherwise it pumps out nil values
 				if ok {
 					log.Logger.Debugf("Worker informs the DAG it's finished processing node '%s'", namedNode.name)
+					mutex.Lock()
 					nodeItem := nodeStatusesById[namedNode.node.ID()]
 					nodeItem.status = finished
-					mutex.Lock()
 					nodeStatusesById[namedNode.node.ID()] = nodeItem
 
 					// copy the node status map with a mutex so we don't hit concurrent map misuse issues
@@ -462,23 +609,27 @@ func (g *Dag) walk(down bool, processCh chan<- NamedNode, doneCh chan NamedNode)
 						if allDone(nodeStatusesByIdCopy) {
 							log.Logger.Infof("DAG fully processed")
 							// keep track of whether we've closed the channels (possibly in another goroutine)
+							mutex.Lock()
 							if !channelsClosed {
 								close(finishedCh)
 								close(doneCh)
 								close(processCh)
 								channelsClosed = true
 							}
+							mutex.Unlock()
 						}
 					}()
 				}
 			case <-progressTicker.C:
 				inProgressNodes := make([]string, 0)
+				mutex.Lock()
 				for node, nodeStatus := range nodeStatusesById {
 					if nodeStatus.status == running {
 						namedNode := nodeStatusesById[node]
 						inProgressNodes = append(inProgressNodes, namedNode.node.name)
 					}
 				}
+				mutex.Unlock()
 
 				if len(inProgressNodes) > 0 {
 					sort.Strings(inProgressNodes)
@@ -735,6 +886,14 @@ func BuildDagForSelected(stackObj interfaces.IStack, workspaceDir string, includ
 		return nil, errors.WithStack(err)
 	}
 
+	// load outputs, etc. from previous runs
+	if workspaceDir != "" {
+		err = stackObj.PersistRegistry(workspaceDir)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
 	// selected kapps will be returned in the order in which they appear in manifests, not the order
 	// they're specified in selectors
 	selectedInstallables, err := stack.SelectInstallables(stackObj.GetConfig().Manifests(),
@@ -140,6 +140,34 @@ func TestIsAcyclic(t *testing.T) {
 	assert.Nil(t, err)
 	_, err = build(input, stackConfig)
 	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Cyclical dependencies detected: entry")
+}
+
+// Tests that referring to the vars of another kapp implies a dependency on it
+func TestBuildDagImpliedDependencies(t *testing.T) {
+	referringKapp, err := installable.New("example-manifest", []structs.KappDescriptorWithMaps{
+		{
+			Id: "example-kapp",
+			KappConfig: structs.KappConfig{Vars: map[string]interface{}{
+				"fromA": "{{ .kapps.manifest1__kappA.vars.colours }}",
+			}},
+		},
+	})
+	assert.Nil(t, err)
+
+	input := map[string]nodeDescriptor{
+		"manifest1:kappA": {installableObj: kapp(t, nil)},
+		"referrer":        {installableObj: referringKapp},
+	}
+
+	stackConfig, err := stack.BuildStack("large", "../../testdata/stacks.yaml", &structs.StackFile{})
+	assert.Nil(t, err)
+	dag, err := build(input, stackConfig)
+	assert.Nil(t, err)
+
+	nodesByName := dag.nodesByName()
+	assert.True(t, dag.graph.HasEdgeFromTo(nodesByName["manifest1:kappA"].ID(),
+		nodesByName["referrer"].ID()))
 }
 
 func TestTraverse(t *testing.T) {
@@ -180,6 +208,7 @@ func TestTraverse(t *testing.T) {
 			for node := range processCh {
 				log.Logger.Infof("Processing '%s' in goroutine...", node.name)
 
+				mutex.Lock()
 				// make sure the first node we process is one of those marked as being allowed to
 				// be processed first
 				if numProcessed == 0 {
@@ -188,7 +217,6 @@ func TestTraverse(t *testing.T) {
 
 				lastProcessedId = node.name
 
-				mutex.Lock()
 				numProcessed++
 				mutex.Unlock()
 
@@ -204,6 +232,8 @@ func TestTraverse(t *testing.T) {
 	<-finishedCh
 
 	// make sure the last to be processed is marked as being allowed to be last
+	mutex.Lock()
+	defer mutex.Unlock()
 	assert.True(t, utils.InStringArray(possibleLastNodes, lastProcessedId))
 }
 
@@ -255,3 +285,117 @@ func assertDependencies(t *testing.T, graphObj *Dag, descriptors map[string]node
 
 	}
 }
+
+// Returns a kapp with the given dependencies whose outputs publish to the given registry paths
+func kappPublishingTo(t *testing.T, id string, dependencies []string, registryPaths ...string) interfaces.IInstallable {
+	deps := make([]structs.Dependency, 0)
+	for _, dep := range dependencies {
+		deps = append(deps, structs.Dependency{Id: dep})
+	}
+
+	outputs := make(map[string]structs.Output)
+	for i, registryPath := range registryPaths {
+		outputId := fmt.Sprintf("output%d", i)
+		outputs[outputId] = structs.Output{
+			Id:           outputId,
+			Path:         "output.json",
+			Format:       "json",
+			RegistryPath: registryPath,
+		}
+	}
+
+	kapp, err := installable.New("example-manifest", []structs.KappDescriptorWithMaps{
+		{
+			Id:         id,
+			KappConfig: structs.KappConfig{DependsOn: deps},
+			Outputs:    outputs,
+		},
+	})
+	assert.Nil(t, err)
+
+	return kapp
+}
+
+func TestCheckRegistryPaths(t *testing.T) {
+	tests := []struct {
+		name        string
+		descriptors func() map[string]nodeDescriptor
+		expectedErr string
+	}{
+		{
+			name: "different_paths",
+			descriptors: func() map[string]nodeDescriptor {
+				return map[string]nodeDescriptor{
+					"vpc1": {installableObj: kappPublishingTo(t, "vpc1", nil, "network.vpc")},
+					"vpc2": {installableObj: kappPublishingTo(t, "vpc2", nil, "network.subnets")},
+				}
+			},
+		},
+		{
+			name: "ordered_by_dependency",
+			descriptors: func() map[string]nodeDescriptor {
+				return map[string]nodeDescriptor{
+					"vpc1": {installableObj: kappPublishingTo(t, "vpc1", nil, "network.vpc")},
+					"mid":  {installableObj: kappPublishingTo(t, "mid", []string{"vpc1"})},
+					"vpc2": {installableObj: kappPublishingTo(t, "vpc2", []string{"mid"}, "network.vpc")},
+				}
+			},
+		},
+		{
+			name: "unordered",
+			descriptors: func() map[string]nodeDescriptor {
+				return map[string]nodeDescriptor{
+					"vpc1": {installableObj: kappPublishingTo(t, "vpc1", nil, "network.vpc")},
+					"vpc2": {installableObj: kappPublishingTo(t, "vpc2", nil, "network.vpc")},
+				}
+			},
+			expectedErr: "Kapps 'vpc1' (output 'output0') and 'vpc2' (output 'output0') both publish " +
+				"to registry path 'network.vpc' but neither depends on the other",
+		},
+		{
+			name: "unordered_nested",
+			descriptors: func() map[string]nodeDescriptor {
+				return map[string]nodeDescriptor{
+					"vpc1": {installableObj: kappPublishingTo(t, "vpc1", nil, "network")},
+					"vpc2": {installableObj: kappPublishingTo(t, "vpc2", nil, "network.vpc")},
+				}
+			},
+			expectedErr: "both publish to registry path 'network.vpc'",
+		},
+		{
+			name: "same_kapp",
+			descriptors: func() map[string]nodeDescriptor {
+				return map[string]nodeDescriptor{
+					"vpc1": {installableObj: kappPublishingTo(t, "vpc1", nil, "network.vpc", "network.vpc")},
+				}
+			},
+			expectedErr: "Outputs 'output0' and 'output1' of kapp 'vpc1' both publish to registry path",
+		},
+		{
+			name: "protected",
+			descriptors: func() map[string]nodeDescriptor {
+				return map[string]nodeDescriptor{
+					"vpc1": {installableObj: kappPublishingTo(t, "vpc1", nil, "outputs.other__kapp.vpc")},
+				}
+			},
+			expectedErr: "Registry path 'outputs.other__kapp.vpc' is protected",
+		},
+	}
+
+	stackConfig, err := stack.BuildStack("large", "../../testdata/stacks.yaml", &structs.StackFile{})
+	assert.Nil(t, err)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dag, err := build(test.descriptors(), stackConfig)
+			assert.Nil(t, err)
+
+			err = dag.checkRegistryPaths()
+			if test.expectedErr == "" {
+				assert.Nil(t, err)
+			} else if assert.NotNil(t, err) {
+				assert.Contains(t, err.Error(), test.expectedErr)
+			}
+		})
+	}
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
 	"github.com/sugarkube/sugarkube/internal/pkg/printer"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
 	"github.com/sugarkube/sugarkube/internal/pkg/registry"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
 	"github.com/sugarkube/sugarkube/internal/pkg/utils"
@@ -37,6 +38,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 // Traverses the DAG executing the named action on marked/processable nodes depending on the
@@ -58,6 +60,9 @@ func (d *Dag) Execute(action string, stackObj interfaces.IStack, plan bool, appr
 		return errors.WithStack(err)
 	}
 
+	// save any changes that weren't saved when kapps finished, e.g. because one failed
+	defer saveRegistry(stackObj)
+
 	// create the worker pool
 	for w := int(0); w < numWorkers; w++ {
 		go worker(d, processCh, doneCh, errCh, action, stackObj, plan, approved, skipPreActions, skipPostActions,
@@ -108,8 +113,11 @@ func (d *Dag) Execute(action string, stackObj interfaces.IStack, plan bool, appr
 	}
 }
 
-// Traverses the DAG printing vars for all marked nodes, optionally suppressing output for certain keys
-func (d *Dag) ExecuteGetVars(action string, stackObj interfaces.IStack, loadOutputs bool, suppress []string) error {
+// Traverses the DAG printing vars for all marked nodes, optionally suppressing output for certain keys. If
+// `explain` is true the source of each value is printed instead of the vars and kapp config. Sensitive values
+// are masked unless `showSecrets` is true.
+func (d *Dag) ExecuteGetVars(action string, stackObj interfaces.IStack, loadOutputs bool, suppress []string,
+	explain bool, showSecrets bool) error {
 	numWorkers := config.CurrentConfig.NumWorkers
 
 	processCh := make(chan NamedNode, numWorkers)
@@ -120,7 +128,7 @@ func (d *Dag) ExecuteGetVars(action string, stackObj interfaces.IStack, loadOutp
 
 	// create the worker pool
 	for w := int(0); w < numWorkers; w++ {
-		go varsWorker(processCh, doneCh, errCh, stackObj, suppress)
+		go varsWorker(processCh, doneCh, errCh, stackObj, suppress, explain, showSecrets)
 	}
 
 	var finishedCh <-chan bool
@@ -157,6 +165,63 @@ func (d *Dag) ExecuteGetVars(action string, stackObj interfaces.IStack, loadOutp
 	}
 }
 
+// Loads the outputs of all nodes in the DAG into their local registries, then returns the installable
+// with the given fully-qualified ID. This lets a single kapp be worked with outside of the usual actions
+// while still having access to its parents' outputs.
+func (d *Dag) LoadInstallable(stackObj interfaces.IStack, fullyQualifiedId string, dryRun bool) (interfaces.IInstallable, error) {
+	node, ok := d.nodesByName()[fullyQualifiedId]
+	if !ok {
+		return nil, fmt.Errorf("Kapp '%s' isn't in the DAG", fullyQualifiedId)
+	}
+
+	err := initLocalRegistries(d, config.CurrentConfig.NumWorkers, stackObj, constants.DagActionOutput,
+		false, dryRun)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return node.installableObj, nil
+}
+
+// Executes the run steps referred to by `call` for a single kapp, e.g. 'output/tf-output'. See
+// `installer.RunUnitInstaller.Call` for the syntax. Templates are rendered first unless `skipTemplating`
+// is true.
+func ExecuteCall(installableObj interfaces.IInstallable, stackObj interfaces.IStack, call string,
+	skipTemplating bool, ignoreErrors bool, dryRun bool) error {
+
+	// save any outputs the run steps set
+	defer saveRegistry(stackObj)
+
+	if !skipTemplating {
+		err := renderKappTemplates(stackObj, installableObj, true, dryRun)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	installerImpl := installer.RunUnitInstaller{}
+	installerMethod := func(installableObj interfaces.IInstallable, stackObj interfaces.IStack,
+		dryRun bool) ([]structs.RunStep, error) {
+		return installerImpl.Call(installableObj, stackObj, call, dryRun)
+	}
+
+	runSteps, err := installerMethod(installableObj, stackObj, dryRun)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if len(runSteps) == 0 {
+		return fmt.Errorf("No run steps found for '%s' in kapp '%s'", call, installableObj.FullyQualifiedId())
+	}
+
+	err = executeRunSteps(call, runSteps, installableObj, stackObj, installerMethod, ignoreErrors, dryRun)
+	if err != nil {
+		return errors.Wrapf(err, "Error executing run steps for kapp '%s'", installableObj.Id())
+	}
+
+	return nil
+}
+
 // Creates a pool of workers to populate the local registries on installables in the DAG
 func initLocalRegistries(dagObj *Dag, numWorkers int, stackObj interfaces.IStack, action string,
 	approved bool, dryRun bool) error {
@@ -168,6 +233,8 @@ func initLocalRegistries(dagObj *Dag, numWorkers int, stackObj interfaces.IStack
 	doneCh := make(chan NamedNode)
 	errCh := make(chan error)
 
+	defer saveRegistry(stackObj)
+
 	for w := int(0); w < numWorkers; w++ {
 		go registryWorker(dagObj, processCh, doneCh, errCh, stackObj, action, approved, dryRun)
 	}
@@ -233,27 +300,36 @@ func registryWorker(dagObj *Dag, processCh <-chan NamedNode, doneCh chan<- Named
 			return
 		}
 
-		// try loading outputs, but don't fail if we can't
-		outputs, err := getOutputs(installableObj, stackObj, installerImpl, true, dryRun)
-		if err != nil {
-			errCh <- errors.WithStack(err)
-			return
+		// reuse outputs persisted by a previous run instead of running output steps again
+		outputs, ok := registryOutputs(installableObj, stackObj.GetRegistry())
+		if ok {
+			log.Logger.Infof("Using outputs of kapp '%s' from the registry", installableObj.FullyQualifiedId())
+		} else {
+			// try loading outputs, but don't fail if we can't
+			outputs, err = getOutputs(installableObj, stackObj, installerImpl, true, dryRun)
+			if err != nil {
+				errCh <- errors.WithStack(err)
+				return
+			}
 		}
 
 		// add outputs to the kapp
-		err = addOutputsToRegistry(installableObj, outputs, installableObj.GetLocalRegistry(), true)
+		err = addOutputsToRegistry(installableObj, outputs, installableObj.GetLocalRegistry(), true, dryRun)
 		if err != nil {
 			errCh <- errors.WithStack(err)
 			return
 		}
 
 		// and also to the stack's registry (but only with fully-qualified keys)
-		err = addOutputsToRegistry(installableObj, outputs, stackObj.GetRegistry(), false)
+		err = addOutputsToRegistry(installableObj, outputs, stackObj.GetRegistry(), false, dryRun)
 		if err != nil {
 			errCh <- errors.WithStack(err)
 			return
 		}
 
+		// save outputs from running output steps
+		saveRegistry(stackObj)
+
 		log.Logger.Tracef("Registry worker finished processing kapp '%s' (node=%#v)", installableObj.FullyQualifiedId(),
 			node)
 		doneCh <- node
@@ -399,14 +475,14 @@ func worker(dagObj *Dag, processCh <-chan NamedNode, doneCh chan<- NamedNode, er
 			}
 
 			// add outputs to the kapp
-			err = addOutputsToRegistry(installableObj, outputs, installableObj.GetLocalRegistry(), true)
+			err = addOutputsToRegistry(installableObj, outputs, installableObj.GetLocalRegistry(), true, dryRun)
 			if err != nil {
 				errCh <- errors.WithStack(err)
 				return
 			}
 
 			// and also to the stack's registry (but only with fully-qualified keys)
-			err = addOutputsToRegistry(installableObj, outputs, stackObj.GetRegistry(), false)
+			err = addOutputsToRegistry(installableObj, outputs, stackObj.GetRegistry(), false, dryRun)
 			if err != nil {
 				errCh <- errors.WithStack(err)
 				return
@@ -427,6 +503,9 @@ func worker(dagObj *Dag, processCh <-chan NamedNode, doneCh chan<- NamedNode, er
 			}
 		}
 
+		// save outputs, etc. once the kapp has finished
+		saveRegistry(stackObj)
+
 		log.Logger.Tracef("Worker finished processing kapp '%s' (node=%#v)", installableObj.FullyQualifiedId(),
 			node)
 		doneCh <- node
@@ -436,7 +515,7 @@ func worker(dagObj *Dag, processCh <-chan NamedNode, doneCh chan<- NamedNode, er
 
 // Prints out the variables for each node, marked or not.
 func varsWorker(processCh <-chan NamedNode, doneCh chan<- NamedNode, errCh chan error, stackObj interfaces.IStack,
-	suppress []string) {
+	suppress []string, explain bool, showSecrets bool) {
 
 	for node := range processCh {
 		installableObj := node.installableObj
@@ -456,6 +535,17 @@ func varsWorker(processCh <-chan NamedNode, doneCh chan<- NamedNode, errCh chan
 
 		log.Logger.Debugf("Getting variables for kapp '%s'", installableObj.FullyQualifiedId())
 
+		if explain {
+			err = printVarsExplanation(stackObj, installableObj, suppress, showSecrets)
+			if err != nil {
+				errCh <- errors.WithStack(err)
+				return
+			}
+
+			doneCh <- node
+			continue
+		}
+
 		// template the kapp's descriptor, including the global registry
 		templatedVars, err := stackObj.GetTemplatedVars(installableObj, map[string]interface{}{})
 
@@ -474,13 +564,13 @@ func varsWorker(processCh <-chan NamedNode, doneCh chan<- NamedNode, errCh chan
 			}
 		}
 
-		yamlData, err := yaml.Marshal(&templatedVars)
+		yamlData, err := yaml.Marshal(maskValue(templatedVars, showSecrets))
 		if err != nil {
 			errCh <- errors.WithStack(err)
 			return
 		}
 
-		_, err = printer.Fprintf("\n[yellow]***** Start variables for kapp '[bold]%s[reset][yellow]' *****[reset]\n"+
+		_, err = printIfAllowed(showSecrets, "\n[yellow]***** Start variables for kapp '[bold]%s[reset][yellow]' *****[reset]\n"+
 			"%s[yellow]***** End variables for kapp '[bold]%s[reset][yellow]' *****[reset]\n",
 			installableObj.FullyQualifiedId(), yamlData, installableObj.FullyQualifiedId())
 		if err != nil {
@@ -500,9 +590,9 @@ func varsWorker(processCh <-chan NamedNode, doneCh chan<- NamedNode, errCh chan
 			return
 		}
 
-		_, err = printer.Fprintf("\n[yellow]***** Start config for kapp '[bold]%s[reset][yellow]' *****[reset]\n"+
+		_, err = printIfAllowed(showSecrets, "\n[yellow]***** Start config for kapp '[bold]%s[reset][yellow]' *****[reset]\n"+
 			"%s[yellow]***** End config for kapp '[bold]%s[reset][yellow]' *****[reset]\n",
-			installableObj.FullyQualifiedId(), kappConfig, installableObj.FullyQualifiedId())
+			installableObj.FullyQualifiedId(), string(kappConfig), installableObj.FullyQualifiedId())
 
 		log.Logger.Tracef("Vars worker finished processing kapp '%s' (node=%#v)", installableObj.FullyQualifiedId(),
 			node)
@@ -511,6 +601,81 @@ func varsWorker(processCh <-chan NamedNode, doneCh chan<- NamedNode, errCh chan
 	}
 }
 
+// Prints each of a kapp's vars along with the layer it came from and its raw value if that's
+// different to the templated value
+func printVarsExplanation(stackObj interfaces.IStack, installableObj interfaces.IInstallable,
+	suppress []string, showSecrets bool) error {
+	templatedVars, provenance, err := stackObj.ExplainVars(installableObj)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var buffer bytes.Buffer
+
+	for _, explanation := range provenance.Explain(templatedVars) {
+		if isSuppressed(explanation.Path, suppress) {
+			continue
+		}
+
+		buffer.WriteString(sprintfIfAllowed(showSecrets, "[bold]%s[reset]: %v\n    [green]from:[reset] %s\n",
+			explanation.Path, maskValue(explanation.Value, showSecrets), explanation.Origin))
+
+		if fmt.Sprintf("%v", explanation.Raw) != fmt.Sprintf("%v", explanation.Value) {
+			buffer.WriteString(sprintfIfAllowed(showSecrets, "    [green]raw:[reset]  %v\n",
+				maskValue(explanation.Raw, showSecrets)))
+		}
+	}
+
+	// print everything at once so output for different kapps isn't interleaved
+	_, err = printIfAllowed(showSecrets, "\n[yellow]***** Start variable sources for kapp '[bold]%s[reset][yellow]' *****[reset]\n"+
+		"%s[yellow]***** End variable sources for kapp '[bold]%s[reset][yellow]' *****[reset]\n",
+		installableObj.FullyQualifiedId(), buffer.String(), installableObj.FullyQualifiedId())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// Masks sensitive values unless a user asked to see them
+func maskValue(value interface{}, showSecrets bool) interface{} {
+	if showSecrets {
+		return value
+	}
+
+	return redact.Value(value)
+}
+
+// Prints formatted text, only masking sensitive values if a user didn't ask to see them
+func printIfAllowed(showSecrets bool, format string, args ...interface{}) (int, error) {
+	if showSecrets {
+		return printer.FprintfUnmasked(format, args...)
+	}
+
+	return printer.Fprintf(format, args...)
+}
+
+// Formats text, only masking sensitive values if a user didn't ask to see them
+func sprintfIfAllowed(showSecrets bool, format string, args ...interface{}) string {
+	if showSecrets {
+		return printer.SprintfUnmasked(format, args...)
+	}
+
+	return printer.Sprintf(format, args...)
+}
+
+// Returns whether a path to a variable is the same as or under any of the suppressed paths
+func isSuppressed(path string, suppress []string) bool {
+	for _, exclusion := range suppress {
+		exclusion = strings.TrimPrefix(exclusion, ".")
+		if path == exclusion || strings.HasPrefix(path, exclusion+".") {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Implements the install action. Nodes that should be processed are installed. All nodes load any outputs
 // and merge them with their parents' outputs.
 func installOrDelete(install bool, dagObj *Dag, node NamedNode, installerImpl interfaces.IInstaller,
@@ -677,6 +842,9 @@ func installOrDelete(install bool, dagObj *Dag, node NamedNode, installerImpl in
 					errCh <- errors.Wrapf(err, "Error processing kapp '%s'", installableObj.Id())
 					return
 				}
+			} else if !install && !dryRun {
+				// the kapp's outputs no longer exist so stop persisting them
+				deleteOutputsFromRegistry(installableObj, stackObj.GetRegistry())
 			}
 		}
 	}
@@ -695,14 +863,14 @@ func installOrDelete(install bool, dagObj *Dag, node NamedNode, installerImpl in
 	}
 
 	// build the kapp's local registry
-	err = addOutputsToRegistry(installableObj, outputs, installableObj.GetLocalRegistry(), true)
+	err = addOutputsToRegistry(installableObj, outputs, installableObj.GetLocalRegistry(), true, dryRun)
 	if err != nil {
 		errCh <- errors.WithStack(err)
 		return
 	}
 
 	// and also to the stack's registry (but only with fully-qualified keys)
-	err = addOutputsToRegistry(installableObj, outputs, stackObj.GetRegistry(), false)
+	err = addOutputsToRegistry(installableObj, outputs, stackObj.GetRegistry(), false, dryRun)
 	if err != nil {
 		errCh <- errors.WithStack(err)
 		return
@@ -754,7 +922,32 @@ func executeRunSteps(unitName string, runSteps []structs.RunStep, installableObj
 		dryRunPrefix = "[Dry run] "
 	}
 
-	_, err := printer.Fprintf("%s[white][bold]%s[reset] - Executing '[white]%s[default]' run steps...\n",
+	action, err := installer.CallAction(installableObj, unitName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// the vars the run steps were templated with
+	templatedVars, err := stackObj.GetTemplatedVars(installableObj, installer.RunStepVars(action, dryRun))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// don't run anything with vars that don't conform to the kapp's schema
+	err = stackObj.ValidateVars(installableObj, templatedVars)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// sensitive templates only exist while the run steps are executing
+	defer deleteSensitiveTemplates(installableObj)
+
+	err = renderSensitiveTemplates(stackObj, installableObj, templatedVars, dryRun)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = printer.Fprintf("%s[white][bold]%s[reset] - Executing '[white]%s[default]' run steps...\n",
 		dryRunPrefix, installableObj.FullyQualifiedId(), unitName)
 	if err != nil {
 		return errors.WithStack(err)
@@ -922,13 +1115,13 @@ func executeRunSteps(unitName string, runSteps []structs.RunStep, installableObj
 			}
 
 			// add outputs to the kapp's registry
-			err = addOutputsToRegistry(installableObj, outputs, installableObj.GetLocalRegistry(), true)
+			err = addOutputsToRegistry(installableObj, outputs, installableObj.GetLocalRegistry(), true, dryRun)
 			if err != nil {
 				return errors.WithStack(err)
 			}
 
 			// and also to the stack's registry (but only with fully-qualified keys)
-			err = addOutputsToRegistry(installableObj, outputs, stackObj.GetRegistry(), false)
+			err = addOutputsToRegistry(installableObj, outputs, stackObj.GetRegistry(), false, dryRun)
 			if err != nil {
 				return errors.WithStack(err)
 			}
@@ -940,6 +1133,16 @@ func executeRunSteps(unitName string, runSteps []structs.RunStep, installableObj
 				return errors.WithStack(err)
 			}
 
+			templatedVars, err = stackObj.GetTemplatedVars(installableObj, installer.RunStepVars(action, dryRun))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
+			err = renderSensitiveTemplates(stackObj, installableObj, templatedVars, dryRun)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+
 			// rerender the run steps
 			runSteps, err = installerMethod(installableObj, stackObj, dryRun)
 			if err != nil {
@@ -1154,17 +1357,14 @@ func deleteSpecialThisOutput(registry interfaces.IRegistry) {
 
 // Adds output from an installable to the registry
 // If `includeLocalKeys` is true, additional keys will be added to the registry for access from within a kapp (e.g.
-// `.outputs.this`, etc.
+// `.outputs.this`, etc. Outputs added during dry runs aren't persisted.
 func addOutputsToRegistry(installableObj interfaces.IInstallable, outputs map[string]interface{},
-	registry interfaces.IRegistry, includeLocalKeys bool) error {
+	registryObj interfaces.IRegistry, includeLocalKeys bool, dryRun bool) error {
 
 	// We convert kapp IDs to have underscores because Go's templating library throws its toys out
 	// the pram when it find a map key with a hyphen in. K8s is the opposite, so this seems like
 	// the least worst way of accommodating both
 	underscoredInstallableId := strings.Replace(installableObj.Id(), "-", "_", -1)
-	underscoredInstallableFQId := strings.Replace(installableObj.FullyQualifiedId(), "-", "_", -1)
-	underscoredInstallableFQId = strings.Replace(underscoredInstallableFQId, constants.NamespaceSeparator,
-		constants.TemplateNamespaceSeparator, -1)
 
 	prefixes := make([]string, 0)
 
@@ -1179,24 +1379,103 @@ func addOutputsToRegistry(installableObj interfaces.IInstallable, outputs map[st
 	}
 
 	// always add keys for the fully-qualified kapp ID
-	prefixes = append(prefixes, strings.Join([]string{constants.RegistryKeyOutputs, underscoredInstallableFQId},
-		constants.RegistryFieldSeparator))
+	prefixes = append(prefixes, fullyQualifiedOutputsKey(installableObj))
+
+	source := interfaces.RegistrySource{
+		Kapp:   installableObj.FullyQualifiedId(),
+		Time:   time.Now(),
+		DryRun: dryRun,
+	}
 
 	// store the output under various keys
 	for outputId, output := range outputs {
 		for _, prefix := range prefixes {
 			underscoredOutputId := strings.Replace(outputId, "-", "_", -1)
 			key := strings.Join([]string{prefix, underscoredOutputId}, constants.RegistryFieldSeparator)
-			err := registry.Set(key, output)
+			err := registryObj.SetWithSource(key, output, source)
 			if err != nil {
 				return errors.WithStack(err)
 			}
 		}
 	}
 
+	// also publish outputs to any custom registry paths they declare
+	for _, outputDescriptor := range installableObj.GetDescriptor().Outputs {
+		if outputDescriptor.RegistryPath == "" {
+			continue
+		}
+
+		// don't replace a value published by another kapp with a missing output
+		output, ok := outputs[outputDescriptor.Id]
+		if !ok || output == nil {
+			continue
+		}
+
+		err := registry.ValidatePath(outputDescriptor.RegistryPath)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid registry path for output '%s' of kapp '%s'",
+				outputDescriptor.Id, installableObj.FullyQualifiedId())
+		}
+
+		err = registryObj.SetWithSource(outputDescriptor.RegistryPath, output, source)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
 	return nil
 }
 
+// Deletes a kapp's outputs from the registry, including those at custom registry paths provided they
+// were published by the kapp
+func deleteOutputsFromRegistry(installableObj interfaces.IInstallable, registryObj interfaces.IRegistry) {
+	registryObj.Delete(fullyQualifiedOutputsKey(installableObj))
+
+	sources := registryObj.Sources()
+	for _, outputDescriptor := range installableObj.GetDescriptor().Outputs {
+		if outputDescriptor.RegistryPath == "" {
+			continue
+		}
+
+		source, ok := sources[outputDescriptor.RegistryPath]
+		if ok && source.Kapp == installableObj.FullyQualifiedId() {
+			registryObj.Delete(outputDescriptor.RegistryPath)
+		}
+	}
+}
+
+// Returns the registry key the outputs of a kapp are stored under with its fully-qualified ID
+func fullyQualifiedOutputsKey(installableObj interfaces.IInstallable) string {
+	underscoredInstallableFQId := strings.Replace(installableObj.FullyQualifiedId(), "-", "_", -1)
+	underscoredInstallableFQId = strings.Replace(underscoredInstallableFQId, constants.NamespaceSeparator,
+		constants.TemplateNamespaceSeparator, -1)
+
+	return strings.Join([]string{constants.RegistryKeyOutputs, underscoredInstallableFQId},
+		constants.RegistryFieldSeparator)
+}
+
+// Saves changes to the stack's registry. Errors are only logged because the changes are still
+// available for the rest of the run.
+func saveRegistry(stackObj interfaces.IStack) {
+	err := stackObj.SaveRegistry()
+	if err != nil {
+		log.Logger.Warnf("Error saving the registry: %v", err)
+	}
+}
+
+// Returns the outputs of a kapp already in the stack's registry, e.g. because they were persisted
+// by a previous run
+func registryOutputs(installableObj interfaces.IInstallable,
+	registryObj interfaces.IRegistry) (map[string]interface{}, bool) {
+	value, ok := registryObj.Get(fullyQualifiedOutputsKey(installableObj))
+	if !ok {
+		return nil, false
+	}
+
+	outputs, ok := value.(map[string]interface{})
+	return outputs, ok && len(outputs) > 0
+}
+
 // Renders templates for a kapp
 func renderKappTemplates(stackObj interfaces.IStack, installableObj interfaces.IInstallable,
 	printMessage bool, dryRun bool) error {
@@ -1252,3 +1531,25 @@ func renderKappTemplates(stackObj interfaces.IStack, installableObj interfaces.I
 
 	return nil
 }
+
+// Renders a kapp's sensitive templates just before its run steps are executed, using the same vars
+// as the run steps
+func renderSensitiveTemplates(stackObj interfaces.IStack, installableObj interfaces.IInstallable,
+	templatedVars map[string]interface{}, dryRun bool) error {
+	err := installableObj.RenderSensitiveTemplates(templatedVars, stackObj.GetConfig(), dryRun)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// Deletes a kapp's sensitive templates. Errors are logged because this is called once the kapp
+// has been executed and shouldn't hide any error from doing so.
+func deleteSensitiveTemplates(installableObj interfaces.IInstallable) {
+	err := installableObj.DeleteSensitiveTemplates()
+	if err != nil {
+		log.Logger.Errorf("Error deleting sensitive templates for kapp '%s': %v",
+			installableObj.FullyQualifiedId(), err)
+	}
+}
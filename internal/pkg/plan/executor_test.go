@@ -0,0 +1,184 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package plan
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
+	"github.com/sugarkube/sugarkube/internal/pkg/installable"
+	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
+	"github.com/sugarkube/sugarkube/internal/pkg/registry"
+	"github.com/sugarkube/sugarkube/internal/pkg/stack"
+	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"sync"
+	"testing"
+)
+
+// Returns descriptors like getDescriptors but with a unique kapp ID for each node
+func getUniqueDescriptors(t *testing.T) map[string]nodeDescriptor {
+	descriptors := make(map[string]nodeDescriptor)
+
+	for id, descriptor := range getDescriptors(t) {
+		installableObj, err := installable.New("example-manifest", []structs.KappDescriptorWithMaps{
+			{
+				Id:         id,
+				KappConfig: structs.KappConfig{DependsOn: descriptor.installableObj.GetDescriptor().DependsOn},
+			},
+		})
+		assert.Nil(t, err)
+
+		descriptors[id] = nodeDescriptor{installableObj: installableObj}
+	}
+
+	return descriptors
+}
+
+// Walks the DAG with workers that publish outputs to local and global registries the way the
+// executor does. Run with -race to detect unsynchronised access to the registries.
+func TestRegistryWithConcurrentWorkers(t *testing.T) {
+	input := getUniqueDescriptors(t)
+	stackConfig, err := stack.BuildStack("large", "../../testdata/stacks.yaml", &structs.StackFile{})
+	assert.Nil(t, err)
+	dag, err := build(input, stackConfig)
+	assert.Nil(t, err)
+
+	globalRegistry := registry.New()
+
+	mutex := &sync.Mutex{}
+	published := make([]string, 0)
+	unsubscribe := globalRegistry.Subscribe(func(event interfaces.RegistryEvent) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		published = append(published, event.Key)
+	})
+	defer unsubscribe()
+
+	processCh := make(chan NamedNode)
+	doneCh := make(chan NamedNode)
+	errCh := make(chan error, len(input))
+
+	numWorkers := 5
+	config.CurrentConfig = &config.Config{
+		NumWorkers: numWorkers,
+	}
+
+	for i := 0; i < numWorkers; i++ {
+		go func() {
+			for node := range processCh {
+				addParentRegistries(dag, node, errCh)
+
+				// read the global registry while other workers write to it
+				for range globalRegistry.AsMap() {
+				}
+				globalRegistry.Get("outputs.example_manifest__cluster")
+
+				outputs := map[string]interface{}{
+					"name": node.name,
+					"nested": map[interface{}]interface{}{
+						"worker": fmt.Sprintf("%s-worker", node.name),
+					},
+				}
+
+				err := addOutputsToRegistry(node.installableObj, outputs,
+					node.installableObj.GetLocalRegistry(), true, false)
+				if err != nil {
+					errCh <- err
+				}
+
+				err = addOutputsToRegistry(node.installableObj, outputs, globalRegistry, false, false)
+				if err != nil {
+					errCh <- err
+				}
+
+				// templating reads the local registry of this node and its parents
+				_, err = node.installableObj.Vars(stackConfig)
+				if err != nil {
+					errCh <- err
+				}
+
+				doneCh <- node
+			}
+		}()
+	}
+
+	finishedCh := dag.walkDown(processCh, doneCh)
+
+	// wait for traversal to finish
+	<-finishedCh
+
+	select {
+	case err := <-errCh:
+		assert.Nil(t, err)
+	default:
+	}
+
+	for id := range input {
+		name, ok := globalRegistry.Get(fmt.Sprintf("outputs.example_manifest__%s.name", id))
+		assert.True(t, ok)
+		assert.Equal(t, id, name)
+	}
+
+	// each node publishes 2 outputs to the global registry
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Len(t, published, 2*len(input))
+
+	// outputs of parents are in the local registries of their children
+	wordpress := input["wordpress1"].installableObj
+	name, ok := wordpress.GetLocalRegistry().Get("outputs.sharedRds.name")
+	assert.True(t, ok)
+	assert.Equal(t, "sharedRds", name)
+}
+
+func TestAddOutputsToRegistryPaths(t *testing.T) {
+	vpc1 := kappPublishingTo(t, "vpc1", nil, "network.vpc")
+	vpc2 := kappPublishingTo(t, "vpc2", nil, "network.vpc")
+
+	registryObj := registry.New()
+
+	err := addOutputsToRegistry(vpc1, map[string]interface{}{"output0": "vpc-1"}, registryObj, false, false)
+	assert.Nil(t, err)
+
+	value, ok := registryObj.Get("network.vpc")
+	assert.True(t, ok)
+	assert.Equal(t, "vpc-1", value)
+	assert.Equal(t, "example-manifest:vpc1", registryObj.Sources()["network.vpc"].Kapp)
+
+	// missing outputs don't replace values published by other kapps
+	err = addOutputsToRegistry(vpc2, map[string]interface{}{"output0": nil}, registryObj, false, false)
+	assert.Nil(t, err)
+	value, _ = registryObj.Get("network.vpc")
+	assert.Equal(t, "vpc-1", value)
+
+	err = addOutputsToRegistry(vpc2, map[string]interface{}{"output0": "vpc-2"}, registryObj, false, false)
+	assert.Nil(t, err)
+	value, _ = registryObj.Get("network.vpc")
+	assert.Equal(t, "vpc-2", value)
+
+	// deleting a kapp only deletes registry paths it published
+	deleteOutputsFromRegistry(vpc1, registryObj)
+	_, ok = registryObj.Get("outputs.example_manifest__vpc1")
+	assert.False(t, ok)
+	value, ok = registryObj.Get("network.vpc")
+	assert.True(t, ok)
+	assert.Equal(t, "vpc-2", value)
+
+	deleteOutputsFromRegistry(vpc2, registryObj)
+	_, ok = registryObj.Get("network.vpc")
+	assert.False(t, ok)
+}
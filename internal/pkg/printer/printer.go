@@ -3,6 +3,7 @@ package printer
 import (
 	"fmt"
 	"github.com/mitchellh/colorstring"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
 	"io"
 	"os"
 )
@@ -23,20 +24,40 @@ func Disable() {
 	coloriser.Disable = true
 }
 
-func SetOutput(out io.Writer) {
+// Sets the writer output is printed to and returns the previous one so it can be restored
+func SetOutput(out io.Writer) io.Writer {
+	previous := writer
 	writer = out
+	return previous
 }
 
 // Valid colour codes are listed at: https://github.com/mitchellh/colorstring/blob/master/colorstring.go
+// Sensitive values are masked in everything that's printed.
 
 func Fprint(text string) (int, error) {
-	return fmt.Fprint(writer, coloriser.Color(text))
+	return fmt.Fprint(writer, redact.String(coloriser.Color(text)))
 }
 
 func Fprintf(format string, args ...interface{}) (int, error) {
-	return fmt.Fprintf(writer, coloriser.Color(format), args...)
+	return fmt.Fprint(writer, redact.String(fmt.Sprintf(coloriser.Color(format), args...)))
+}
+
+// Returns the formatted string with colour codes in the format replaced
+func Sprintf(format string, args ...interface{}) string {
+	return redact.String(fmt.Sprintf(coloriser.Color(format), args...))
 }
 
 func Fprintln(text string) (int, error) {
-	return fmt.Fprintln(writer, coloriser.Color(text))
+	return fmt.Fprintln(writer, redact.String(coloriser.Color(text)))
+}
+
+// Like Fprintf but sensitive values aren't masked. Only use this to print values a user has
+// explicitly asked to see.
+func FprintfUnmasked(format string, args ...interface{}) (int, error) {
+	return fmt.Fprint(writer, fmt.Sprintf(coloriser.Color(format), args...))
+}
+
+// Like Sprintf but sensitive values aren't masked
+func SprintfUnmasked(format string, args ...interface{}) string {
+	return fmt.Sprintf(coloriser.Color(format), args...)
 }
@@ -130,10 +130,8 @@ func findVarsFiles(provider interfaces.IProvider, stackConfig interfaces.IStackC
 			log.Logger.Tracef("Walked to path: %s", path)
 
 			if !info.IsDir() {
-				ext := filepath.Ext(path)
-
-				if strings.ToLower(ext) != ".yaml" {
-					log.Logger.Debugf("Ignoring non-yaml file: %s", path)
+				if !vars.IsVarsFile(path) {
+					log.Logger.Debugf("Ignoring file that isn't a vars file: %s", path)
 					return nil
 				}
 
@@ -151,5 +149,7 @@ func findVarsFiles(provider interfaces.IProvider, stackConfig interfaces.IStackC
 
 	log.Logger.Debugf("Provider var paths are: %s", strings.Join(paths, ", "))
 
+	vars.WarnAboutConflicts(paths)
+
 	return paths, nil
 }
@@ -19,6 +19,8 @@ package provider
 import (
 	"github.com/stretchr/testify/assert"
 	"github.com/sugarkube/sugarkube/internal/pkg/mock"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 )
@@ -107,3 +109,34 @@ func TestFindProviderVarsFiles(t *testing.T) {
 
 	assert.Equal(t, expected, results)
 }
+
+func TestFindProviderVarsFilesFormats(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "provider-vars-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	for _, name := range []string{"values.yaml", "values.json", "large.yml", "fake-region.toml",
+		"notes.txt"} {
+		err = ioutil.WriteFile(filepath.Join(tempDir, name), []byte{}, 0644)
+		assert.Nil(t, err)
+	}
+
+	stackObj := mock.GetMockStackConfig(t, tempDir, "large", "", "local",
+		"minikube", "local", "large", "fake-region", []string{"."})
+
+	// files with the same basename are ordered by extension
+	expected := []string{
+		filepath.Join(tempDir, "values.json"),
+		filepath.Join(tempDir, "values.yaml"),
+		filepath.Join(tempDir, "large.yml"),
+		filepath.Join(tempDir, "fake-region.toml"),
+	}
+
+	providerImpl, err := New(stackObj)
+	assert.Nil(t, err)
+
+	results, err := findVarsFiles(providerImpl, stackObj)
+	assert.Nil(t, err)
+
+	assert.Equal(t, expected, results)
+}
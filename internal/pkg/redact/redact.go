@@ -0,0 +1,291 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redact
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Replaces sensitive values
+const Mask = "******"
+
+// Values shorter than this aren't masked. They're unlikely to be secrets and masking them would
+// mangle everything they appear in.
+const minLength = 4
+
+// Keys matching these patterns hold sensitive values unless others are configured
+var DefaultKeyPatterns = []string{"*password*", "*token*"}
+
+var sensitiveValues = make(map[string]bool)
+var keyPatterns = DefaultKeyPatterns
+var enabled = true
+var mutex sync.RWMutex
+
+// Marks a value as sensitive so it'll be masked
+func AddValue(value string) {
+	if len(value) < minLength {
+		return
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	sensitiveValues[value] = true
+}
+
+// Marks all the leaf values in nested maps and lists as sensitive apart from booleans
+func AddValues(data interface{}) {
+	value := reflect.ValueOf(data)
+	if !value.IsValid() {
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			AddValues(value.MapIndex(key).Interface())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			AddValues(value.Index(i).Interface())
+		}
+	case reflect.Bool:
+		return
+	default:
+		AddValue(fmt.Sprintf("%v", data))
+	}
+}
+
+// Sets the glob patterns (e.g. `*password*`) that keys of sensitive values match. Patterns are
+// matched against keys case-insensitively.
+func SetKeyPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		_, err := filepath.Match(pattern, "")
+		if err != nil {
+			return errors.Wrapf(err, "Invalid sensitive key pattern '%s'", pattern)
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	keyPatterns = patterns
+
+	return nil
+}
+
+// Returns whether a key matches any of the sensitive key patterns
+func IsSensitiveKey(key string) bool {
+	mutex.RLock()
+	patterns := keyPatterns
+	mutex.RUnlock()
+
+	key = strings.ToLower(key)
+	for _, pattern := range patterns {
+		if matched, _ := filepath.Match(strings.ToLower(pattern), key); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Marks the string values of all keys in nested maps and lists that match the sensitive key
+// patterns as sensitive. Other values (e.g. `token_ttl: 3600`) aren't secrets and masking them
+// would mangle unrelated text.
+func AddMatchingValues(data interface{}) {
+	value := reflect.ValueOf(data)
+	if !value.IsValid() {
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			child := value.MapIndex(key).Interface()
+			if IsSensitiveKey(fmt.Sprintf("%v", key.Interface())) {
+				addStringValues(child)
+			} else {
+				AddMatchingValues(child)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			AddMatchingValues(value.Index(i).Interface())
+		}
+	}
+}
+
+// Marks all the string leaf values in nested maps and lists as sensitive
+func addStringValues(data interface{}) {
+	value := reflect.ValueOf(data)
+	if !value.IsValid() {
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			addStringValues(value.MapIndex(key).Interface())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			addStringValues(value.Index(i).Interface())
+		}
+	case reflect.String:
+		AddValue(value.String())
+	}
+}
+
+// Sets whether sensitive values are masked. They're masked unless this is called with false, e.g.
+// because a user explicitly asked to see secrets.
+func SetEnabled(isEnabled bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	enabled = isEnabled
+}
+
+// Forgets all sensitive values and restores the default settings, e.g. so tests don't affect
+// each other
+func Reset() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	sensitiveValues = make(map[string]bool)
+	keyPatterns = DefaultKeyPatterns
+	enabled = true
+}
+
+// Returns the sensitive values, longest first so values containing other values are masked
+// completely
+func values() []string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+
+	if !enabled {
+		return nil
+	}
+
+	result := make([]string, 0, len(sensitiveValues))
+	for value := range sensitiveValues {
+		result = append(result, value)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if len(result[i]) != len(result[j]) {
+			return len(result[i]) > len(result[j])
+		}
+		return result[i] < result[j]
+	})
+
+	return result
+}
+
+// Returns whether the value is sensitive
+func isSensitive(value string) bool {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return enabled && sensitiveValues[value]
+}
+
+// Returns whether any of the leaf values in nested maps and lists are sensitive. Leaves are
+// compared whole, so values that merely contain a sensitive value aren't sensitive. Unlike masking
+// this doesn't depend on whether masking is enabled.
+func IsSensitiveValue(data interface{}) bool {
+	value := reflect.ValueOf(data)
+	if !value.IsValid() {
+		return false
+	}
+
+	switch value.Kind() {
+	case reflect.Map:
+		for _, key := range value.MapKeys() {
+			if IsSensitiveValue(value.MapIndex(key).Interface()) {
+				return true
+			}
+		}
+		return false
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if IsSensitiveValue(value.Index(i).Interface()) {
+				return true
+			}
+		}
+		return false
+	default:
+		mutex.RLock()
+		defer mutex.RUnlock()
+		return sensitiveValues[fmt.Sprintf("%v", data)]
+	}
+}
+
+// Masks all sensitive values in some text
+func String(text string) string {
+	for _, value := range values() {
+		text = strings.Replace(text, value, Mask, -1)
+	}
+
+	return text
+}
+
+// Returns a copy of nested maps and lists with sensitive values masked. Leaf values that are
+// sensitive are replaced entirely and sensitive values in other strings are masked.
+func Value(data interface{}) interface{} {
+	value := reflect.ValueOf(data)
+	if !value.IsValid() {
+		return data
+	}
+
+	switch value.Kind() {
+	case reflect.Map:
+		result := reflect.MakeMapWithSize(value.Type(), value.Len())
+		for _, key := range value.MapKeys() {
+			redacted := reflect.ValueOf(Value(value.MapIndex(key).Interface()))
+			if !redacted.IsValid() || !redacted.Type().AssignableTo(value.Type().Elem()) {
+				redacted = value.MapIndex(key)
+			}
+			result.SetMapIndex(key, redacted)
+		}
+		return result.Interface()
+	case reflect.Slice:
+		if value.IsNil() {
+			return data
+		}
+		result := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			redacted := reflect.ValueOf(Value(value.Index(i).Interface()))
+			if !redacted.IsValid() || !redacted.Type().AssignableTo(value.Type().Elem()) {
+				redacted = value.Index(i)
+			}
+			result.Index(i).Set(redacted)
+		}
+		return result.Interface()
+	case reflect.String:
+		if isSensitive(value.String()) {
+			return Mask
+		}
+		return String(value.String())
+	default:
+		if isSensitive(fmt.Sprintf("%v", data)) {
+			return Mask
+		}
+		return data
+	}
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package redact
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Removes all sensitive values and re-enables masking
+func TestString(t *testing.T) {
+	defer Reset()
+
+	AddValue("hunter2")
+	AddValue("hunter22")
+	AddValue("1")
+
+	assert.Equal(t, "password=****** and ******, 1 time", String("password=hunter22 and hunter2, 1 time"))
+
+	SetEnabled(false)
+	assert.Equal(t, "password=hunter2", String("password=hunter2"))
+}
+
+func TestValue(t *testing.T) {
+	defer Reset()
+
+	AddValues(map[interface{}]interface{}{
+		"db": map[interface{}]interface{}{
+			"password": "hunter2",
+			"port":     1234,
+		},
+		"flags": []interface{}{true},
+	})
+
+	data := map[string]interface{}{
+		"password": "hunter2",
+		"url":      "postgres://admin:hunter2@db",
+		"port":     1234,
+		"other":    5678,
+		"enabled":  true,
+		"list":     []interface{}{"hunter2", "public"},
+		"nested":   map[interface{}]interface{}{"key": "hunter2"},
+	}
+
+	assert.Equal(t, map[string]interface{}{
+		"password": Mask,
+		"url":      "postgres://admin:" + Mask + "@db",
+		"port":     Mask,
+		"other":    5678,
+		"enabled":  true,
+		"list":     []interface{}{Mask, "public"},
+		"nested":   map[interface{}]interface{}{"key": Mask},
+	}, Value(data))
+
+	// the original isn't modified
+	assert.Equal(t, "hunter2", data["password"])
+}
+
+func TestIsSensitiveValue(t *testing.T) {
+	defer Reset()
+
+	AddValues(map[string]interface{}{"password": "hunter2", "port": 1234})
+
+	assert.True(t, IsSensitiveValue("hunter2"))
+	assert.True(t, IsSensitiveValue(1234))
+	assert.True(t, IsSensitiveValue(map[interface{}]interface{}{
+		"passwords": []interface{}{"public", "hunter2"},
+	}))
+
+	// leaves are compared whole
+	assert.False(t, IsSensitiveValue("postgres://admin:hunter2@db"))
+	assert.False(t, IsSensitiveValue(12345))
+	assert.False(t, IsSensitiveValue(map[string]interface{}{"port": 5678, "enabled": true}))
+	assert.False(t, IsSensitiveValue(nil))
+
+	// values are still sensitive when masking is disabled
+	SetEnabled(false)
+	assert.True(t, IsSensitiveValue("hunter2"))
+}
+
+func TestAddMatchingValues(t *testing.T) {
+	defer Reset()
+
+	err := SetKeyPatterns([]string{"*password*", "api_*"})
+	assert.Nil(t, err)
+
+	AddMatchingValues(map[string]interface{}{
+		"db": map[interface{}]interface{}{
+			"user":          "admin",
+			"adminPassword": "hunter2",
+		},
+		"API_KEY":             []interface{}{"key-one", "key-two"},
+		"region":              "eu-west-1",
+		"password_min_length": 12345,
+	})
+
+	assert.Equal(t, "admin ****** ****** ****** eu-west-1",
+		String("admin hunter2 key-one key-two eu-west-1"))
+
+	// only strings under matching keys are sensitive
+	assert.Equal(t, "timeout=12345", String("timeout=12345"))
+
+	assert.Error(t, SetKeyPatterns([]string{"[unclosed"}))
+}
@@ -17,6 +17,7 @@
 package registry
 
 import (
+	"fmt"
 	"github.com/pkg/errors"
 	"github.com/sugarkube/sugarkube/internal/pkg/constants"
 	"github.com/sugarkube/sugarkube/internal/pkg/convert"
@@ -24,12 +25,22 @@ import (
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
-// A registry so that different parts of the program can set and access values
+// A registry so that different parts of the program can set and access values. It's safe for
+// concurrent use. Writes never modify existing maps. Instead they copy the maps on the path to the
+// key being changed and swap in a new root map, so maps returned by `Get` and `AsMap` are
+// immutable snapshots that can be read without locking.
 type Registry struct {
-	data map[string]interface{}
+	mutex          sync.RWMutex
+	data           map[string]interface{}
+	sources        map[string]interfaces.RegistrySource // keyed by the keys values were set with
+	listeners      map[int]func(event interfaces.RegistryEvent)
+	nextListenerId int
 }
 
 func New() interfaces.IRegistry {
@@ -41,12 +52,13 @@ func New() interfaces.IRegistry {
 		data: map[string]interface{}{
 			constants.RegistryKeyKubeConfig: kubeConfig,
 		},
+		sources: map[string]interfaces.RegistrySource{},
 	}
 }
 
 // Returns a copy of the registry
 func (r *Registry) Copy() (interfaces.IRegistry, error) {
-	newRegistry := New()
+	newRegistry := New().(*Registry)
 	for k, v := range r.AsMap() {
 		err := newRegistry.Set(k, v)
 		if err != nil {
@@ -54,24 +66,124 @@ func (r *Registry) Copy() (interfaces.IRegistry, error) {
 		}
 	}
 
+	newRegistry.sources = copySources(r.Sources())
+
 	return newRegistry, nil
 }
 
 // Add data to the registry.
 func (r *Registry) Set(key string, value interface{}) error {
+	return r.SetWithSource(key, value, interfaces.RegistrySource{Time: time.Now()})
+}
+
+// Add data to the registry, recording what set it
+func (r *Registry) SetWithSource(key string, value interface{}, source interfaces.RegistrySource) error {
 	log.Logger.Tracef("Setting registry key='%s' to value=%+v", key, value)
-	data, err := nestedMap(r.data, strings.Split(key, constants.RegistryFieldSeparator), value)
+
+	listeners, err := r.set(key, value, source)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	r.data = data
-	log.Logger.Tracef("Set registry data to: %+v", r.data)
+
+	notify(listeners, interfaces.RegistryEvent{Key: key, Value: value, Source: source})
 	return nil
 }
 
+// Sets a value while holding the lock, returning the listeners to notify of the change
+func (r *Registry) set(key string, value interface{},
+	source interfaces.RegistrySource) ([]func(event interfaces.RegistryEvent), error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	data, err := nestedMap(copyMap(r.data), strings.Split(key, constants.RegistryFieldSeparator), value, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r.data = data
+	r.sources = copySources(r.sources)
+	r.sources[key] = source
+
+	log.Logger.Tracef("Set registry data to: %+v", data)
+
+	return r.sortedListeners(), nil
+}
+
+// Returns what set each key in the registry, keyed by the keys values were set with. It's a
+// snapshot so mustn't be modified.
+func (r *Registry) Sources() map[string]interfaces.RegistrySource {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.sources
+}
+
+// Returns a copy of a map of sources
+func copySources(sources map[string]interfaces.RegistrySource) map[string]interfaces.RegistrySource {
+	result := make(map[string]interfaces.RegistrySource, len(sources)+1)
+	for k, v := range sources {
+		result[k] = v
+	}
+	return result
+}
+
+// Registers a function to be called after each change to the registry and returns a function to
+// unregister it. Listeners are called synchronously by the goroutine that made the change, so they
+// should return quickly and mustn't modify the registry.
+func (r *Registry) Subscribe(listener func(event interfaces.RegistryEvent)) func() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.listeners == nil {
+		r.listeners = make(map[int]func(event interfaces.RegistryEvent))
+	}
+
+	id := r.nextListenerId
+	r.nextListenerId++
+	r.listeners[id] = listener
+
+	return func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		delete(r.listeners, id)
+	}
+}
+
+// Returns listeners in the order they subscribed. Must be called while holding the lock.
+func (r *Registry) sortedListeners() []func(event interfaces.RegistryEvent) {
+	ids := make([]int, 0, len(r.listeners))
+	for id := range r.listeners {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	listeners := make([]func(event interfaces.RegistryEvent), 0, len(ids))
+	for _, id := range ids {
+		listeners = append(listeners, r.listeners[id])
+	}
+
+	return listeners
+}
+
+// Calls each listener with the event
+func notify(listeners []func(event interfaces.RegistryEvent), event interfaces.RegistryEvent) {
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+// Returns a shallow copy of a map
+func copyMap(data map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(data))
+	for k, v := range data {
+		result[k] = v
+	}
+	return result
+}
+
 // Inserts the given value into the data map. `elements` is a list of map keys - if the map for any
-// particular key doesn't exist a blank map will be created.
-func nestedMap(data map[string]interface{}, elements []string, value interface{}) (map[string]interface{}, error) {
+// particular key doesn't exist a blank map will be created. `parents` are the keys of the maps
+// `data` is nested in.
+func nestedMap(data map[string]interface{}, elements []string, value interface{},
+	parents []string) (map[string]interface{}, error) {
 
 	// too verbose even for tracing, so comment it out for now
 	//log.Logger.Tracef("new iteration: data=%v, elements=%v, value=%+v", data, elements, value)
@@ -83,7 +195,15 @@ func nestedMap(data map[string]interface{}, elements []string, value interface{}
 		if reflected.Kind() == reflect.Map {
 			//log.Logger.Tracef("Value is a map: %v", value)
 
-			itemMap := getMapOrNew(data, key)
+			// a map replaces any other value already at the key
+			if _, isMap := data[key].(map[string]interface{}); !isMap {
+				delete(data, key)
+			}
+
+			itemMap, err := getMapOrNew(data, key, parents)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
 
 			valueMap, ok := value.(map[string]interface{})
 			if !ok {
@@ -100,7 +220,7 @@ func nestedMap(data map[string]interface{}, elements []string, value interface{}
 			for k, v := range valueMap {
 				kParts := strings.Split(k, constants.RegistryFieldSeparator)
 				//log.Logger.Tracef("Branch 1: Running with: itemMap=%v kParts=%v, v=%v", itemMap, kParts, v)
-				result, err := nestedMap(itemMap, kParts, v)
+				result, err := nestedMap(itemMap, kParts, v, childPath(parents, key))
 				if err != nil {
 					return nil, errors.WithStack(err)
 				}
@@ -115,9 +235,12 @@ func nestedMap(data map[string]interface{}, elements []string, value interface{}
 		return data, nil
 	} else {
 		// if the map exists fetch it, otherwise create it
-		itemMap := getMapOrNew(data, key)
+		itemMap, err := getMapOrNew(data, key, parents)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
 		//log.Logger.Tracef("Branch 2: elements=%v value=%v", elements[1:], value)
-		result, err := nestedMap(itemMap, elements[1:], value)
+		result, err := nestedMap(itemMap, elements[1:], value, childPath(parents, key))
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
@@ -126,19 +249,34 @@ func nestedMap(data map[string]interface{}, elements []string, value interface{}
 	}
 }
 
-// Gets a submap from a map, or returns a new map if there is no submap
-func getMapOrNew(data map[string]interface{}, key string) map[string]interface{} {
-	_, ok := data[key]
-	if ok {
-		return data[key].(map[string]interface{})
-	} else {
-		return map[string]interface{}{}
+// Gets a copy of a submap from a map, or returns a new map if there is no submap. The submap is
+// copied because it may be part of a snapshot that's being read. Returns an error if the key holds
+// a value that isn't a map.
+func getMapOrNew(data map[string]interface{}, key string, parents []string) (map[string]interface{}, error) {
+	existing, ok := data[key]
+	if !ok {
+		return map[string]interface{}{}, nil
 	}
+
+	existingMap, ok := existing.(map[string]interface{})
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Key '%s' holds a value, not a map",
+			strings.Join(childPath(parents, key), constants.RegistryFieldSeparator)))
+	}
+
+	return copyMap(existingMap), nil
+}
+
+// Returns the path to a key in a map nested under the parent keys
+func childPath(parents []string, key string) []string {
+	path := make([]string, 0, len(parents)+1)
+	path = append(path, parents...)
+	return append(path, key)
 }
 
 // Get value from the registry. `constants.RegistryFieldSeparator` is used to separate the key into submaps
 func (r *Registry) Get(key string) (interface{}, bool) {
-	return nestedLookup(r.data, strings.Split(key, constants.RegistryFieldSeparator))
+	return nestedLookup(r.AsMap(), strings.Split(key, constants.RegistryFieldSeparator))
 }
 
 // Gets a value from a nested map. Also returns a boolean indicating whether the value was found in
@@ -153,11 +291,11 @@ func nestedLookup(data map[string]interface{}, elements []string) (interface{},
 		}
 		return val, true
 	} else {
-		// see if the key is in the map
-		subMap, ok := data[key]
+		// see if the key is in the map and is a submap
+		subMap, ok := data[key].(map[string]interface{})
 		if ok {
 			// yes, so recurse into the submap
-			return nestedLookup(subMap.(map[string]interface{}), elements[1:])
+			return nestedLookup(subMap, elements[1:])
 		} else {
 			// not found
 			return nil, false
@@ -165,8 +303,11 @@ func nestedLookup(data map[string]interface{}, elements []string) (interface{},
 	}
 }
 
-// Return the registry as a map
-func (r Registry) AsMap() map[string]interface{} {
+// Return a snapshot of the registry as a map. It won't be changed by later writes to the registry
+// so it mustn't be modified. Copy it first if it needs to be changed (e.g. by merging into it).
+func (r *Registry) AsMap() map[string]interface{} {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
 	return r.data
 }
 
@@ -174,23 +315,87 @@ func (r Registry) AsMap() map[string]interface{} {
 // be traversed
 func (r *Registry) Delete(key string) {
 	log.Logger.Tracef("Deleting key='%s' from the registry", key)
-	nestedDelete(r.data, strings.Split(key, constants.RegistryFieldSeparator))
-	log.Logger.Tracef("Registry data after deletion is: %+v", r.data)
+
+	r.mutex.Lock()
+	data, deleted := nestedDelete(r.data, strings.Split(key, constants.RegistryFieldSeparator))
+	r.data = data
+	if deleted {
+		r.sources = deleteSources(r.sources, key)
+	}
+	listeners := r.sortedListeners()
+	r.mutex.Unlock()
+
+	log.Logger.Tracef("Registry data after deletion is: %+v", data)
+
+	if deleted {
+		notify(listeners, interfaces.RegistryEvent{Key: key, Deleted: true})
+	}
+}
+
+// Returns a copy of a map of sources without the sources of the key or any keys under it
+func deleteSources(sources map[string]interfaces.RegistrySource,
+	key string) map[string]interfaces.RegistrySource {
+	result := make(map[string]interfaces.RegistrySource, len(sources))
+	for k, v := range sources {
+		if k != key && !strings.HasPrefix(k, key+constants.RegistryFieldSeparator) {
+			result[k] = v
+		}
+	}
+	return result
 }
 
-// Deletes a value from a map, traversing submaps as necessary
-func nestedDelete(data map[string]interface{}, elements []string) {
+// Returns a copy of a map without the given value, traversing submaps as necessary. Also returns
+// whether the value was found.
+func nestedDelete(data map[string]interface{}, elements []string) (map[string]interface{}, bool) {
 	key := elements[0]
 
 	item, ok := data[key]
 	if !ok {
-		return
+		return data, false
 	}
 
 	if len(elements) == 1 {
-		delete(data, key)
-	} else {
-		nestedDelete(item.(map[string]interface{}), elements[1:])
-		return
+		result := copyMap(data)
+		delete(result, key)
+		return result, true
+	}
+
+	subMap, ok := item.(map[string]interface{})
+	if !ok {
+		return data, false
+	}
+
+	newSubMap, deleted := nestedDelete(subMap, elements[1:])
+	if !deleted {
+		return data, false
+	}
+
+	result := copyMap(data)
+	result[key] = newSubMap
+	return result, true
+}
+
+// top-level keys kapps may not set values under with an output's `registry_path`
+var protectedKeys = []string{
+	constants.RegistryKeyOutputs,
+	constants.RegistryKeyKubeConfig,
+	constants.RegistryKeyThis,
+}
+
+// Returns an error if a path kapps want to publish outputs to is invalid or protected
+func ValidatePath(path string) error {
+	for _, element := range strings.Split(path, constants.RegistryFieldSeparator) {
+		if element == "" {
+			return errors.New(fmt.Sprintf("Registry path '%s' contains an empty element", path))
+		}
 	}
+
+	for _, protectedKey := range protectedKeys {
+		if HasPrefix(path, protectedKey) {
+			return errors.New(fmt.Sprintf("Registry path '%s' is protected. Outputs can't be "+
+				"published under any of: %s", path, strings.Join(protectedKeys, ", ")))
+		}
+	}
+
+	return nil
 }
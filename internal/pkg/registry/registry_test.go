@@ -17,10 +17,14 @@
 package registry
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
 	"os"
+	"sync"
 	"testing"
+	"time"
 )
 
 func init() {
@@ -158,6 +162,28 @@ func TestNestedMapInterfaceInterface(t *testing.T) {
 	assert.True(t, ok)
 }
 
+func TestSetUnderValue(t *testing.T) {
+	registry := New()
+	assert.Nil(t, registry.Set("a.b", "1"))
+
+	err := registry.Set("a.b.c", "2")
+	assert.EqualError(t, err, "Key 'a.b' holds a value, not a map")
+
+	err = registry.Set("a", map[string]interface{}{"b.c": "2"})
+	assert.EqualError(t, err, "Key 'a.b' holds a value, not a map")
+
+	// the registry isn't left locked or changed
+	value, ok := registry.Get("a.b")
+	assert.True(t, ok)
+	assert.Equal(t, "1", value)
+
+	// maps can replace values
+	assert.Nil(t, registry.Set("a.b", map[string]interface{}{"c": "2"}))
+	value, ok = registry.Get("a.b.c")
+	assert.True(t, ok)
+	assert.Equal(t, "2", value)
+}
+
 func TestDelete(t *testing.T) {
 	registry := New()
 
@@ -205,3 +231,146 @@ func TestDelete(t *testing.T) {
 	}, val)
 	assert.True(t, ok)
 }
+
+// Test that snapshots returned by AsMap and Get aren't changed by later writes
+func TestSnapshots(t *testing.T) {
+	registry := New()
+
+	err := registry.Set("outputs.kapp.a", 1)
+	assert.Nil(t, err)
+
+	snapshot := registry.AsMap()
+	outputs, ok := registry.Get("outputs.kapp")
+	assert.True(t, ok)
+
+	err = registry.Set("outputs.kapp.b", 2)
+	assert.Nil(t, err)
+	registry.Delete("outputs.kapp.a")
+
+	assert.Equal(t, map[string]interface{}{"a": 1}, outputs)
+	assert.Equal(t, map[string]interface{}{"kapp": map[string]interface{}{"a": 1}},
+		snapshot["outputs"])
+
+	val, ok := registry.Get("outputs.kapp")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"b": 2}, val)
+}
+
+func TestSubscribe(t *testing.T) {
+	registry := New()
+
+	events := make([]interfaces.RegistryEvent, 0)
+	unsubscribe := registry.Subscribe(func(event interfaces.RegistryEvent) {
+		events = append(events, event)
+	})
+
+	source := interfaces.RegistrySource{Kapp: "manifest:kapp", Time: time.Unix(1570000000, 0)}
+	err := registry.SetWithSource("outputs.kapp.a", 1, source)
+	assert.Nil(t, err)
+	registry.Delete("outputs.kapp.a")
+
+	// deleting missing keys doesn't notify listeners
+	registry.Delete("outputs.kapp.missing")
+
+	unsubscribe()
+	err = registry.Set("outputs.kapp.b", 2)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []interfaces.RegistryEvent{
+		{Key: "outputs.kapp.a", Value: 1, Source: source},
+		{Key: "outputs.kapp.a", Deleted: true},
+	}, events)
+}
+
+func TestSources(t *testing.T) {
+	registry := New()
+
+	source := interfaces.RegistrySource{Kapp: "manifest:kapp", Time: time.Unix(1570000000, 0)}
+	assert.Nil(t, registry.SetWithSource("outputs.kapp.a", 1, source))
+	assert.Nil(t, registry.SetWithSource("outputs.kapp.b", 2, source))
+	assert.Nil(t, registry.Set("outputs.other", 3))
+
+	sources := registry.Sources()
+	assert.Equal(t, source, sources["outputs.kapp.a"])
+	assert.Equal(t, source, sources["outputs.kapp.b"])
+	assert.Equal(t, "", sources["outputs.other"].Kapp)
+	assert.False(t, sources["outputs.other"].Time.IsZero())
+
+	// deleting a key deletes the sources of keys under it
+	registry.Delete("outputs.kapp")
+	sources = registry.Sources()
+	assert.Len(t, sources, 1)
+	_, ok := sources["outputs.other"]
+	assert.True(t, ok)
+}
+
+// Run with -race to detect unsynchronised access
+func TestConcurrentAccess(t *testing.T) {
+	registry := New()
+
+	numWriters := 10
+	numWrites := 50
+
+	mutex := sync.Mutex{}
+	numEvents := 0
+	unsubscribe := registry.Subscribe(func(event interfaces.RegistryEvent) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		numEvents++
+	})
+	defer unsubscribe()
+
+	wg := sync.WaitGroup{}
+
+	for i := 0; i < numWriters; i++ {
+		wg.Add(2)
+
+		go func(writer int) {
+			defer wg.Done()
+			for j := 0; j < numWrites; j++ {
+				err := registry.Set(fmt.Sprintf("outputs.kapp%d.out%d", writer, j),
+					map[string]interface{}{"value": j})
+				assert.Nil(t, err)
+			}
+			registry.Delete(fmt.Sprintf("outputs.kapp%d.out0", writer))
+		}(i)
+
+		// read snapshots while they're being written
+		go func() {
+			defer wg.Done()
+			for j := 0; j < numWrites; j++ {
+				for _, kapp := range registry.AsMap() {
+					if kappMap, ok := kapp.(map[string]interface{}); ok {
+						for range kappMap {
+						}
+					}
+				}
+				registry.Get("outputs.kapp0")
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	outputs, ok := registry.Get("outputs")
+	assert.True(t, ok)
+	assert.Len(t, outputs, numWriters)
+
+	for i := 0; i < numWriters; i++ {
+		kappOutputs, ok := registry.Get(fmt.Sprintf("outputs.kapp%d", i))
+		assert.True(t, ok)
+		assert.Len(t, kappOutputs, numWrites-1)
+	}
+
+	assert.Equal(t, numWriters*(numWrites+1), numEvents)
+}
+
+func TestValidatePath(t *testing.T) {
+	assert.Nil(t, ValidatePath("network.vpc"))
+	assert.Nil(t, ValidatePath("outputs_vpc"))
+	assert.Nil(t, ValidatePath("thisvpc"))
+
+	for _, path := range []string{"outputs", "outputs.kapp.vpc", "kubeconfig", "this.vpc", "network..vpc", ""} {
+		assert.NotNil(t, ValidatePath(path), path)
+	}
+}
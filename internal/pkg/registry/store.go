@@ -0,0 +1,372 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package registry
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
+	"github.com/sugarkube/sugarkube/internal/pkg/constants"
+	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
+	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
+	"github.com/sugarkube/sugarkube/internal/pkg/secrets"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// persisted registries are stored in this directory relative to the workspace
+const storeDir = ".sugarkube/registry"
+const storeFileExtension = ".yaml"
+const storeMode = 0600
+
+// The environment variable the passphrase for encrypted registries is read from
+var PassphraseEnvVar = config.EnvPrefix + "_REGISTRY_PASSPHRASE"
+
+// A value in the registry along with what set it
+type Entry struct {
+	Key       string      `yaml:"-" json:"key"`
+	Value     interface{} `yaml:"value" json:"value"`
+	Kapp      string      `yaml:"kapp,omitempty" json:"kapp,omitempty"`
+	Time      time.Time   `yaml:"time" json:"time"`
+	Sensitive bool        `yaml:"sensitive,omitempty" json:"sensitive,omitempty"`
+}
+
+// The contents of a persisted registry. If it's encrypted, Encrypted contains the encrypted YAML
+// of the entries.
+type storedRegistry struct {
+	Entries   map[string]Entry `yaml:"entries,omitempty"`
+	Encrypted string           `yaml:"encrypted,omitempty"`
+}
+
+// serialises writes to registry files
+var saveMutex sync.Mutex
+
+// Returns the path to the file the registry for a stack and cluster is persisted to in a workspace
+func StorePath(workspaceDir string, stackName string, cluster string) (string, error) {
+	absDir, err := filepath.Abs(workspaceDir)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return filepath.Join(absDir, storeDir, stackName, cluster+storeFileExtension), nil
+}
+
+// Returns each value set in the registry along with what set it, sorted by key. Values that are
+// (or contain) sensitive values are flagged.
+func Entries(registryObj interfaces.IRegistry) []Entry {
+	sources := registryObj.Sources()
+
+	keys := make([]string, 0, len(sources))
+	for key := range sources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	entries := make([]Entry, 0, len(keys))
+	for _, key := range keys {
+		// values may have been deleted from under the key they were set with
+		value, ok := registryObj.Get(key)
+		if !ok {
+			continue
+		}
+
+		entries = append(entries, Entry{
+			Key:       key,
+			Value:     value,
+			Kapp:      sources[key].Kapp,
+			Time:      sources[key].Time,
+			Sensitive: redact.IsSensitiveValue(value),
+		})
+	}
+
+	return entries
+}
+
+// Loads entries persisted at the path into the registry. Does nothing if the file doesn't exist.
+func Load(registryObj interfaces.IRegistry, path string) error {
+	return load(registryObj, path, false)
+}
+
+// Loads entries persisted at the path into the registry, optionally skipping kapps' outputs so
+// their output steps are run again
+func load(registryObj interfaces.IRegistry, path string, skipOutputs bool) error {
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Logger.Debugf("No registry persisted at '%s'", path)
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Error reading registry file '%s'", path)
+	}
+
+	stored := storedRegistry{}
+	err = yaml.Unmarshal(contents, &stored)
+	if err != nil {
+		return errors.Wrapf(err, "Error parsing registry file '%s'", path)
+	}
+
+	if stored.Encrypted != "" {
+		stored, err = decryptStore(stored.Encrypted)
+		if err != nil {
+			return errors.Wrapf(err, "Error decrypting registry file '%s'", path)
+		}
+	}
+
+	keys := make([]string, 0, len(stored.Entries))
+	for key := range stored.Entries {
+		keys = append(keys, key)
+	}
+
+	// set parents before their children so children aren't overwritten
+	sort.Strings(keys)
+
+	numLoaded := 0
+	for _, key := range keys {
+		if skipOutputs && HasPrefix(key, constants.RegistryKeyOutputs) {
+			log.Logger.Debugf("Not loading registry key '%s' so outputs are refreshed", key)
+			continue
+		}
+
+		entry := stored.Entries[key]
+		if entry.Sensitive {
+			redact.AddValues(entry.Value)
+		}
+
+		err = registryObj.SetWithSource(key, entry.Value, interfaces.RegistrySource{
+			Kapp: entry.Kapp,
+			Time: entry.Time,
+		})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		numLoaded++
+	}
+
+	log.Logger.Infof("Loaded %d registry entries from '%s'", numLoaded, path)
+
+	return nil
+}
+
+// Writes the registry's entries to the path. Sensitive values are only written if the file is
+// encrypted.
+func Save(registryObj interfaces.IRegistry, path string, encrypt bool) error {
+	saveMutex.Lock()
+	defer saveMutex.Unlock()
+
+	stored := storedRegistry{Entries: map[string]Entry{}}
+	sources := registryObj.Sources()
+
+	for _, entry := range Entries(registryObj) {
+		// don't persist values set during dry runs or missing outputs so they aren't reused
+		if sources[entry.Key].DryRun || entry.Value == nil {
+			log.Logger.Debugf("Not persisting registry key '%s'", entry.Key)
+			continue
+		}
+
+		if entry.Sensitive && !encrypt {
+			log.Logger.Debugf("Not persisting sensitive registry key '%s' because the registry "+
+				"isn't encrypted", entry.Key)
+			continue
+		}
+		stored.Entries[entry.Key] = entry
+	}
+
+	contents, err := yaml.Marshal(stored)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if encrypt {
+		encrypted, err := encryptStore(contents)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		contents, err = yaml.Marshal(storedRegistry{Encrypted: encrypted})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	err = writeFileAtomically(path, contents)
+	if err != nil {
+		return errors.Wrapf(err, "Error writing registry file '%s'", path)
+	}
+
+	return nil
+}
+
+// Saves a registry to a file after it's changed. Saving can be slow (e.g. deriving the key to
+// encrypt it), so changes are only recorded as they're made and saved together by Flush.
+type Persister struct {
+	registryObj interfaces.IRegistry
+	path        string
+	encrypt     bool
+	dirty       bool
+	mutex       sync.Mutex
+}
+
+// Loads entries persisted at the path into the registry and returns a Persister to save the
+// registry there after it changes. If refreshOutputs is true, persisted outputs aren't loaded so
+// kapps' output steps are run again and the new outputs replace them.
+func Persist(registryObj interfaces.IRegistry, path string, encrypt bool, refreshOutputs bool) (*Persister, error) {
+	err := load(registryObj, path, refreshOutputs)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	persister := &Persister{
+		registryObj: registryObj,
+		path:        path,
+		encrypt:     encrypt,
+	}
+
+	registryObj.Subscribe(func(event interfaces.RegistryEvent) {
+		// dry runs shouldn't change the persisted registry
+		if event.Source.DryRun {
+			return
+		}
+
+		persister.mutex.Lock()
+		defer persister.mutex.Unlock()
+		persister.dirty = true
+	})
+
+	return persister, nil
+}
+
+// Returns the path the registry is saved to
+func (p *Persister) Path() string {
+	return p.path
+}
+
+// Saves the registry if it's changed since it was loaded or last saved
+func (p *Persister) Flush() error {
+	p.mutex.Lock()
+	if !p.dirty {
+		p.mutex.Unlock()
+		return nil
+	}
+	p.dirty = false
+	p.mutex.Unlock()
+
+	err := Save(p.registryObj, p.path, p.encrypt)
+	if err != nil {
+		// try again next time
+		p.mutex.Lock()
+		p.dirty = true
+		p.mutex.Unlock()
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// Returns whether a key is the given key or under it
+func HasPrefix(key string, prefix string) bool {
+	return prefix == "" || key == prefix ||
+		strings.HasPrefix(key, prefix+constants.RegistryFieldSeparator)
+}
+
+// Returns the passphrase for encrypting registries
+func passphrase() (string, error) {
+	passphrase := os.Getenv(PassphraseEnvVar)
+	if passphrase == "" {
+		return "", errors.New(fmt.Sprintf("Set %s to the passphrase for encrypting the registry",
+			PassphraseEnvVar))
+	}
+
+	return passphrase, nil
+}
+
+// Encrypts the YAML of a registry's entries, returning it base64 encoded
+func encryptStore(contents []byte) (string, error) {
+	passphrase, err := passphrase()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	encrypted, err := secrets.Encrypt(contents, passphrase)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return base64.StdEncoding.EncodeToString(encrypted), nil
+}
+
+// Decrypts entries encrypted by encryptStore
+func decryptStore(encrypted string) (storedRegistry, error) {
+	stored := storedRegistry{}
+
+	passphrase, err := passphrase()
+	if err != nil {
+		return stored, errors.WithStack(err)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return stored, errors.WithStack(err)
+	}
+
+	contents, err := secrets.Decrypt(decoded, passphrase)
+	if err != nil {
+		return stored, errors.Wrapf(err, "Is the passphrase in %s correct?", PassphraseEnvVar)
+	}
+
+	err = yaml.Unmarshal(contents, &stored)
+	if err != nil {
+		return stored, errors.WithStack(err)
+	}
+
+	return stored, nil
+}
+
+// Writes to a temporary file and renames it so the file is never partially written
+func writeFileAtomically(path string, contents []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	_, err = tempFile.Write(contents)
+	if err == nil {
+		err = tempFile.Chmod(storeMode)
+	}
+	closeErr := tempFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tempFile.Name())
+		return errors.WithStack(err)
+	}
+
+	return os.Rename(tempFile.Name(), path)
+}
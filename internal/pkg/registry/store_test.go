@@ -0,0 +1,276 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package registry
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func tempStorePath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "registry-store-")
+	assert.Nil(t, err)
+
+	path, err := StorePath(dir, "test-stack", "test-cluster")
+	assert.Nil(t, err)
+
+	return path, func() { _ = os.RemoveAll(dir) }
+}
+
+func TestStorePath(t *testing.T) {
+	path, err := StorePath("/tmp/workspace", "dev", "cluster1")
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.FromSlash("/tmp/workspace/.sugarkube/registry/dev/cluster1.yaml"), path)
+}
+
+func TestSaveLoad(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	setAt := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	original := New()
+	err := original.SetWithSource("outputs.prelaunch__bucket.name", "my-bucket",
+		interfaces.RegistrySource{Kapp: "prelaunch:bucket", Time: setAt})
+	assert.Nil(t, err)
+	err = original.SetWithSource("outputs.prelaunch__bucket.region", "eu-west-1",
+		interfaces.RegistrySource{Kapp: "prelaunch:bucket", Time: setAt})
+	assert.Nil(t, err)
+
+	err = Save(original, path, false)
+	assert.Nil(t, err)
+
+	info, err := os.Stat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(storeMode), info.Mode().Perm())
+
+	loaded := New()
+	err = Load(loaded, path)
+	assert.Nil(t, err)
+
+	value, ok := loaded.Get("outputs.prelaunch__bucket")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]interface{}{
+		"name":   "my-bucket",
+		"region": "eu-west-1",
+	}, value)
+
+	assert.Equal(t, Entries(original), Entries(loaded))
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	registryObj := New()
+	err := Load(registryObj, path)
+	assert.Nil(t, err)
+	assert.Empty(t, Entries(registryObj))
+}
+
+func TestSaveSkipsSensitiveValuesUnencrypted(t *testing.T) {
+	defer redact.Reset()
+
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	redact.AddValue("registry-store-secret")
+
+	original := New()
+	assert.Nil(t, original.Set("outputs.kapp.password", "registry-store-secret"))
+	assert.Nil(t, original.Set("outputs.kapp.user", "admin"))
+
+	err := Save(original, path, false)
+	assert.Nil(t, err)
+
+	contents, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(contents), "registry-store-secret")
+
+	loaded := New()
+	assert.Nil(t, Load(loaded, path))
+
+	_, ok := loaded.Get("outputs.kapp.password")
+	assert.False(t, ok)
+	value, ok := loaded.Get("outputs.kapp.user")
+	assert.True(t, ok)
+	assert.Equal(t, "admin", value)
+}
+
+// numbers under sensitive keys aren't secrets so outputs containing them are still saved
+func TestSaveKeepsValuesMatchingSensitiveNumbers(t *testing.T) {
+	defer redact.Reset()
+
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	redact.AddMatchingValues(map[string]interface{}{"token_ttl": 3600})
+
+	original := New()
+	assert.Nil(t, original.Set("outputs.kapp.timeout", 3600))
+	assert.Nil(t, original.Set("outputs.kapp.description", "expires after 3600 seconds"))
+
+	assert.Equal(t, "timeout=3600", redact.String("timeout=3600"))
+	assert.Nil(t, Save(original, path, false))
+
+	loaded := New()
+	assert.Nil(t, Load(loaded, path))
+
+	value, ok := loaded.Get("outputs.kapp")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]interface{}{
+		"timeout":     3600,
+		"description": "expires after 3600 seconds",
+	}, value)
+}
+
+func TestSaveLoadEncrypted(t *testing.T) {
+	defer redact.Reset()
+
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	assert.Nil(t, os.Setenv(PassphraseEnvVar, "test-passphrase"))
+	defer func() { _ = os.Unsetenv(PassphraseEnvVar) }()
+
+	redact.AddValue("registry-store-encrypted")
+
+	original := New()
+	assert.Nil(t, original.Set("outputs.kapp.password", "registry-store-encrypted"))
+
+	err := Save(original, path, true)
+	assert.Nil(t, err)
+
+	contents, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(contents), "registry-store-encrypted")
+	assert.NotContains(t, string(contents), "outputs.kapp.password")
+
+	loaded := New()
+	assert.Nil(t, Load(loaded, path))
+
+	value, ok := loaded.Get("outputs.kapp.password")
+	assert.True(t, ok)
+	assert.Equal(t, "registry-store-encrypted", value)
+
+	assert.Nil(t, os.Setenv(PassphraseEnvVar, "wrong-passphrase"))
+	assert.NotNil(t, Load(New(), path))
+}
+
+func TestPersist(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	registryObj := New()
+	persister, err := Persist(registryObj, path, false, false)
+	assert.Nil(t, err)
+
+	assert.Nil(t, registryObj.Set("outputs.kapp.name", "value"))
+
+	// changes aren't saved until they're flushed
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+	assert.Nil(t, persister.Flush())
+
+	loaded := New()
+	assert.Nil(t, Load(loaded, path))
+	value, ok := loaded.Get("outputs.kapp.name")
+	assert.True(t, ok)
+	assert.Equal(t, "value", value)
+
+	registryObj.Delete("outputs.kapp")
+	assert.Nil(t, persister.Flush())
+
+	loaded = New()
+	assert.Nil(t, Load(loaded, path))
+	_, ok = loaded.Get("outputs.kapp.name")
+	assert.False(t, ok)
+}
+
+func TestHasPrefix(t *testing.T) {
+	assert.True(t, HasPrefix("outputs.kapp", ""))
+	assert.True(t, HasPrefix("outputs.kapp", "outputs.kapp"))
+	assert.True(t, HasPrefix("outputs.kapp.name", "outputs.kapp"))
+	assert.False(t, HasPrefix("outputs.kapp2", "outputs.kapp"))
+	assert.False(t, HasPrefix("outputs", "outputs.kapp"))
+}
+
+func TestPersistSkipsDryRunsAndMissingValues(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	registryObj := New()
+	persister, err := Persist(registryObj, path, false, false)
+	assert.Nil(t, err)
+
+	// changes during dry runs don't save the registry
+	err = registryObj.SetWithSource("outputs.kapp.synthetic", "value", interfaces.RegistrySource{
+		Kapp:   "manifest:kapp",
+		Time:   time.Now(),
+		DryRun: true,
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, persister.Flush())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Nil(t, registryObj.Set("outputs.kapp.missing", nil))
+	assert.Nil(t, registryObj.Set("outputs.kapp.real", "value"))
+	assert.Nil(t, persister.Flush())
+
+	loaded := New()
+	assert.Nil(t, Load(loaded, path))
+	value, ok := loaded.Get("outputs.kapp")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]interface{}{"real": "value"}, value)
+}
+
+func TestPersistRefreshingOutputs(t *testing.T) {
+	path, cleanup := tempStorePath(t)
+	defer cleanup()
+
+	registryObj := New()
+	persister, err := Persist(registryObj, path, false, false)
+	assert.Nil(t, err)
+	assert.Nil(t, registryObj.Set("outputs.kapp.name", "old"))
+	assert.Nil(t, registryObj.Set("other", "value"))
+	assert.Nil(t, persister.Flush())
+
+	refreshed := New()
+	persister, err = Persist(refreshed, path, false, true)
+	assert.Nil(t, err)
+	_, ok := refreshed.Get("outputs.kapp.name")
+	assert.False(t, ok)
+	value, ok := refreshed.Get("other")
+	assert.True(t, ok)
+	assert.Equal(t, "value", value)
+
+	// new outputs replace the persisted ones
+	assert.Nil(t, refreshed.Set("outputs.kapp.name", "new"))
+	assert.Nil(t, persister.Flush())
+
+	loaded := New()
+	assert.Nil(t, Load(loaded, path))
+	value, ok = loaded.Get("outputs.kapp.name")
+	assert.True(t, ok)
+	assert.Equal(t, "new", value)
+}
@@ -0,0 +1,810 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A value that doesn't conform to a schema
+type ValidationError struct {
+	Path    []string // path to the invalid value. List indices are formatted as `[i]`
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s: %s", FormatPath(e.Path), e.Message)
+}
+
+// Returns a path as a string, e.g. `db.hosts[0].port`
+func FormatPath(path []string) string {
+	formatted := ""
+	for _, element := range path {
+		if formatted != "" && !strings.HasPrefix(element, "[") {
+			formatted += "."
+		}
+		formatted += element
+	}
+
+	return formatted
+}
+
+// Validates a value against a JSON schema, returning all the ways it doesn't conform. An error is
+// returned if the schema itself is invalid. Only the subset of JSON Schema that's useful for
+// describing vars is supported: `type`, `enum`, `const`, `required`, `properties`,
+// `additionalProperties`, `items`, `minimum`, `maximum`, `exclusiveMinimum`, `exclusiveMaximum`,
+// `minLength`, `maxLength`, `pattern`, `minItems` and `maxItems`, along with the annotations in
+// `annotationKeywords`. Schemas using any other keywords are invalid rather than silently not being
+// enforced.
+func Validate(schema interface{}, value interface{}) ([]ValidationError, error) {
+	validationErrors := make([]ValidationError, 0)
+
+	err := checkKeywords(schema, []string{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	err = validate(schema, value, []string{}, &validationErrors)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return validationErrors, nil
+}
+
+// keywords that are validated
+var validationKeywords = []string{"type", "enum", "const", "required", "properties",
+	"additionalProperties", "items", "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum",
+	"minLength", "maxLength", "pattern", "minItems", "maxItems"}
+
+// keywords that don't affect validation so can be used anywhere
+var annotationKeywords = []string{"$schema", "$id", "$comment", "title", "description", "default",
+	"examples", "deprecated", "readOnly", "writeOnly"}
+
+// Returns an error if a schema or any of its subschemas use keywords that aren't supported
+func checkKeywords(schema interface{}, path []string) error {
+	schemaMap, ok := toMap(schema)
+	if !ok {
+		// other errors are returned when the schema is used
+		return nil
+	}
+
+	for _, keyword := range sortedKeys(schemaMap) {
+		if !contains(validationKeywords, keyword) && !contains(annotationKeywords, keyword) {
+			return errors.New(fmt.Sprintf("Unsupported keyword '%s' in schema at '%s'. Supported "+
+				"keywords are: %s", keyword, FormatPath(path), strings.Join(validationKeywords, ", ")))
+		}
+	}
+
+	properties, _ := toMap(schemaMap["properties"])
+	for _, name := range sortedKeys(properties) {
+		err := checkKeywords(properties[name], appendPath(path, name))
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	for _, keyword := range []string{"items", "additionalProperties"} {
+		if subschema, ok := schemaMap[keyword]; ok {
+			err := checkKeywords(subschema, appendPath(path, keyword))
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns a copy of a value with strings converted to the boolean, integer or number type the
+// schema expects if they can be parsed as one. Templated values are always strings, so this allows
+// validating e.g. `enabled: '{{ .some.flag }}'` against `type: boolean`.
+func Coerce(schema interface{}, value interface{}) interface{} {
+	schemaMap, ok := toMap(schema)
+	if !ok {
+		return value
+	}
+
+	switch typed := value.(type) {
+	case string:
+		allowed, err := toStrings(schemaMap["type"])
+		if err != nil || contains(allowed, "string") {
+			return value
+		}
+
+		for _, typeName := range allowed {
+			switch typeName {
+			case "boolean":
+				if parsed, err := strconv.ParseBool(typed); err == nil {
+					return parsed
+				}
+			case "integer":
+				if parsed, err := strconv.Atoi(typed); err == nil {
+					return parsed
+				}
+			case "number":
+				if parsed, err := strconv.Atoi(typed); err == nil {
+					return parsed
+				}
+				if parsed, err := strconv.ParseFloat(typed, 64); err == nil {
+					return parsed
+				}
+			}
+		}
+	case []interface{}:
+		items, ok := schemaMap["items"]
+		if !ok {
+			return value
+		}
+
+		output := make([]interface{}, len(typed))
+		for i, item := range typed {
+			output[i] = Coerce(items, item)
+		}
+		return output
+	case map[interface{}]interface{}:
+		output := make(map[interface{}]interface{}, len(typed))
+		for key, child := range typed {
+			output[key] = Coerce(propertySchema(schemaMap, fmt.Sprintf("%v", key)), child)
+		}
+		return output
+	case map[string]interface{}:
+		output := make(map[string]interface{}, len(typed))
+		for key, child := range typed {
+			output[key] = Coerce(propertySchema(schemaMap, key), child)
+		}
+		return output
+	}
+
+	return value
+}
+
+// Returns the schema for a property of an object, or nil if it doesn't have one
+func propertySchema(schema map[string]interface{}, name string) interface{} {
+	properties, _ := toMap(schema["properties"])
+	if propertySchema, ok := properties[name]; ok {
+		return propertySchema
+	}
+
+	return schema["additionalProperties"]
+}
+
+// Returns a copy of the value with defaults from the schema set for any properties that are
+// missing, along with the paths of the values that were defaulted. Defaults are only applied to
+// objects that exist.
+func ApplyDefaults(schema interface{}, value interface{}) (interface{}, [][]string) {
+	applied := make([][]string, 0)
+	return applyDefaults(schema, value, []string{}, &applied), applied
+}
+
+func applyDefaults(schema interface{}, value interface{}, path []string, applied *[][]string) interface{} {
+	schemaMap, ok := toMap(schema)
+	if !ok {
+		return value
+	}
+
+	properties, ok := toMap(schemaMap["properties"])
+	if !ok {
+		return value
+	}
+
+	// copy the map so the original isn't modified, keeping the type of its keys
+	var output interface{}
+	var get func(name string) (interface{}, bool)
+	var set func(name string, child interface{})
+
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		copied := make(map[interface{}]interface{}, len(typed))
+		for key, child := range typed {
+			copied[key] = child
+		}
+		output = copied
+		get = func(name string) (interface{}, bool) {
+			child, ok := copied[name]
+			return child, ok
+		}
+		set = func(name string, child interface{}) {
+			copied[name] = child
+		}
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(typed))
+		for key, child := range typed {
+			copied[key] = child
+		}
+		output = copied
+		get = func(name string) (interface{}, bool) {
+			child, ok := copied[name]
+			return child, ok
+		}
+		set = func(name string, child interface{}) {
+			copied[name] = child
+		}
+	default:
+		return value
+	}
+
+	for _, name := range sortedKeys(properties) {
+		propertyPath := appendPath(path, name)
+
+		child, exists := get(name)
+		if exists {
+			set(name, applyDefaults(properties[name], child, propertyPath, applied))
+			continue
+		}
+
+		if propertyDefault, ok := defaultOf(properties[name]); ok {
+			set(name, propertyDefault)
+			*applied = append(*applied, propertyPath)
+		}
+	}
+
+	return output
+}
+
+// Returns the default value declared in a schema, if any
+func defaultOf(schema interface{}) (interface{}, bool) {
+	schemaMap, ok := toMap(schema)
+	if !ok {
+		return nil, false
+	}
+
+	value, ok := schemaMap["default"]
+	return value, ok
+}
+
+// the placeholder for generated strings
+const generatedString = "dry-run"
+
+// Returns a synthetic value conforming to a schema, e.g. so templates that use outputs can be
+// rendered during dry runs. Values declared with `default`, `const`, `enum` or `examples` are used
+// if present. Otherwise values are generated from the schema's `type`: objects have all their
+// declared properties, arrays have `minItems` items (or one) and numbers are their minimum (or 0).
+func Generate(schema interface{}) (interface{}, error) {
+	err := checkKeywords(schema, []string{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return generate(schema, []string{})
+}
+
+func generate(schema interface{}, path []string) (interface{}, error) {
+	if _, ok := schema.(bool); ok {
+		return nil, nil
+	}
+
+	schemaMap, ok := toMap(schema)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Invalid schema at '%s'. Schemas must be maps or booleans "+
+			"but got: %#v", FormatPath(path), schema))
+	}
+
+	if value, ok := schemaMap["default"]; ok {
+		return value, nil
+	}
+
+	if value, ok := schemaMap["const"]; ok {
+		return value, nil
+	}
+
+	for _, keyword := range []string{"enum", "examples"} {
+		if options, ok := schemaMap[keyword].([]interface{}); ok && len(options) > 0 {
+			return options[0], nil
+		}
+	}
+
+	typeName := ""
+	if types, ok := schemaMap["type"]; ok {
+		allowed, err := toStrings(types)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid 'type' in schema at '%s'", FormatPath(path))
+		}
+
+		// prefer generating an actual value to null
+		for _, allowedType := range allowed {
+			typeName = allowedType
+			if allowedType != "null" {
+				break
+			}
+		}
+	} else if _, ok := schemaMap["properties"]; ok {
+		typeName = "object"
+	} else if _, ok := schemaMap["items"]; ok {
+		typeName = "array"
+	}
+
+	switch typeName {
+	case "object":
+		object := map[string]interface{}{}
+		properties, _ := toMap(schemaMap["properties"])
+		for _, name := range sortedKeys(properties) {
+			value, err := generate(properties[name], appendPath(path, name))
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			object[name] = value
+		}
+		return object, nil
+	case "array":
+		numItems := 1
+		if minItems, ok := toFloat(schemaMap["minItems"]); ok {
+			numItems = int(minItems)
+		}
+
+		list := make([]interface{}, 0, numItems)
+		items, hasItems := schemaMap["items"]
+		for i := 0; i < numItems && hasItems; i++ {
+			value, err := generate(items, appendPath(path, fmt.Sprintf("[%d]", i)))
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			list = append(list, value)
+		}
+		return list, nil
+	case "string":
+		value := generatedString
+		if minLength, ok := toFloat(schemaMap["minLength"]); ok && int(minLength) > len(value) {
+			value += strings.Repeat("x", int(minLength)-len(value))
+		}
+		return value, nil
+	case "integer", "number":
+		number := 0.0
+		if minimum, ok := toFloat(schemaMap["minimum"]); ok {
+			number = minimum
+		} else if exclusiveMinimum, ok := toFloat(schemaMap["exclusiveMinimum"]); ok {
+			number = math.Floor(exclusiveMinimum) + 1
+		} else if maximum, ok := toFloat(schemaMap["maximum"]); ok && maximum < 0 {
+			number = maximum
+		}
+
+		if typeName == "integer" {
+			return int(math.Ceil(number)), nil
+		}
+		return number, nil
+	case "boolean":
+		return false, nil
+	}
+
+	return nil, nil
+}
+
+func validate(schema interface{}, value interface{}, path []string,
+	validationErrors *[]ValidationError) error {
+
+	// `true` accepts everything and `false` accepts nothing
+	if accepted, ok := schema.(bool); ok {
+		if !accepted {
+			addError(validationErrors, path, "no value is allowed here")
+		}
+		return nil
+	}
+
+	schemaMap, ok := toMap(schema)
+	if !ok {
+		return errors.New(fmt.Sprintf("Invalid schema at '%s'. Schemas must be maps or booleans "+
+			"but got: %#v", FormatPath(path), schema))
+	}
+
+	if types, ok := schemaMap["type"]; ok {
+		allowed, err := toStrings(types)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid 'type' in schema at '%s'", FormatPath(path))
+		}
+
+		if !hasType(value, allowed) {
+			addError(validationErrors, path, fmt.Sprintf("expected %s but got %s",
+				strings.Join(allowed, " or "), describe(value)))
+			// other keywords are unlikely to produce helpful errors for a value of the wrong type
+			return nil
+		}
+	}
+
+	if enum, ok := schemaMap["enum"]; ok {
+		options, ok := enum.([]interface{})
+		if !ok {
+			return errors.New(fmt.Sprintf("Invalid 'enum' in schema at '%s'. It must be a list",
+				FormatPath(path)))
+		}
+
+		found := false
+		for _, option := range options {
+			if equal(option, value) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			formatted := make([]string, 0, len(options))
+			for _, option := range options {
+				formatted = append(formatted, fmt.Sprintf("%#v", option))
+			}
+			addError(validationErrors, path, fmt.Sprintf("%s isn't one of the allowed values: %s",
+				describe(value), strings.Join(formatted, ", ")))
+		}
+	}
+
+	if constant, ok := schemaMap["const"]; ok && !equal(constant, value) {
+		addError(validationErrors, path, fmt.Sprintf("expected %#v but got %s", constant,
+			describe(value)))
+	}
+
+	if number, ok := toFloat(value); ok {
+		validateNumber(schemaMap, number, path, validationErrors)
+	}
+
+	if str, ok := value.(string); ok {
+		err := validateString(schemaMap, str, path, validationErrors)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if list, ok := value.([]interface{}); ok {
+		err := validateList(schemaMap, list, path, validationErrors)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if object, ok := toMap(value); ok {
+		err := validateObject(schemaMap, object, path, validationErrors)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+func validateNumber(schema map[string]interface{}, number float64, path []string,
+	validationErrors *[]ValidationError) {
+	if minimum, ok := toFloat(schema["minimum"]); ok && number < minimum {
+		addError(validationErrors, path, fmt.Sprintf("%v is less than the minimum of %v", number, minimum))
+	}
+
+	if maximum, ok := toFloat(schema["maximum"]); ok && number > maximum {
+		addError(validationErrors, path, fmt.Sprintf("%v is greater than the maximum of %v", number, maximum))
+	}
+
+	if minimum, ok := toFloat(schema["exclusiveMinimum"]); ok && number <= minimum {
+		addError(validationErrors, path, fmt.Sprintf("%v must be greater than %v", number, minimum))
+	}
+
+	if maximum, ok := toFloat(schema["exclusiveMaximum"]); ok && number >= maximum {
+		addError(validationErrors, path, fmt.Sprintf("%v must be less than %v", number, maximum))
+	}
+}
+
+func validateString(schema map[string]interface{}, str string, path []string,
+	validationErrors *[]ValidationError) error {
+	length := float64(utf8.RuneCountInString(str))
+
+	if minLength, ok := toFloat(schema["minLength"]); ok && length < minLength {
+		addError(validationErrors, path, fmt.Sprintf("%q is shorter than the minimum length of %v",
+			str, minLength))
+	}
+
+	if maxLength, ok := toFloat(schema["maxLength"]); ok && length > maxLength {
+		addError(validationErrors, path, fmt.Sprintf("%q is longer than the maximum length of %v",
+			str, maxLength))
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok {
+		regex, err := regexp.Compile(pattern)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid 'pattern' in schema at '%s'", FormatPath(path))
+		}
+
+		if !regex.MatchString(str) {
+			addError(validationErrors, path, fmt.Sprintf("%q doesn't match the pattern '%s'", str, pattern))
+		}
+	}
+
+	return nil
+}
+
+func validateList(schema map[string]interface{}, list []interface{}, path []string,
+	validationErrors *[]ValidationError) error {
+	length := float64(len(list))
+
+	if minItems, ok := toFloat(schema["minItems"]); ok && length < minItems {
+		addError(validationErrors, path, fmt.Sprintf("expected at least %v items but got %d",
+			minItems, len(list)))
+	}
+
+	if maxItems, ok := toFloat(schema["maxItems"]); ok && length > maxItems {
+		addError(validationErrors, path, fmt.Sprintf("expected at most %v items but got %d",
+			maxItems, len(list)))
+	}
+
+	if items, ok := schema["items"]; ok {
+		for i, item := range list {
+			err := validate(items, item, append(append([]string{}, path...), fmt.Sprintf("[%d]", i)),
+				validationErrors)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func validateObject(schema map[string]interface{}, object map[string]interface{}, path []string,
+	validationErrors *[]ValidationError) error {
+
+	if required, ok := schema["required"]; ok {
+		names, err := toStrings(required)
+		if err != nil {
+			return errors.Wrapf(err, "Invalid 'required' in schema at '%s'", FormatPath(path))
+		}
+
+		for _, name := range names {
+			if _, ok := object[name]; !ok {
+				addError(validationErrors, appendPath(path, name), "is required but isn't set")
+			}
+		}
+	}
+
+	properties, _ := toMap(schema["properties"])
+
+	for _, name := range sortedKeys(object) {
+		childPath := appendPath(path, name)
+
+		if propertySchema, ok := properties[name]; ok {
+			err := validate(propertySchema, object[name], childPath, validationErrors)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			continue
+		}
+
+		additional, ok := schema["additionalProperties"]
+		if !ok {
+			continue
+		}
+
+		if allowed, ok := additional.(bool); ok && !allowed {
+			addError(validationErrors, childPath, "isn't an allowed property")
+			continue
+		}
+
+		err := validate(additional, object[name], childPath, validationErrors)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	return nil
+}
+
+func addError(validationErrors *[]ValidationError, path []string, message string) {
+	*validationErrors = append(*validationErrors, ValidationError{
+		Path:    append([]string{}, path...),
+		Message: message,
+	})
+}
+
+// Returns whether a value has any of the given JSON schema types
+func hasType(value interface{}, allowed []string) bool {
+	for _, typeName := range allowed {
+		switch typeName {
+		case "null":
+			if value == nil {
+				return true
+			}
+		case "boolean":
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case "string":
+			if _, ok := value.(string); ok {
+				return true
+			}
+		case "number":
+			if _, ok := toFloat(value); ok {
+				return true
+			}
+		case "integer":
+			if number, ok := toFloat(value); ok && number == math.Trunc(number) {
+				return true
+			}
+		case "array":
+			if _, ok := value.([]interface{}); ok {
+				return true
+			}
+		case "object":
+			if _, ok := toMap(value); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Describes a value's JSON schema type along with the value for use in error messages
+func describe(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return fmt.Sprintf("boolean %v", typed)
+	case string:
+		return fmt.Sprintf("string %q", typed)
+	case []interface{}:
+		return "an array"
+	}
+
+	if number, ok := toFloat(value); ok {
+		if number == math.Trunc(number) {
+			return fmt.Sprintf("integer %v", value)
+		}
+		return fmt.Sprintf("number %v", value)
+	}
+
+	if _, ok := toMap(value); ok {
+		return "an object"
+	}
+
+	return fmt.Sprintf("%#v", value)
+}
+
+// Returns whether two values are equal, treating all numbers as floats and ignoring the types of
+// map keys
+func equal(a interface{}, b interface{}) bool {
+	if first, ok := toFloat(a); ok {
+		second, ok := toFloat(b)
+		return ok && first == second
+	}
+
+	if first, ok := toMap(a); ok {
+		second, ok := toMap(b)
+		if !ok || len(first) != len(second) {
+			return false
+		}
+		for key, value := range first {
+			if !equal(value, second[key]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if first, ok := a.([]interface{}); ok {
+		second, ok := b.([]interface{})
+		if !ok || len(first) != len(second) {
+			return false
+		}
+		for i := range first {
+			if !equal(first[i], second[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// Converts any numeric type to a float
+func toFloat(value interface{}) (float64, bool) {
+	switch typed := value.(type) {
+	case int:
+		return float64(typed), true
+	case int8:
+		return float64(typed), true
+	case int16:
+		return float64(typed), true
+	case int32:
+		return float64(typed), true
+	case int64:
+		return float64(typed), true
+	case uint:
+		return float64(typed), true
+	case uint8:
+		return float64(typed), true
+	case uint16:
+		return float64(typed), true
+	case uint32:
+		return float64(typed), true
+	case uint64:
+		return float64(typed), true
+	case float32:
+		return float64(typed), true
+	case float64:
+		return typed, true
+	}
+
+	return 0, false
+}
+
+// Converts either type of map to a map with string keys
+func toMap(value interface{}) (map[string]interface{}, bool) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		return typed, true
+	case map[interface{}]interface{}:
+		output := make(map[string]interface{}, len(typed))
+		for key, child := range typed {
+			output[fmt.Sprintf("%v", key)] = child
+		}
+		return output, true
+	}
+
+	return nil, false
+}
+
+// Converts a string or list of strings to a list of strings
+func toStrings(value interface{}) ([]string, error) {
+	switch typed := value.(type) {
+	case string:
+		return []string{typed}, nil
+	case []interface{}:
+		output := make([]string, 0, len(typed))
+		for _, item := range typed {
+			str, ok := item.(string)
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("Expected a string but got %#v", item))
+			}
+			output = append(output, str)
+		}
+		return output, nil
+	case []string:
+		return typed, nil
+	}
+
+	return nil, errors.New(fmt.Sprintf("Expected a string or a list of strings but got %#v", value))
+}
+
+func sortedKeys(data map[string]interface{}) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func appendPath(path []string, element string) []string {
+	return append(append([]string{}, path...), element)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+
+	return false
+}
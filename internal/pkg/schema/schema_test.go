@@ -0,0 +1,283 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package schema
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+	"testing"
+)
+
+const testSchema = `
+type: object
+required: [name, ports]
+additionalProperties: false
+properties:
+  name:
+    type: string
+    pattern: ^[a-z-]+$
+    maxLength: 10
+  replicas:
+    type: integer
+    minimum: 1
+    maximum: 5
+    default: 1
+  ratio:
+    type: [number, "null"]
+  enabled:
+    type: boolean
+    default: true
+  tier:
+    enum: [frontend, backend]
+  ports:
+    type: array
+    minItems: 1
+    items:
+      type: integer
+  database:
+    type: object
+    properties:
+      host:
+        type: string
+      port:
+        type: integer
+        default: 5432
+`
+
+func loadYaml(t *testing.T, input string) interface{} {
+	var output interface{}
+	err := yaml.Unmarshal([]byte(input), &output)
+	assert.Nil(t, err)
+
+	return output
+}
+
+func TestValidate(t *testing.T) {
+	schema := loadYaml(t, testSchema)
+
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name: "valid",
+			input: `
+name: web
+replicas: 3
+ratio: 0.5
+enabled: false
+tier: backend
+ports: [80, 443]
+database:
+  host: db
+`,
+			expected: []string{},
+		},
+		{
+			name: "invalid",
+			input: `
+name: Web_App_Server
+replicas: "3"
+ratio: null
+enabled: "false"
+tier: middle
+ports: [80, http]
+database:
+  port: 1.5
+extra: true
+`,
+			expected: []string{
+				"database.port: expected integer but got number 1.5",
+				"enabled: expected boolean but got string \"false\"",
+				"extra: isn't an allowed property",
+				"name: \"Web_App_Server\" is longer than the maximum length of 10",
+				"name: \"Web_App_Server\" doesn't match the pattern '^[a-z-]+$'",
+				"ports[1]: expected integer but got string \"http\"",
+				"replicas: expected integer but got string \"3\"",
+				"tier: string \"middle\" isn't one of the allowed values: \"frontend\", \"backend\"",
+			},
+		},
+		{
+			name:  "missing",
+			input: `replicas: 9`,
+			expected: []string{
+				"name: is required but isn't set",
+				"ports: is required but isn't set",
+				"replicas: 9 is greater than the maximum of 5",
+			},
+		},
+		{
+			name:  "wrong type",
+			input: `[a, b]`,
+			expected: []string{
+				"expected object but got an array",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		validationErrors, err := Validate(schema, loadYaml(t, test.input))
+		assert.Nil(t, err, "Unexpected error for test '%s'", test.name)
+
+		actual := make([]string, 0)
+		for _, validationError := range validationErrors {
+			actual = append(actual, validationError.Error())
+		}
+
+		assert.Equal(t, test.expected, actual, "Unexpected validation errors for test '%s'", test.name)
+	}
+}
+
+func TestValidateInvalidSchema(t *testing.T) {
+	_, err := Validate(loadYaml(t, `type: 3`), "value")
+	assert.NotNil(t, err)
+
+	_, err = Validate(loadYaml(t, `pattern: "[unclosed"`), "value")
+	assert.NotNil(t, err)
+
+	// unsupported keywords aren't silently ignored, even in nested schemas
+	for _, unsupported := range []string{
+		`oneOf: [{type: string}, {type: integer}]`,
+		`properties: {name: {type: string, format: email}}`,
+		`items: {$ref: "#/definitions/item"}`,
+		`additionalProperties: {anyOf: [{type: string}]}`,
+	} {
+		_, err = Validate(loadYaml(t, unsupported), "value")
+		assert.NotNil(t, err, unsupported)
+		assert.Contains(t, err.Error(), "Unsupported keyword", unsupported)
+
+		_, err = Generate(loadYaml(t, unsupported))
+		assert.NotNil(t, err, unsupported)
+	}
+
+	// annotations are fine
+	_, err = Validate(loadYaml(t, `{type: string, title: Name, description: The name, examples: [a]}`), "value")
+	assert.Nil(t, err)
+}
+
+func TestCoerce(t *testing.T) {
+	schema := loadYaml(t, `
+properties:
+  enabled: {type: boolean}
+  replicas: {type: integer}
+  ratio: {type: number}
+  name: {type: [string, integer]}
+  ports: {items: {type: integer}}
+additionalProperties: {type: boolean}
+`)
+
+	actual := Coerce(schema, loadYaml(t, `
+enabled: "true"
+replicas: "3"
+ratio: "0.5"
+name: "3"
+ports: ["80", "not-a-port"]
+other: "false"
+`))
+
+	assert.Equal(t, loadYaml(t, `
+enabled: true
+replicas: 3
+ratio: 0.5
+name: "3"
+ports: [80, "not-a-port"]
+other: false
+`), actual)
+}
+
+func TestApplyDefaults(t *testing.T) {
+	schema := loadYaml(t, testSchema)
+
+	input := loadYaml(t, `
+name: web
+enabled: false
+database:
+  host: db
+`)
+
+	actual, applied := ApplyDefaults(schema, input)
+
+	assert.Equal(t, loadYaml(t, `
+name: web
+replicas: 1
+enabled: false
+database:
+  host: db
+  port: 5432
+`), actual)
+
+	assert.Equal(t, [][]string{{"database", "port"}, {"replicas"}}, applied)
+
+	// the input isn't modified
+	assert.Equal(t, loadYaml(t, `
+name: web
+enabled: false
+database:
+  host: db
+`), input)
+}
+
+func TestGenerate(t *testing.T) {
+	schema := loadYaml(t, testSchema)
+
+	actual, err := Generate(schema)
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"name":     "dry-run",
+		"replicas": 1,
+		"ratio":    0.0,
+		"enabled":  true,
+		"tier":     "frontend",
+		"ports":    []interface{}{0},
+		"database": map[string]interface{}{
+			"host": "dry-run",
+			"port": 5432,
+		},
+	}, actual)
+
+	// generated values conform to the schema
+	validationErrors, err := Validate(schema, actual)
+	assert.Nil(t, err)
+	assert.Empty(t, validationErrors)
+}
+
+func TestGenerateScalars(t *testing.T) {
+	tests := []struct {
+		schema   string
+		expected interface{}
+	}{
+		{"type: string\nminLength: 10", "dry-runxxx"},
+		{"type: integer\nexclusiveMinimum: 2", 3},
+		{"type: number\nmaximum: -5", -5.0},
+		{"type: [\"null\", boolean]", false},
+		{"const: fixed", "fixed"},
+		{"examples: [vpc-123]", "vpc-123"},
+		{"items: {type: string}\nminItems: 2", []interface{}{"dry-run", "dry-run"}},
+		{"description: anything", nil},
+	}
+
+	for _, test := range tests {
+		actual, err := Generate(loadYaml(t, test.schema))
+		assert.Nil(t, err, test.schema)
+		assert.Equal(t, test.expected, actual, test.schema)
+	}
+
+	_, err := Generate(loadYaml(t, "type: 5"))
+	assert.NotNil(t, err)
+}
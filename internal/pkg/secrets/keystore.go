@@ -0,0 +1,266 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
+	"golang.org/x/crypto/scrypt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+)
+
+// The environment variable the keystore's passphrase is read from
+var KeystorePassphraseEnvVar = config.EnvPrefix + "_KEYSTORE_PASSPHRASE"
+
+// scrypt parameters for deriving the keystore's encryption key from its passphrase
+const (
+	scryptN      = 32768
+	scryptR      = 8
+	scryptP      = 1
+	keyLength    = 32 // for AES-256
+	saltLength   = 16
+	keystoreMode = 0600
+)
+
+// Loads secrets from a local file encrypted with a passphrase, e.g. `keystore://app/db#password`.
+// Secrets are maps of strings keyed by their path.
+type keystoreProvider struct{}
+
+// Data encrypted with a passphrase, e.g. the keystore
+type encryptedFile struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func (p *keystoreProvider) Get(path string) (interface{}, error) {
+	keystorePath, err := KeystorePath()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	secrets, err := loadKeystore(keystorePath)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	secret, ok := secrets[path]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("No secret found in keystore '%s' at '%s'", keystorePath, path))
+	}
+
+	result := make(map[string]interface{}, len(secret))
+	for key, value := range secret {
+		result[key] = value
+	}
+
+	return result, nil
+}
+
+// Returns the path to the keystore
+func KeystorePath() (string, error) {
+	if config.CurrentConfig != nil && config.CurrentConfig.Secrets.Keystore.Path != "" {
+		return config.CurrentConfig.Secrets.Keystore.Path, nil
+	}
+
+	usr, err := user.Current()
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return filepath.Join(usr.HomeDir, ".sugarkube", "keystore"), nil
+}
+
+// Sets a key of a secret in the keystore, creating the keystore if it doesn't exist
+func SetKeystoreSecret(path string, key string, value string) error {
+	keystorePath, err := KeystorePath()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	secrets := map[string]map[string]string{}
+
+	if _, err := os.Stat(keystorePath); err == nil {
+		secrets, err = loadKeystore(keystorePath)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	if _, ok := secrets[path]; !ok {
+		secrets[path] = map[string]string{}
+	}
+	secrets[path][key] = value
+
+	err = saveKeystore(keystorePath, secrets)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	ClearCache()
+
+	return nil
+}
+
+// Decrypts and returns the secrets in a keystore
+func loadKeystore(keystorePath string) (map[string]map[string]string, error) {
+	contents, err := ioutil.ReadFile(keystorePath)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error reading keystore '%s'", keystorePath)
+	}
+
+	passphrase, err := keystorePassphrase()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	plaintext, err := Decrypt(contents, passphrase)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error decrypting keystore '%s'. Is the passphrase "+
+			"in %s correct?", keystorePath, KeystorePassphraseEnvVar)
+	}
+
+	secrets := map[string]map[string]string{}
+	err = json.Unmarshal(plaintext, &secrets)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error parsing decrypted keystore '%s'", keystorePath)
+	}
+
+	return secrets, nil
+}
+
+// Encrypts secrets and writes them to a keystore
+func saveKeystore(keystorePath string, secrets map[string]map[string]string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	passphrase, err := keystorePassphrase()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	contents, err := Encrypt(plaintext, passphrase)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(keystorePath), 0700)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = ioutil.WriteFile(keystorePath, contents, keystoreMode)
+	if err != nil {
+		return errors.Wrapf(err, "Error writing keystore '%s'", keystorePath)
+	}
+
+	return nil
+}
+
+// Returns the keystore's passphrase
+func keystorePassphrase() (string, error) {
+	passphrase := os.Getenv(KeystorePassphraseEnvVar)
+	if passphrase == "" {
+		return "", errors.New(fmt.Sprintf("Set %s to the keystore's passphrase",
+			KeystorePassphraseEnvVar))
+	}
+
+	return passphrase, nil
+}
+
+// Encrypts data with AES-GCM using a key derived from the passphrase. Returns JSON containing the
+// salt, nonce and ciphertext.
+func Encrypt(plaintext []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, saltLength)
+	_, err := io.ReadFull(rand.Reader, salt)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	gcm, err := newCipher(passphrase, salt)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	contents, err := json.Marshal(encryptedFile{
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, plaintext, nil),
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return contents, nil
+}
+
+// Decrypts data returned by Encrypt
+func Decrypt(contents []byte, passphrase string) ([]byte, error) {
+	encrypted := encryptedFile{}
+	err := json.Unmarshal(contents, &encrypted)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error parsing encrypted data")
+	}
+
+	gcm, err := newCipher(passphrase, encrypted.Salt)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	plaintext, err := gcm.Open(nil, encrypted.Nonce, encrypted.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error decrypting data")
+	}
+
+	return plaintext, nil
+}
+
+// Returns an AES-GCM cipher using a key derived from the passphrase and the salt
+func newCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keyLength)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return gcm, nil
+}
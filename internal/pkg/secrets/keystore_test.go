@@ -0,0 +1,72 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secrets
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeystore(t *testing.T) {
+	defer ClearCache()
+
+	tempDir, err := ioutil.TempDir("", "keystore-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	keystorePath := filepath.Join(tempDir, "nested", "keystore")
+
+	previousConfig := config.CurrentConfig
+	config.CurrentConfig = &config.Config{Secrets: config.SecretsConfig{
+		Keystore: config.KeystoreConfig{Path: keystorePath},
+	}}
+	defer func() { config.CurrentConfig = previousConfig }()
+
+	os.Setenv(KeystorePassphraseEnvVar, "correct horse")
+	defer os.Unsetenv(KeystorePassphraseEnvVar)
+
+	err = SetKeystoreSecret("app/db", "password", "keystore-password")
+	assert.Nil(t, err)
+	err = SetKeystoreSecret("app/db", "user", "admin")
+	assert.Nil(t, err)
+
+	info, err := os.Stat(keystorePath)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// the keystore is encrypted
+	contents, err := ioutil.ReadFile(keystorePath)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(contents), "keystore-password")
+
+	value, err := Resolve("keystore://app/db#password")
+	assert.Nil(t, err)
+	assert.Equal(t, "keystore-password", value)
+
+	value, err = Resolve("keystore://app/db#user")
+	assert.Nil(t, err)
+	assert.Equal(t, "admin", value)
+
+	os.Setenv(KeystorePassphraseEnvVar, "wrong")
+	ClearCache()
+
+	_, err = Resolve("keystore://app/db#password")
+	assert.Error(t, err)
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secrets
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Loads secrets from environment variables, e.g. `env://DB_PASSWORD`
+type envProvider struct{}
+
+func (p envProvider) Get(path string) (interface{}, error) {
+	value, ok := os.LookupEnv(path)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Environment variable '%s' isn't set", path))
+	}
+
+	return value, nil
+}
+
+// Loads secrets from files, e.g. `file:///run/secrets/db` or `file://secrets/db.yaml#password`.
+// Relative paths are relative to the current directory. A trailing newline is removed.
+type fileProvider struct{}
+
+func (p fileProvider) Get(path string) (interface{}, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error reading secret file '%s'", path)
+	}
+
+	return strings.TrimSuffix(string(contents), "\n"), nil
+}
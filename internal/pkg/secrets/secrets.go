@@ -0,0 +1,219 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secrets
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
+	"gopkg.in/yaml.v2"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Separates the backend from the path in secret references, e.g. `vault://secret/app#password`
+const schemeSeparator = "://"
+
+// Separates the path to a secret from the key to return from it
+const keySeparator = "#"
+
+// A backend secrets can be loaded from
+type SecretProvider interface {
+	// Returns the secret at the given path. Secrets are either strings or maps of strings.
+	Get(path string) (interface{}, error)
+}
+
+// providers keyed by the scheme they're referred to by
+var providers = map[string]SecretProvider{
+	"env":      envProvider{},
+	"file":     fileProvider{},
+	"vault":    &vaultProvider{},
+	"keystore": &keystoreProvider{},
+}
+var providersMutex sync.RWMutex
+
+// secrets loaded during this run keyed by scheme and path, so each is only loaded once
+var cache = make(map[string]interface{})
+var cacheMutex sync.Mutex
+
+// Registers a provider for references using the given scheme, replacing any existing one
+func Register(scheme string, provider SecretProvider) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+	providers[scheme] = provider
+}
+
+// Clears all cached secrets
+func ClearCache() {
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+	cache = make(map[string]interface{})
+}
+
+// Returns the value of a secret referred to like `backend://path#key`, e.g.
+// `vault://secret/app/db#password`. The key is optional for secrets that are strings, but
+// required for maps. If a key is given for a string secret it's parsed as YAML (or JSON).
+// Secrets are cached for the rest of the run and masked in all output.
+func Resolve(reference string) (string, error) {
+	scheme, path, key, err := parseReference(reference)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	secret, err := load(scheme, path)
+	if err != nil {
+		return "", errors.Wrapf(err, "Error loading secret '%s'", reference)
+	}
+
+	if key == "" {
+		if value, ok := secret.(string); ok {
+			return value, nil
+		}
+
+		return "", errors.New(fmt.Sprintf("Secret '%s' contains multiple values. Append "+
+			"'%s' and the name of the one to use, e.g. '%s%sname' (available keys: %s)", reference,
+			keySeparator, reference, keySeparator, strings.Join(keys(secret), ", ")))
+	}
+
+	if value, ok := secret.(string); ok {
+		parsed := map[string]interface{}{}
+		err = yaml.Unmarshal([]byte(value), &parsed)
+		if err != nil {
+			return "", errors.New(fmt.Sprintf("Secret '%s' isn't a map so a key can't be "+
+				"looked up in it", reference))
+		}
+		secret = parsed
+	}
+
+	value, ok := lookup(secret, key)
+	if !ok {
+		return "", errors.New(fmt.Sprintf("Key '%s' not found in secret '%s%s%s' (available "+
+			"keys: %s)", key, scheme, schemeSeparator, path, strings.Join(keys(secret), ", ")))
+	}
+
+	// string secrets are masked as a whole when they're loaded, so mask values parsed from them too
+	redact.AddValue(value)
+
+	return value, nil
+}
+
+// Splits a reference into its scheme, path and key (which may be empty)
+func parseReference(reference string) (string, string, string, error) {
+	parts := strings.SplitN(reference, schemeSeparator, 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", "", errors.New(fmt.Sprintf("Invalid secret reference '%s'. It should be "+
+			"formatted 'backend://path#key', e.g. 'vault://secret/app#password'", reference))
+	}
+
+	scheme := parts[0]
+	path := parts[1]
+	key := ""
+
+	if index := strings.LastIndex(path, keySeparator); index >= 0 {
+		key = path[index+1:]
+		path = path[:index]
+	}
+
+	return scheme, path, key, nil
+}
+
+// Loads a secret from its provider unless it's already been loaded
+func load(scheme string, path string) (interface{}, error) {
+	providersMutex.RLock()
+	provider, ok := providers[scheme]
+	providersMutex.RUnlock()
+
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Unknown secret backend '%s'. Supported backends "+
+			"are: %s", scheme, strings.Join(schemes(), ", ")))
+	}
+
+	cacheKey := scheme + schemeSeparator + path
+
+	// hold the lock while loading so the same secret isn't loaded concurrently by multiple workers
+	cacheMutex.Lock()
+	defer cacheMutex.Unlock()
+
+	if secret, ok := cache[cacheKey]; ok {
+		return secret, nil
+	}
+
+	log.Logger.Debugf("Loading secret '%s'", cacheKey)
+
+	secret, err := provider.Get(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	redact.AddValues(secret)
+	cache[cacheKey] = secret
+
+	return secret, nil
+}
+
+// Returns the value of a key in a map as a string
+func lookup(secret interface{}, key string) (string, bool) {
+	var value interface{}
+	var ok bool
+
+	switch typed := secret.(type) {
+	case map[string]interface{}:
+		value, ok = typed[key]
+	case map[interface{}]interface{}:
+		value, ok = typed[key]
+	}
+
+	if !ok {
+		return "", false
+	}
+
+	return fmt.Sprintf("%v", value), true
+}
+
+// Returns the sorted keys of a map
+func keys(secret interface{}) []string {
+	result := make([]string, 0)
+
+	switch typed := secret.(type) {
+	case map[string]interface{}:
+		for key := range typed {
+			result = append(result, key)
+		}
+	case map[interface{}]interface{}:
+		for key := range typed {
+			result = append(result, fmt.Sprintf("%v", key))
+		}
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// Returns the sorted schemes of all registered providers
+func schemes() []string {
+	providersMutex.RLock()
+	defer providersMutex.RUnlock()
+
+	result := make([]string, 0, len(providers))
+	for scheme := range providers {
+		result = append(result, scheme)
+	}
+
+	sort.Strings(result)
+	return result
+}
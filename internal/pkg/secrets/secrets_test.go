@@ -0,0 +1,124 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secrets
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func init() {
+	log.ConfigureLogger("trace", false, os.Stderr)
+}
+
+// Counts how many times secrets are loaded
+type countingProvider struct {
+	calls int
+}
+
+func (p *countingProvider) Get(path string) (interface{}, error) {
+	p.calls++
+	return map[string]interface{}{"user": "admin", "password": "counted-" + path}, nil
+}
+
+func TestResolve(t *testing.T) {
+	defer redact.Reset()
+	defer ClearCache()
+
+	tempDir, err := ioutil.TempDir("", "secrets-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	secretFile := filepath.Join(tempDir, "db.yaml")
+	err = ioutil.WriteFile(secretFile, []byte("password: file-password\nport: 5432\n"), 0600)
+	assert.Nil(t, err)
+
+	plainFile := filepath.Join(tempDir, "token")
+	err = ioutil.WriteFile(plainFile, []byte("file-token\n"), 0600)
+	assert.Nil(t, err)
+
+	os.Setenv("SECRETS_TEST_PASSWORD", "env-password")
+	defer os.Unsetenv("SECRETS_TEST_PASSWORD")
+
+	tests := map[string]string{
+		"env://SECRETS_TEST_PASSWORD":        "env-password",
+		"file://" + plainFile:                "file-token",
+		"file://" + secretFile + "#password": "file-password",
+		"file://" + secretFile + "#port":     "5432",
+	}
+
+	for reference, expected := range tests {
+		value, err := Resolve(reference)
+		assert.Nil(t, err, "unexpected error for '%s'", reference)
+		assert.Equal(t, expected, value, "unexpected value for '%s'", reference)
+	}
+
+	// resolved secrets are masked
+	assert.Equal(t, redact.Mask, redact.String("file-password"))
+}
+
+func TestResolveErrors(t *testing.T) {
+	defer ClearCache()
+
+	Register("counting", &countingProvider{})
+	defer func() {
+		providersMutex.Lock()
+		delete(providers, "counting")
+		providersMutex.Unlock()
+	}()
+
+	tests := []string{
+		"no-scheme",                       // not a reference
+		"unknown://path#key",              // unknown backend
+		"env://SECRETS_TEST_UNSET",        // missing env var
+		"counting://db",                   // no key given for a map
+		"counting://db#missing",           // missing key
+		"file:///does/not/exist#password", // missing file
+	}
+
+	for _, reference := range tests {
+		_, err := Resolve(reference)
+		assert.Error(t, err, "expected an error for '%s'", reference)
+	}
+}
+
+func TestResolveCaches(t *testing.T) {
+	defer ClearCache()
+
+	provider := &countingProvider{}
+	Register("counting", provider)
+	defer func() {
+		providersMutex.Lock()
+		delete(providers, "counting")
+		providersMutex.Unlock()
+	}()
+
+	for _, reference := range []string{"counting://db#user", "counting://db#password", "counting://db#user"} {
+		_, err := Resolve(reference)
+		assert.Nil(t, err)
+	}
+
+	value, err := Resolve("counting://other#password")
+	assert.Nil(t, err)
+	assert.Equal(t, "counted-other", value)
+
+	assert.Equal(t, 2, provider.calls)
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const defaultVaultTimeout = 30
+
+// Loads secrets from a HashiCorp Vault KV version 2 secrets engine. The first element of the path
+// is where the engine is mounted, e.g. `vault://secret/app/db#password` loads the latest version
+// of `app/db` from the engine mounted at `secret`.
+type vaultProvider struct {
+	client *http.Client
+}
+
+// The parts of a response from the KV v2 API we use
+type vaultResponse struct {
+	Data struct {
+		Data map[string]interface{} `json:"data"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func (p *vaultProvider) Get(path string) (interface{}, error) {
+	vaultConfig := config.VaultConfig{}
+	if config.CurrentConfig != nil {
+		vaultConfig = config.CurrentConfig.Secrets.Vault
+	}
+
+	address := vaultConfig.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	if address == "" {
+		return nil, errors.New("No Vault address configured. Set VAULT_ADDR or " +
+			"'secrets.vault.address' in the sugarkube config file")
+	}
+
+	token, err := vaultToken()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	elements := strings.SplitN(strings.Trim(path, "/"), "/", 2)
+	if len(elements) != 2 {
+		return nil, errors.New(fmt.Sprintf("Invalid Vault path '%s'. It should start with the "+
+			"path the KV engine is mounted at, e.g. 'secret/app/db'", path))
+	}
+
+	url := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimSuffix(address, "/"), elements[0], elements[1])
+
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	request.Header.Set("X-Vault-Token", token)
+
+	namespace := vaultConfig.Namespace
+	if namespace == "" {
+		namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if namespace != "" {
+		request.Header.Set("X-Vault-Namespace", namespace)
+	}
+
+	if p.client == nil {
+		timeout := vaultConfig.Timeout
+		if timeout <= 0 {
+			timeout = defaultVaultTimeout
+		}
+		p.client = &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	}
+
+	response, err := p.client.Do(request)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error requesting '%s' from Vault", path)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	parsed := vaultResponse{}
+	// error responses may not be JSON
+	_ = json.Unmarshal(body, &parsed)
+
+	switch {
+	case response.StatusCode == http.StatusNotFound:
+		return nil, errors.New(fmt.Sprintf("No secret found in Vault at '%s'", path))
+	case response.StatusCode != http.StatusOK:
+		return nil, errors.New(fmt.Sprintf("Vault returned status %d for '%s': %s",
+			response.StatusCode, path, strings.Join(parsed.Errors, ", ")))
+	case parsed.Data.Data == nil:
+		return nil, errors.New(fmt.Sprintf("Vault didn't return any data for '%s'. Is it a "+
+			"KV version 2 secrets engine?", path))
+	}
+
+	return parsed.Data.Data, nil
+}
+
+// Returns the Vault token from VAULT_TOKEN or the file the Vault CLI stores it in
+func vaultToken() (string, error) {
+	token := os.Getenv("VAULT_TOKEN")
+	if token != "" {
+		return token, nil
+	}
+
+	usr, err := user.Current()
+	if err == nil {
+		contents, err := ioutil.ReadFile(filepath.Join(usr.HomeDir, ".vault-token"))
+		if err == nil {
+			return strings.TrimSpace(string(contents)), nil
+		}
+	}
+
+	return "", errors.New("No Vault token found. Set VAULT_TOKEN or log in with the Vault CLI")
+}
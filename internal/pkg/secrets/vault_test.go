@@ -0,0 +1,81 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package secrets
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// Returns a fake Vault server with a KV v2 engine mounted at 'secret'
+func newFakeVault(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.Header.Get("X-Vault-Token") != "test-token" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v1/secret/data/app/db":
+			_, _ = w.Write([]byte(`{"data":{"data":{"password":"vault-password","user":"admin"},
+				"metadata":{"version":3}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"errors":[]}`))
+		}
+	}))
+}
+
+func TestVaultProvider(t *testing.T) {
+	defer ClearCache()
+
+	server := newFakeVault(t)
+	defer server.Close()
+
+	previousConfig := config.CurrentConfig
+	config.CurrentConfig = &config.Config{Secrets: config.SecretsConfig{
+		Vault: config.VaultConfig{Address: server.URL},
+	}}
+	defer func() { config.CurrentConfig = previousConfig }()
+
+	os.Setenv("VAULT_TOKEN", "test-token")
+	defer os.Unsetenv("VAULT_TOKEN")
+
+	value, err := Resolve("vault://secret/app/db#password")
+	assert.Nil(t, err)
+	assert.Equal(t, "vault-password", value)
+
+	_, err = Resolve("vault://secret/app/missing#password")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "No secret found in Vault")
+
+	_, err = Resolve("vault://secret/app/db#missing")
+	assert.Error(t, err)
+
+	os.Setenv("VAULT_TOKEN", "wrong-token")
+	ClearCache()
+
+	_, err = Resolve("vault://secret/app/db#password")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "permission denied")
+}
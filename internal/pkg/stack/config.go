@@ -19,11 +19,18 @@ package stack
 import (
 	"fmt"
 	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
 	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/program"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"github.com/sugarkube/sugarkube/internal/pkg/templater"
+	"github.com/sugarkube/sugarkube/internal/pkg/utils"
+	"github.com/sugarkube/sugarkube/internal/pkg/vars"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 )
 
 // The populated config for a stack - all object addresses from the raw stack config have been
@@ -34,6 +41,9 @@ type StackConfig struct {
 	manifests     []interfaces.IManifest
 	onlineTimeout uint32
 	readyTimeout  uint32
+	// vars set by environment variables. Kapp vars are keyed by fully-qualified kapp ID.
+	kappVarEnvOverrides  map[string][]vars.EnvOverride
+	stackVarEnvOverrides []vars.EnvOverride
 }
 
 // Returns the populated manifests
@@ -46,6 +56,26 @@ func (s StackConfig) KappVarsDirs() []string {
 	return s.stackFile.KappVarsDirs
 }
 
+// Returns vars set on the command line for the given kapp
+func (s StackConfig) KappVarOverrides(fullyQualifiedId string) map[string]interface{} {
+	return s.stackFile.KappVarOverrides[fullyQualifiedId]
+}
+
+// Returns whether outputs should be loaded by running output steps instead of from the registry
+func (s StackConfig) RefreshOutputs() bool {
+	return s.stackFile.RefreshOutputs
+}
+
+// Returns vars set by environment variables for the given kapp
+func (s StackConfig) KappVarEnvOverrides(fullyQualifiedId string) []vars.EnvOverride {
+	return s.kappVarEnvOverrides[fullyQualifiedId]
+}
+
+// Returns stack vars set by environment variables
+func (s StackConfig) StackVarEnvOverrides() []vars.EnvOverride {
+	return s.stackVarEnvOverrides
+}
+
 // Sets the ready timeout
 func (s *StackConfig) SetReadyTimeout(timeout uint32) {
 	s.readyTimeout = timeout
@@ -125,6 +155,80 @@ func validateStackConfig(stackConfig interfaces.IStackConfig) error {
 	return nil
 }
 
+// Validates that vars set on the command line are for kapps that exist in the stack, since
+// otherwise a typo would silently be ignored
+func validateKappVarOverrides(stackConfig *StackConfig) error {
+	kappIds := make([]string, 0)
+
+	for _, manifest := range stackConfig.Manifests() {
+		for _, installableObj := range manifest.Installables() {
+			kappIds = append(kappIds, installableObj.FullyQualifiedId())
+		}
+	}
+
+	for kappId := range stackConfig.stackFile.KappVarOverrides {
+		if !utils.InStringArray(kappIds, kappId) {
+			return program.SimpleError{Message: fmt.Sprintf("Vars were set for kapp '%s' but "+
+				"it isn't in the stack", kappId)}
+		}
+	}
+
+	return nil
+}
+
+// names of env vars for kapps that aren't in the stack that have been logged, so each is only
+// logged once however many times the stack is loaded
+var loggedEnvOverrides = map[string]bool{}
+var loggedEnvOverridesMutex sync.Mutex
+
+func logUnknownEnvOverride(name string) {
+	loggedEnvOverridesMutex.Lock()
+	defer loggedEnvOverridesMutex.Unlock()
+
+	if loggedEnvOverrides[name] {
+		return
+	}
+
+	loggedEnvOverrides[name] = true
+	log.Logger.Debugf("Ignoring environment variable '%s' since it sets vars for a kapp that "+
+		"isn't in the stack", name)
+}
+
+// Parses vars set by environment variables, associating kapp vars with the kapps in the stack
+// they're for. Manifest and kapp IDs in variable names are case-insensitive and underscores match
+// hyphens. Env vars for kapps that aren't in the stack are ignored, but malformed names are an error.
+func loadEnvOverrides(stackConfig *StackConfig, environ []string) error {
+	kappOverrides, stackOverrides, err := vars.ParseEnvOverrides(config.EnvPrefix, environ)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	stackConfig.stackVarEnvOverrides = stackOverrides
+	stackConfig.kappVarEnvOverrides = map[string][]vars.EnvOverride{}
+
+	for _, override := range kappOverrides {
+		kappId := ""
+
+		for _, manifest := range stackConfig.Manifests() {
+			for _, installableObj := range manifest.Installables() {
+				if strings.EqualFold(templater.TemplateKey(installableObj.FullyQualifiedId()), override.Kapp) {
+					kappId = installableObj.FullyQualifiedId()
+				}
+			}
+		}
+
+		// env vars are often shared by several stacks so this isn't an error
+		if kappId == "" {
+			logUnknownEnvOverride(override.Name)
+			continue
+		}
+
+		stackConfig.kappVarEnvOverrides[kappId] = append(stackConfig.kappVarEnvOverrides[kappId], override)
+	}
+
+	return nil
+}
+
 // Returns the directory the stack config was loaded from, or the current
 // working directory. This can be used to build relative paths.
 func (s *StackConfig) GetDir() string {
@@ -175,5 +175,15 @@ func parseStackFile(stackFile structs.StackFile) (interfaces.IStackConfig, error
 		manifests: manifests,
 	}
 
+	err = validateKappVarOverrides(stackConfig)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	err = loadEnvOverrides(stackConfig, os.Environ())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
 	return stackConfig, nil
 }
@@ -27,6 +27,7 @@ import (
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
 	"github.com/sugarkube/sugarkube/internal/pkg/utils"
+	"github.com/sugarkube/sugarkube/internal/pkg/vars"
 	"path/filepath"
 	"strings"
 )
@@ -66,6 +67,8 @@ func instantiateInstallables(manifest Manifest) ([]interfaces.IInstallable, erro
 
 	manfestDefaults := structs.KappDescriptorWithMaps{
 		KappConfig: manifestFile.Defaults,
+		Origin: vars.Origin{Source: vars.SourceManifestDefaults,
+			Detail: fmt.Sprintf("manifest '%s'", manifest.Id())}.String(),
 	}
 
 	for i, kappDescriptor := range manifestFile.KappDescriptor {
@@ -74,6 +77,8 @@ func instantiateInstallables(manifest Manifest) ([]interfaces.IInstallable, erro
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
+		kappDescriptorWithMap.Origin = vars.Origin{Source: vars.SourceManifestKapp,
+			Detail: fmt.Sprintf("manifest '%s'", manifest.Id())}.String()
 
 		// need to merge structs for kapp descriptors (in order of lowest to highest precedence):
 		//   * values from the sugarkube-conf.yaml file (if any are specified for
@@ -319,6 +324,7 @@ func acquireManifests(stackFile structs.StackFile) ([]interfaces.IManifest, erro
 
 	stackDefaults := structs.KappDescriptorWithMaps{
 		KappConfig: stackFile.Defaults,
+		Origin:     vars.Origin{Source: vars.SourceStackDefaults, Detail: stackFile.FilePath}.String(),
 	}
 
 	manifests := make([]interfaces.IManifest, len(stackFile.ManifestDescriptors))
@@ -340,6 +346,8 @@ func acquireManifests(stackFile structs.StackFile) ([]interfaces.IManifest, erro
 			// the descriptor to the list
 			stackOverrides, ok := manifestDescriptor.Overrides[installableObj.Id()]
 			if ok {
+				stackOverrides.Origin = vars.Origin{Source: vars.SourceStackOverrides,
+					Detail: stackFile.FilePath}.String()
 				err = installableObj.AddDescriptor(stackOverrides, false)
 				if err != nil {
 					return nil, errors.WithStack(err)
@@ -19,19 +19,37 @@ import (
 	"fmt"
 	"github.com/pkg/errors"
 	"github.com/sugarkube/sugarkube/internal/pkg/clustersot"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
+	"github.com/sugarkube/sugarkube/internal/pkg/constants"
 	"github.com/sugarkube/sugarkube/internal/pkg/convert"
 	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
 	"github.com/sugarkube/sugarkube/internal/pkg/provider"
 	"github.com/sugarkube/sugarkube/internal/pkg/provisioner"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
+	"github.com/sugarkube/sugarkube/internal/pkg/registry"
 	"github.com/sugarkube/sugarkube/internal/pkg/templater"
 	"github.com/sugarkube/sugarkube/internal/pkg/utils"
 	"github.com/sugarkube/sugarkube/internal/pkg/vars"
 	"gopkg.in/yaml.v2"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
 )
 
+// matches references to the vars of other kapps in templates, e.g. `.kapps.somekapp.vars.thevar`
+// or `index .kapps "somekapp"`
+var kappReferenceRegex = regexp.MustCompile(`(?:\.kapps\.|index\s+\.kapps\s+")([A-Za-z0-9_]+)`)
+
+// matches template actions, and any references to `.kapps` in them
+var templateActionRegex = regexp.MustCompile(`(?s){{.*?}}`)
+var kappsRegex = regexp.MustCompile(`\.kapps\b`)
+
+// matches calls to the `kappVar` template function with the ID of another kapp, e.g. `kappVar "manifest:kapp" "key"`
+var kappVarReferenceRegex = regexp.MustCompile(`kappVar\s+"([^"]+)"\s+"`)
+
 // Top-level struct that holds references to instantiations of other objects
 // we need to pass around. This is in its own package to avoid circular
 // dependencies.
@@ -41,6 +59,8 @@ type Stack struct {
 	provisioner interfaces.IProvisioner
 	status      *ClusterStatus
 	registry    interfaces.IRegistry
+	// saves the registry to the workspace, if it's persisted
+	registryPersister *registry.Persister
 }
 
 // Creates a new Stack
@@ -102,8 +122,40 @@ func (s Stack) GetRegistry() interfaces.IRegistry {
 // Merges and templates vars from all configured sources. If an installable instance
 // is given, data specific to it will be included in the returned map,
 // otherwise only stack-specific variables will be returned.
+//
+// Values are merged in the following order, with later values overriding earlier ones: stack
+// data, runtime values under `sugarkube`, provider vars, the registry, the vars of other
+// kapps under `kapps`, then the installable's own vars (from vars files, its `vars` block, then
+// vars set on the command line). Finally the installable's `vars_template` is rendered with the
+// result and deep-merged into its vars, so values from it take precedence over all other kapp vars
+// except those set on the command line, and defaults from its `vars_schema` are set for any vars
+// that are still missing.
 func (s *Stack) GetTemplatedVars(installableObj interfaces.IInstallable,
 	extraVars map[string]interface{}) (map[string]interface{}, error) {
+	return s.getTemplatedVars(installableObj, extraVars, []string{}, nil)
+}
+
+// Returns the same templated vars as `GetTemplatedVars` along with the layer each value came from
+// and its raw value before templating
+func (s *Stack) ExplainVars(installableObj interfaces.IInstallable) (map[string]interface{},
+	vars.Provenance, error) {
+	provenance := vars.Provenance{}
+
+	templatedVars, err := s.getTemplatedVars(installableObj, map[string]interface{}{}, []string{},
+		provenance)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	return templatedVars, provenance, nil
+}
+
+// Returns templated vars. `resolving` contains the fully-qualified IDs of kapps whose vars are
+// being resolved because they (transitively) refer to the vars of this installable. It's used
+// to detect circular references. If provenance isn't nil the source of each value is recorded in it.
+func (s *Stack) getTemplatedVars(installableObj interfaces.IInstallable,
+	extraVars map[string]interface{}, resolving []string,
+	provenance vars.Provenance) (map[string]interface{}, error) {
 
 	stackConfig := s.config
 
@@ -116,6 +168,7 @@ func (s *Stack) GetTemplatedVars(installableObj interfaces.IInstallable,
 	configFragments = append(configFragments, map[string]interface{}{
 		"stack": convert.MapStringStringToMapStringInterface(stackIntrinsicData),
 	})
+	provenance.Record([]string{"stack"}, stackIntrinsicData, vars.SourceStackIntrinsic, "")
 
 	// store additional runtime values under the "sugarkube" key
 	extraVars["defaultVars"] = []string{
@@ -130,11 +183,33 @@ func (s *Stack) GetTemplatedVars(installableObj interfaces.IInstallable,
 	configFragments = append(configFragments, map[string]interface{}{
 		"sugarkube": extraVars,
 	})
+	provenance.Record([]string{"sugarkube"}, extraVars, vars.SourceRuntime, "")
 
 	providerVars := stackConfig.GetProviderVars()
 	configFragments = append(configFragments, providerVars)
 
-	// merge in values from the registry (we need to copy it to prevent data races)
+	if provenance != nil && s.provider != nil {
+		err := provenance.RecordFiles([]string{}, vars.SourceProviderVarsFile,
+			s.provider.VarsFilePaths()...)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	// stack vars set by environment variables override provider vars
+	stackVarEnvOverrides := stackConfig.StackVarEnvOverrides()
+	if len(stackVarEnvOverrides) > 0 {
+		envOverrides, err := vars.EnvOverridesToMap(stackVarEnvOverrides)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		configFragments = append(configFragments, envOverrides)
+		provenance.RecordEnvOverrides([]string{}, stackVarEnvOverrides)
+	}
+
+	// merge in values from the registry (we need to copy its snapshot because merging may modify
+	// its submaps)
 	var registryCopy map[string]interface{}
 	err := utils.DeepCopy(s.registry.AsMap(), &registryCopy)
 	if err != nil {
@@ -142,14 +217,42 @@ func (s *Stack) GetTemplatedVars(installableObj interfaces.IInstallable,
 	}
 	log.Logger.Tracef("Merging stack vars with global registry: %v", registryCopy)
 	configFragments = append(configFragments, registryCopy)
+	provenance.RecordRegistry(registryCopy)
 
 	if installableObj != nil {
-		installableVars, err := installableObj.Vars(s)
+		// the installable's vars are recorded separately because they're merged after the vars of
+		// other kapps
+		var installableProvenance vars.Provenance
+		var installableVars map[string]interface{}
+		if provenance == nil {
+			installableVars, err = installableObj.Vars(s)
+		} else {
+			installableProvenance = vars.Provenance{}
+			installableVars, err = installableObj.ExplainVars(s, installableProvenance)
+		}
 		if err != nil {
 			return nil, errors.WithStack(err)
 		}
 
+		kappsVars, err := s.getReferencedKappsVars(installableObj, installableVars, resolving)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		if len(kappsVars) > 0 {
+			configFragments = append(configFragments, map[string]interface{}{
+				constants.KappVarsKappsKey: kappsVars,
+			})
+			provenance.Record([]string{constants.KappVarsKappsKey}, kappsVars, vars.SourceKappsVars, "")
+		}
+
 		configFragments = append(configFragments, installableVars)
+		provenance.Merge(installableProvenance)
+	}
+
+	// mask sensitive values before they're logged
+	for _, fragment := range configFragments {
+		redact.AddMatchingValues(fragment)
 	}
 
 	mergedVars := map[string]interface{}{}
@@ -158,11 +261,38 @@ func (s *Stack) GetTemplatedVars(installableObj interfaces.IInstallable,
 		return nil, errors.WithStack(err)
 	}
 
-	templatedVars, err := templater.IterativelyTemplate(mergedVars)
+	// resolving vars logs them so mask sensitive vars that don't need templating first
+	if installableObj != nil {
+		addSensitiveVars(installableObj, mergedVars, false)
+	}
+
+	templatedVars, err := templater.ResolveVars(mergedVars)
 	if err != nil {
+		if installableObj != nil {
+			return nil, errors.Wrapf(err, "Error templating vars for kapp '%s'",
+				installableObj.FullyQualifiedId())
+		}
 		return nil, errors.WithStack(err)
 	}
 
+	if installableObj != nil {
+		err = applyVarsTemplate(installableObj, stackConfig, templatedVars, provenance)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		err = applySchemaDefaults(installableObj, templatedVars, provenance)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	// templating may have produced new sensitive values
+	redact.AddMatchingValues(templatedVars)
+	if installableObj != nil {
+		addSensitiveVars(installableObj, templatedVars, true)
+	}
+
 	yamlData, err := yaml.Marshal(&templatedVars)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -172,6 +302,306 @@ func (s *Stack) GetTemplatedVars(installableObj interfaces.IInstallable,
 	return templatedVars, nil
 }
 
+// Renders an installable's vars template (if it has one) with the given vars, parses the result as
+// YAML and deep-merges it into the installable's vars in the given map. This lets kapps compute whole
+// maps and lists instead of only strings. Vars set by environment variables and on the command line are
+// merged in again afterwards so they keep the highest precedence.
+func applyVarsTemplate(installableObj interfaces.IInstallable, stackConfig interfaces.IStackConfig,
+	templatedVars map[string]interface{}, provenance vars.Provenance) error {
+	varsTemplate := installableObj.GetDescriptor().VarsTemplate
+	if strings.TrimSpace(varsTemplate) == "" {
+		return nil
+	}
+
+	rendered, err := templater.RenderTemplate(varsTemplate, templatedVars)
+	if err != nil {
+		return errors.Wrapf(err, "Error rendering the vars template for kapp '%s'",
+			installableObj.FullyQualifiedId())
+	}
+
+	log.Logger.Tracef("Rendered vars template for kapp '%s' to:\n%s", installableObj.FullyQualifiedId(),
+		rendered)
+
+	renderedVars := map[interface{}]interface{}{}
+	err = yaml.Unmarshal([]byte(rendered), &renderedVars)
+	if err != nil {
+		return errors.Wrapf(err, "The vars template for kapp '%s' didn't render to a YAML map: %s",
+			installableObj.FullyQualifiedId(), rendered)
+	}
+
+	kappData, ok := templatedVars[constants.KappVarsKappKey].(map[interface{}]interface{})
+	if !ok {
+		return errors.New(fmt.Sprintf("No '%s' vars found for kapp '%s'", constants.KappVarsKappKey,
+			installableObj.FullyQualifiedId()))
+	}
+
+	kappVars, ok := kappData[constants.KappVarsVarsKey].(map[interface{}]interface{})
+	if !ok {
+		kappVars = map[interface{}]interface{}{}
+	}
+
+	err = vars.Merge(&kappVars, renderedVars)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	kappVarsPrefix := []string{constants.KappVarsKappKey, constants.KappVarsVarsKey}
+	provenance.Record(kappVarsPrefix, renderedVars, vars.SourceVarsTemplate, "")
+
+	envOverrides := stackConfig.KappVarEnvOverrides(installableObj.FullyQualifiedId())
+	if len(envOverrides) > 0 {
+		envOverridesMap, err := vars.EnvOverridesToMap(envOverrides)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		err = mergeOverrides(kappVars, envOverridesMap)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		provenance.RecordEnvOverrides(kappVarsPrefix, envOverrides)
+	}
+
+	overrides := stackConfig.KappVarOverrides(installableObj.FullyQualifiedId())
+	if len(overrides) > 0 {
+		err = mergeOverrides(kappVars, overrides)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		provenance.Record(kappVarsPrefix, overrides, vars.SourceCliOverride, "")
+	}
+
+	kappData[constants.KappVarsVarsKey] = kappVars
+
+	return nil
+}
+
+// Masks the values of the vars an installable declares as sensitive. If the vars haven't been
+// templated yet, values containing templates are skipped since they'll be masked once rendered.
+func addSensitiveVars(installableObj interfaces.IInstallable, varsData map[string]interface{}, templated bool) {
+	// untemplated vars can contain maps with string keys so look up the kapp's vars by path
+	kappVars, _ := lookupPath(varsData, []string{constants.KappVarsKappKey, constants.KappVarsVarsKey})
+
+	for _, path := range installableObj.GetDescriptor().SensitiveVars {
+		value, ok := lookupPath(kappVars, strings.Split(strings.TrimPrefix(path, "."), "."))
+		if !ok {
+			if templated {
+				log.Logger.Debugf("Sensitive var '%s' isn't set for kapp '%s'", path,
+					installableObj.FullyQualifiedId())
+			}
+			continue
+		}
+
+		if templated {
+			redact.AddValues(value)
+		} else {
+			addUntemplatedValues(value)
+		}
+	}
+}
+
+// Masks the leaf values in nested maps and lists that don't contain templates
+func addUntemplatedValues(value interface{}) {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		for _, child := range typed {
+			addUntemplatedValues(child)
+		}
+	case map[string]interface{}:
+		for _, child := range typed {
+			addUntemplatedValues(child)
+		}
+	case []interface{}:
+		for _, child := range typed {
+			addUntemplatedValues(child)
+		}
+	case string:
+		if !strings.Contains(typed, "{{") {
+			redact.AddValue(typed)
+		}
+	default:
+		redact.AddValues(typed)
+	}
+}
+
+// Merges overridden vars into an installable's vars
+func mergeOverrides(kappVars map[interface{}]interface{}, overrides map[string]interface{}) error {
+	// convert the overrides to the same type as the rendered vars so they can be merged
+	overridesYaml, err := yaml.Marshal(overrides)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	convertedOverrides := map[interface{}]interface{}{}
+	err = yaml.Unmarshal(overridesYaml, &convertedOverrides)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	err = vars.Merge(&kappVars, convertedOverrides)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return nil
+}
+
+// Returns the resolved vars of all kapps referred to by the given installable under the `kapps`
+// namespace, keyed by the name they're referred to by
+func (s *Stack) getReferencedKappsVars(installableObj interfaces.IInstallable,
+	installableVars map[string]interface{}, resolving []string) (map[string]interface{}, error) {
+
+	referencedKapps, err := findReferencedKapps(s, installableObj, installableVars)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	kappsVars := make(map[string]interface{}, len(referencedKapps))
+
+	if len(referencedKapps) == 0 {
+		return kappsVars, nil
+	}
+
+	resolving = append(append([]string{}, resolving...), installableObj.FullyQualifiedId())
+
+	keys := make([]string, 0, len(referencedKapps))
+	for key := range referencedKapps {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		referencedObj := referencedKapps[key]
+
+		for i, resolvingId := range resolving {
+			if resolvingId == referencedObj.FullyQualifiedId() {
+				cycle := append(append([]string{}, resolving[i:]...), resolvingId)
+				return nil, errors.New(fmt.Sprintf("Circular references between the vars of "+
+					"kapps: %s", strings.Join(cycle, " -> ")))
+			}
+		}
+
+		log.Logger.Debugf("Resolving vars of kapp '%s' referred to by kapp '%s'",
+			referencedObj.FullyQualifiedId(), installableObj.FullyQualifiedId())
+
+		referencedVars, err := s.getTemplatedVars(referencedObj, map[string]interface{}{}, resolving, nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error resolving the vars of kapp '%s' referred to "+
+				"by kapp '%s'", referencedObj.FullyQualifiedId(), installableObj.FullyQualifiedId())
+		}
+
+		kappsVars[key] = referencedVars[constants.KappVarsKappKey]
+	}
+
+	return kappsVars, nil
+}
+
+// Returns the fully-qualified IDs of kapps whose vars are referred to by the given installable,
+// e.g. with `{{ .kapps.somekapp.vars.thevar }}`
+func ReferencedKapps(stackObj interfaces.IStack, installableObj interfaces.IInstallable) ([]string, error) {
+	installableVars, err := installableObj.Vars(stackObj)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	referencedKapps, err := findReferencedKapps(stackObj, installableObj, installableVars)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	ids := make([]string, 0)
+	for _, referencedObj := range referencedKapps {
+		if !utils.InStringArray(ids, referencedObj.FullyQualifiedId()) {
+			ids = append(ids, referencedObj.FullyQualifiedId())
+		}
+	}
+	sort.Strings(ids)
+
+	return ids, nil
+}
+
+// Finds references to other kapps in an installable's vars and descriptor, returning the
+// referenced installables keyed by the name they were referred to by
+func findReferencedKapps(stackObj interfaces.IStack, installableObj interfaces.IInstallable,
+	installableVars map[string]interface{}) (map[string]interfaces.IInstallable, error) {
+
+	varsYaml, err := yaml.Marshal(&installableVars)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	descriptor := installableObj.GetDescriptor()
+	descriptorYaml, err := yaml.Marshal(&descriptor)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	searchText := string(varsYaml) + string(descriptorYaml)
+
+	// only the vars of kapps that are referred to explicitly are resolved, so other uses of `.kapps`
+	// (e.g. `{{ range .kapps }}`) would silently find nothing
+	for _, action := range templateActionRegex.FindAllString(searchText, -1) {
+		if kappsRegex.MatchString(kappReferenceRegex.ReplaceAllString(action, "")) {
+			return nil, errors.New(fmt.Sprintf("Kapp '%s' refers to '.kapps' in '%s' but only "+
+				"references to the vars of specific kapps can be resolved, e.g. "+
+				"'.kapps.<kapp-id>.vars.<var>' or 'index .kapps \"<kapp-id>\"'",
+				installableObj.FullyQualifiedId(), action))
+		}
+	}
+
+	keys := make([]string, 0)
+	for _, match := range kappReferenceRegex.FindAllStringSubmatch(searchText, -1) {
+		keys = append(keys, match[1])
+	}
+	for _, match := range kappVarReferenceRegex.FindAllStringSubmatch(searchText, -1) {
+		keys = append(keys, templater.TemplateKey(match[1]))
+	}
+
+	referencedKapps := make(map[string]interfaces.IInstallable, 0)
+
+	if len(keys) == 0 {
+		return referencedKapps, nil
+	}
+
+	kappsByKey := kappTemplateKeys(stackObj, installableObj.ManifestId())
+
+	for _, key := range keys {
+		referencedObj, ok := kappsByKey[key]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Kapp '%s' refers to the vars of kapp '%s' "+
+				"but no kapp with that ID exists in the stack. Kapps in other manifests must be "+
+				"referred to as '<manifest-id>%s<kapp-id>', and hyphens must be replaced by "+
+				"underscores", installableObj.FullyQualifiedId(), key, constants.TemplateNamespaceSeparator))
+		}
+
+		referencedKapps[key] = referencedObj
+	}
+
+	return referencedKapps, nil
+}
+
+// Returns all kapps in the stack keyed by the names they can be referred to by in templates from a
+// kapp in the given manifest. Kapps in the same manifest can be referred to by their ID, but kapps in
+// other manifests must be fully-qualified. As with outputs, hyphens are replaced by underscores to keep
+// Go's templating library happy.
+func kappTemplateKeys(stackObj interfaces.IStack, manifestId string) map[string]interfaces.IInstallable {
+	kappsByKey := make(map[string]interfaces.IInstallable, 0)
+
+	for _, manifest := range stackObj.GetConfig().Manifests() {
+		for _, installableObj := range manifest.Installables() {
+			kappsByKey[templater.TemplateKey(installableObj.FullyQualifiedId())] = installableObj
+
+			if installableObj.ManifestId() == manifestId {
+				kappsByKey[templater.TemplateKey(installableObj.Id())] = installableObj
+			}
+		}
+	}
+
+	return kappsByKey
+}
+
 // Reload provider vars
 func (s *Stack) RefreshProviderVars() error {
 	providerVars, err := provider.GetVarsFromFiles(s.GetProvider(), s.GetConfig())
@@ -191,6 +621,42 @@ func (s *Stack) RefreshProviderVars() error {
 	return nil
 }
 
+// Loads the registry persisted for the stack and cluster in the workspace so changes can be saved
+// there by SaveRegistry, unless disabled in the config file
+func (s *Stack) PersistRegistry(workspaceDir string) error {
+	if config.CurrentConfig == nil || !config.CurrentConfig.Registry.Persist {
+		return nil
+	}
+
+	path, err := registry.StorePath(workspaceDir, s.config.GetName(), s.config.GetCluster())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	// we may be called again, e.g. when watching for changes
+	if s.registryPersister != nil && path == s.registryPersister.Path() {
+		return nil
+	}
+
+	persister, err := registry.Persist(s.registry, path, config.CurrentConfig.Registry.Encrypt,
+		s.config.RefreshOutputs())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	s.registryPersister = persister
+	return nil
+}
+
+// Saves the registry to the workspace if it's persisted and has changed
+func (s *Stack) SaveRegistry() error {
+	if s.registryPersister == nil {
+		return nil
+	}
+
+	return s.registryPersister.Flush()
+}
+
 // Loads the configs for all installables
 func (s *Stack) LoadInstallables(workspaceDir string) error {
 	installables := make([]interfaces.IInstallable, 0)
@@ -19,9 +19,13 @@ package stack
 import (
 	"github.com/stretchr/testify/assert"
 	"github.com/sugarkube/sugarkube/internal/pkg/constants"
+	"github.com/sugarkube/sugarkube/internal/pkg/installable"
+	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
 	"github.com/sugarkube/sugarkube/internal/pkg/registry"
 	"github.com/sugarkube/sugarkube/internal/pkg/structs"
+	"github.com/sugarkube/sugarkube/internal/pkg/vars"
 	"os"
 	"testing"
 )
@@ -110,3 +114,561 @@ func TestTemplatedVarsWithRegistry(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, expectedVarsUpdatedRegistry, templatedVars)
 }
+
+// Returns a stack containing kapps with the given vars, keyed by manifest ID then kapp ID
+func stackWithKappVars(t *testing.T, kappVars map[string]map[string]map[string]interface{}) *Stack {
+	manifests := make([]interfaces.IManifest, 0)
+
+	for manifestId, kapps := range kappVars {
+		installables := make([]interfaces.IInstallable, 0)
+
+		for kappId, vars := range kapps {
+			installableObj, err := installable.New(manifestId, []structs.KappDescriptorWithMaps{
+				{
+					Id:         kappId,
+					KappConfig: structs.KappConfig{Vars: vars},
+				},
+			})
+			assert.Nil(t, err)
+			installables = append(installables, installableObj)
+		}
+
+		manifests = append(manifests, &Manifest{
+			descriptor:   structs.ManifestDescriptor{Id: manifestId},
+			installables: installables,
+		})
+	}
+
+	return &Stack{
+		config:   &StackConfig{manifests: manifests},
+		status:   &ClusterStatus{},
+		registry: registry.New(),
+	}
+}
+
+func findInstallable(stackObj *Stack, fullyQualifiedId string) interfaces.IInstallable {
+	for _, manifest := range stackObj.GetConfig().Manifests() {
+		for _, installableObj := range manifest.Installables() {
+			if installableObj.FullyQualifiedId() == fullyQualifiedId {
+				return installableObj
+			}
+		}
+	}
+
+	return nil
+}
+
+func installableWithVars(t *testing.T, manifestId string, vars map[string]interface{}) interfaces.IInstallable {
+	installableObj, err := installable.New(manifestId, []structs.KappDescriptorWithMaps{
+		{
+			Id:         "other",
+			KappConfig: structs.KappConfig{Vars: vars},
+		},
+	})
+	assert.Nil(t, err)
+	return installableObj
+}
+
+// Test that kapps can refer to the vars of other kapps
+func TestTemplatedVarsReferencingKapps(t *testing.T) {
+	stackObj := stackWithKappVars(t, map[string]map[string]map[string]interface{}{
+		"manifest1": {
+			"kapp-a": {"name": "a-{{ .stack.region }}"},
+			"kappB":  {"fromA": "{{ .kapps.kapp_a.vars.name }}"},
+		},
+		"manifest2": {
+			"kappC": {
+				"fromB": "{{ .kapps.manifest1__kappB.vars.fromA }}",
+				"fromA": `{{ (index .kapps "manifest1__kapp_a").vars.name }}`,
+			},
+		},
+	})
+	stackObj.config.(*StackConfig).stackFile.Region = "eu"
+
+	templatedVars, err := stackObj.GetTemplatedVars(findInstallable(stackObj, "manifest2:kappC"),
+		map[string]interface{}{})
+	assert.Nil(t, err)
+
+	kappVars := templatedVars[constants.KappVarsKappKey].(map[interface{}]interface{})[constants.KappVarsVarsKey]
+	assert.Equal(t, map[interface{}]interface{}{
+		"fromA": "a-eu",
+		"fromB": "a-eu",
+	}, kappVars)
+
+	referencedIds, err := ReferencedKapps(stackObj, findInstallable(stackObj, "manifest2:kappC"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"manifest1:kapp-a", "manifest1:kappB"}, referencedIds)
+
+	// kapps in other manifests must be referred to by their fully-qualified ID
+	_, err = ReferencedKapps(stackObj, installableWithVars(t, "manifest2", map[string]interface{}{
+		"x": "{{ .kapps.kappB.vars.fromA }}",
+	}))
+	assert.Error(t, err)
+
+	// references to `.kapps` that don't name a kapp can't be resolved
+	for _, value := range []string{
+		"{{ range $id, $kapp := .kapps }}{{ $id }}{{ end }}",
+		"{{ with .kapps }}{{ .kapp_a.vars.name }}{{ end }}",
+		"{{ $k := .kapps }}{{ $k.kapp_a.vars.name }}",
+	} {
+		_, err = ReferencedKapps(stackObj, installableWithVars(t, "manifest1", map[string]interface{}{
+			"x": value,
+		}))
+		assert.Error(t, err, value)
+		assert.Contains(t, err.Error(), "only references to the vars of specific kapps", value)
+	}
+}
+
+// Test that circular references between kapps are reported with the full cycle
+func TestTemplatedVarsCircularKappReferences(t *testing.T) {
+	stackObj := stackWithKappVars(t, map[string]map[string]map[string]interface{}{
+		"manifest1": {
+			"kappA": {"x": "{{ .kapps.kappB.vars.y }}"},
+			"kappB": {"y": "{{ .kapps.kappC.vars.z }}"},
+			"kappC": {"z": "{{ .kapps.kappA.vars.x }}"},
+		},
+	})
+
+	_, err := stackObj.GetTemplatedVars(findInstallable(stackObj, "manifest1:kappA"),
+		map[string]interface{}{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "manifest1:kappA -> manifest1:kappB -> manifest1:kappC -> manifest1:kappA")
+}
+
+// Test that vars templates are rendered, parsed as YAML then deep-merged into kapp vars
+func TestTemplatedVarsWithVarsTemplate(t *testing.T) {
+	installableObj, err := installable.New("manifest1", []structs.KappDescriptorWithMaps{
+		{
+			Id: "kappA",
+			KappConfig: structs.KappConfig{
+				Vars: map[string]interface{}{
+					"endpoints": map[string]interface{}{
+						"db":    "db.example.com",
+						"cache": "cache.example.com",
+					},
+					"helm": map[string]interface{}{
+						"replicas": 1,
+						"image":    "nginx",
+					},
+				},
+				VarsTemplate: `
+helm:
+  replicas: 2
+  hosts:
+{{- range $name, $host := .kapp.vars.endpoints }}
+    - name: {{ $name }}
+      host: {{ $host }}
+{{- end }}
+`,
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	stackObj := &Stack{
+		config: &StackConfig{manifests: []interfaces.IManifest{
+			&Manifest{
+				descriptor:   structs.ManifestDescriptor{Id: "manifest1"},
+				installables: []interfaces.IInstallable{installableObj},
+			},
+		}},
+		status:   &ClusterStatus{},
+		registry: registry.New(),
+	}
+
+	templatedVars, err := stackObj.GetTemplatedVars(installableObj, map[string]interface{}{})
+	assert.Nil(t, err)
+
+	kappVars := templatedVars[constants.KappVarsKappKey].(map[interface{}]interface{})[constants.KappVarsVarsKey]
+	assert.Equal(t, map[interface{}]interface{}{
+		"replicas": 2,
+		"image":    "nginx",
+		"hosts": []interface{}{
+			map[interface{}]interface{}{"name": "cache", "host": "cache.example.com"},
+			map[interface{}]interface{}{"name": "db", "host": "db.example.com"},
+		},
+	}, kappVars.(map[interface{}]interface{})["helm"])
+
+	// templating the descriptor shouldn't touch the vars template
+	err = installableObj.TemplateDescriptor(templatedVars)
+	assert.Nil(t, err)
+	assert.Contains(t, installableObj.GetDescriptor().VarsTemplate, "{{- range $name, $host")
+}
+
+// Test that vars set on the command line take precedence over all other kapp vars
+func TestTemplatedVarsWithOverrides(t *testing.T) {
+	installableObj, err := installable.New("manifest1", []structs.KappDescriptorWithMaps{
+		{
+			Id: "kappA",
+			KappConfig: structs.KappConfig{
+				Vars: map[string]interface{}{
+					// use the same types as when vars are loaded from YAML
+					"image": map[interface{}]interface{}{
+						"repo": "nginx",
+						"tag":  "1.0",
+					},
+				},
+				VarsTemplate: "replicas: 2",
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	stackObj := &Stack{
+		config: &StackConfig{
+			stackFile: structs.StackFile{
+				KappVarOverrides: map[string]map[string]interface{}{
+					"manifest1:kappA": {
+						"image":    map[interface{}]interface{}{"tag": "1.1"},
+						"replicas": 3,
+					},
+				},
+			},
+			manifests: []interfaces.IManifest{
+				&Manifest{
+					descriptor:   structs.ManifestDescriptor{Id: "manifest1"},
+					installables: []interfaces.IInstallable{installableObj},
+				},
+			},
+		},
+		status:   &ClusterStatus{},
+		registry: registry.New(),
+	}
+
+	templatedVars, err := stackObj.GetTemplatedVars(installableObj, map[string]interface{}{})
+	assert.Nil(t, err)
+
+	kappVars := templatedVars[constants.KappVarsKappKey].(map[interface{}]interface{})[constants.KappVarsVarsKey]
+	assert.Equal(t, map[interface{}]interface{}{
+		"image": map[interface{}]interface{}{
+			"repo": "nginx",
+			"tag":  "1.1",
+		},
+		"replicas": 3,
+	}, kappVars)
+
+	// overrides for kapps that aren't in the stack are rejected
+	err = validateKappVarOverrides(&StackConfig{
+		stackFile: structs.StackFile{
+			KappVarOverrides: map[string]map[string]interface{}{"manifest1:typo": {"a": 1}},
+		},
+		manifests: stackObj.config.Manifests(),
+	})
+	assert.Error(t, err)
+}
+
+func TestTemplatedVarsWithEnvOverrides(t *testing.T) {
+	installableObj, err := installable.New("manifest1", []structs.KappDescriptorWithMaps{
+		{
+			Id: "kapp-a",
+			KappConfig: structs.KappConfig{
+				Vars: map[string]interface{}{
+					"image": map[interface{}]interface{}{
+						"repo": "nginx",
+						"tag":  "1.0",
+					},
+					"region": "{{ .region }}",
+				},
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	stackConfig := &StackConfig{
+		stackFile: structs.StackFile{
+			KappVarOverrides: map[string]map[string]interface{}{
+				"manifest1:kapp-a": {"replicas": 3},
+			},
+		},
+		manifests: []interfaces.IManifest{
+			&Manifest{
+				descriptor:   structs.ManifestDescriptor{Id: "manifest1"},
+				installables: []interfaces.IInstallable{installableObj},
+			},
+		},
+		providerVars: map[string]interface{}{"region": "us-east-1"},
+	}
+
+	err = loadEnvOverrides(stackConfig, []string{
+		"SUGARKUBE_VAR_MANIFEST1__KAPP_A__IMAGE__TAG=1.1",
+		"SUGARKUBE_VAR_MANIFEST1__KAPP_A__REPLICAS=2",
+		"SUGARKUBE_STACKVAR_REGION=eu-west-1",
+	})
+	assert.Nil(t, err)
+
+	stackObj := &Stack{
+		config:   stackConfig,
+		status:   &ClusterStatus{},
+		registry: registry.New(),
+	}
+
+	templatedVars, provenance, err := stackObj.ExplainVars(installableObj)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "eu-west-1", templatedVars["region"])
+
+	// vars set on the command line take precedence over env vars
+	kappVars := templatedVars[constants.KappVarsKappKey].(map[interface{}]interface{})[constants.KappVarsVarsKey]
+	assert.Equal(t, map[interface{}]interface{}{
+		"image": map[interface{}]interface{}{
+			"repo": "nginx",
+			"tag":  1.1,
+		},
+		"region":   "eu-west-1",
+		"replicas": 3,
+	}, kappVars)
+
+	assert.Equal(t, vars.Origin{Source: vars.SourceEnvOverride,
+		Detail: "SUGARKUBE_VAR_MANIFEST1__KAPP_A__IMAGE__TAG", Raw: 1.1},
+		provenance["kapp.vars.image.tag"])
+	assert.Equal(t, vars.SourceEnvOverride, provenance["region"].Source)
+	assert.Equal(t, vars.SourceCliOverride, provenance["kapp.vars.replicas"].Source)
+
+	// env vars for kapps that aren't in the stack are ignored
+	err = loadEnvOverrides(stackConfig, []string{"SUGARKUBE_VAR_MANIFEST1__TYPO__A=1"})
+	assert.Nil(t, err)
+	assert.Empty(t, stackConfig.kappVarEnvOverrides)
+}
+
+func TestExplainVars(t *testing.T) {
+	installableObj, err := installable.New("manifest1", []structs.KappDescriptorWithMaps{
+		{
+			KappConfig: structs.KappConfig{
+				Vars: map[string]interface{}{
+					"namespace": "default-ns",
+					"team":      "platform",
+				},
+			},
+			Origin: "manifest defaults",
+		},
+		{
+			Id: "kappA",
+			KappConfig: structs.KappConfig{
+				Vars: map[string]interface{}{
+					"namespace": "kapp-ns",
+					"greeting":  "hello {{ .stack.name }}",
+				},
+				VarsTemplate: "size: large",
+			},
+			Origin: "manifest kapp descriptor",
+		},
+	})
+	assert.Nil(t, err)
+
+	stackObj := &Stack{
+		config: &StackConfig{
+			stackFile: structs.StackFile{
+				Name: "dev",
+				KappVarOverrides: map[string]map[string]interface{}{
+					"manifest1:kappA": {"replicas": 3},
+				},
+			},
+			manifests: []interfaces.IManifest{
+				&Manifest{
+					descriptor:   structs.ManifestDescriptor{Id: "manifest1"},
+					installables: []interfaces.IInstallable{installableObj},
+				},
+			},
+		},
+		status:   &ClusterStatus{},
+		registry: registry.New(),
+	}
+
+	templatedVars, provenance, err := stackObj.ExplainVars(installableObj)
+	assert.Nil(t, err)
+
+	expectedSources := map[string]string{
+		"stack.name":                    vars.SourceStackIntrinsic,
+		"kapp.id":                       vars.SourceKappIntrinsic,
+		"kapp.vars.team":                "manifest defaults",
+		"kapp.vars.namespace":           "manifest kapp descriptor",
+		"kapp.vars.greeting":            "manifest kapp descriptor",
+		"kapp.vars.size":                vars.SourceVarsTemplate,
+		"kapp.vars.replicas":            vars.SourceCliOverride,
+		"sugarkube.defaultVars":         vars.SourceRuntime,
+		"kapp.templates":                vars.SourceKappTemplates,
+		constants.RegistryKeyKubeConfig: vars.SourceRegistry,
+	}
+
+	for path, source := range expectedSources {
+		assert.Equal(t, source, provenance[path].Source, "unexpected source for '%s'", path)
+	}
+
+	explanations := map[string]vars.Explanation{}
+	for _, explanation := range provenance.Explain(templatedVars) {
+		explanations[explanation.Path] = explanation
+	}
+
+	assert.Equal(t, "hello dev", explanations["kapp.vars.greeting"].Value)
+	assert.Equal(t, "hello {{ .stack.name }}", explanations["kapp.vars.greeting"].Raw)
+	assert.Equal(t, 3, explanations["kapp.vars.replicas"].Value)
+}
+
+func TestValidateVars(t *testing.T) {
+	varsSchema := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"region"},
+		"properties": map[string]interface{}{
+			"debug":    map[string]interface{}{"type": "boolean"},
+			"replicas": map[string]interface{}{"type": "integer", "default": 2, "minimum": 1},
+			"size": map[string]interface{}{
+				"enum": []interface{}{"small", "large"},
+			},
+			"region": map[string]interface{}{"type": "string"},
+		},
+	}
+
+	newStack := func(kappVars map[string]interface{}) (*Stack, interfaces.IInstallable) {
+		installableObj, err := installable.New("manifest1", []structs.KappDescriptorWithMaps{
+			{
+				Id: "kappA",
+				KappConfig: structs.KappConfig{
+					Vars:       kappVars,
+					VarsSchema: varsSchema,
+				},
+				Origin: "manifest kapp descriptor",
+			},
+		})
+		assert.Nil(t, err)
+
+		return &Stack{
+			config: &StackConfig{
+				stackFile: structs.StackFile{Name: "dev"},
+				manifests: []interfaces.IManifest{
+					&Manifest{
+						descriptor:   structs.ManifestDescriptor{Id: "manifest1"},
+						installables: []interfaces.IInstallable{installableObj},
+					},
+				},
+			},
+			status:   &ClusterStatus{},
+			registry: registry.New(),
+		}, installableObj
+	}
+
+	stackObj, installableObj := newStack(map[string]interface{}{
+		"debug":  true,
+		"size":   "large",
+		"region": "eu-west-1",
+	})
+
+	assert.Nil(t, stackObj.ValidateVars(installableObj, nil))
+
+	// defaults from the schema are set for missing vars
+	explainedVars, provenance, err := stackObj.ExplainVars(installableObj)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, explainedVars["kapp"].(map[interface{}]interface{})["vars"].(map[interface{}]interface{})["replicas"])
+	assert.Equal(t, vars.SourceSchemaDefault, provenance["kapp.vars.replicas"].Source)
+
+	// templated values are strings so they're converted to the types in the schema
+	stackObj, installableObj = newStack(map[string]interface{}{
+		"debug":    `{{ eq .stack.name "dev" }}`,
+		"replicas": "{{ len .stack.name }}",
+		"size":     "large",
+		"region":   "eu-west-1",
+	})
+
+	assert.Nil(t, stackObj.ValidateVars(installableObj, nil))
+
+	// the vars run steps are templated with are validated
+	templatedVars, err := stackObj.GetTemplatedVars(installableObj, map[string]interface{}{})
+	assert.Nil(t, err)
+	templatedVars["kapp"].(map[interface{}]interface{})["vars"].(map[interface{}]interface{})["replicas"] = "0"
+	err = stackObj.ValidateVars(installableObj, templatedVars)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "kapp.vars.replicas: 0 is less than the minimum of 1")
+
+	stackObj, installableObj = newStack(map[string]interface{}{
+		"debug": "no",
+		"size":  "medium",
+	})
+
+	err = stackObj.ValidateVars(installableObj, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Vars for kapp 'manifest1:kappA' don't conform to its vars_schema:\n"+
+		"  * kapp.vars.region: is required but isn't set\n"+
+		"  * kapp.vars.debug: expected boolean but got string \"no\" (set by manifest kapp descriptor)\n"+
+		"  * kapp.vars.size: string \"medium\" isn't one of the allowed values: \"small\", \"large\" "+
+		"(set by manifest kapp descriptor)", err.Error())
+}
+
+func TestTemplatedVarsRegistersSensitiveValues(t *testing.T) {
+	defer redact.Reset()
+
+	installableObj, err := installable.New("manifest1", []structs.KappDescriptorWithMaps{
+		{
+			Id: "kapp-a",
+			KappConfig: structs.KappConfig{
+				Vars: map[string]interface{}{
+					"db": map[interface{}]interface{}{
+						"user":     "admin-user",
+						"password": "{{ .dbPassword }}",
+					},
+					"license": "license-key-1234",
+				},
+				SensitiveVars: []string{"license", "missing"},
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	stackObj := &Stack{
+		config: &StackConfig{
+			manifests: []interfaces.IManifest{
+				&Manifest{
+					descriptor:   structs.ManifestDescriptor{Id: "manifest1"},
+					installables: []interfaces.IInstallable{installableObj},
+				},
+			},
+			providerVars: map[string]interface{}{"dbPassword": "db-pass-5678"},
+		},
+		status:   &ClusterStatus{},
+		registry: registry.New(),
+	}
+
+	_, err = stackObj.GetTemplatedVars(installableObj, map[string]interface{}{})
+	assert.Nil(t, err)
+
+	assert.Equal(t, "user=admin-user password="+redact.Mask+" license="+redact.Mask,
+		redact.String("user=admin-user password=db-pass-5678 license=license-key-1234"))
+}
+
+// sensitive vars are masked before vars are resolved since resolving them logs them
+func TestSensitiveVarsRegisteredBeforeResolving(t *testing.T) {
+	defer redact.Reset()
+
+	installableObj, err := installable.New("manifest1", []structs.KappDescriptorWithMaps{
+		{
+			Id: "kapp-a",
+			KappConfig: structs.KappConfig{
+				Vars: map[string]interface{}{
+					"license": "license-key-5678",
+					"cert":    "{{ .someCert }}",
+					"broken":  "{{ .unclosed ",
+				},
+				SensitiveVars: []string{"license", "cert"},
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	stackObj := &Stack{
+		config: &StackConfig{
+			manifests: []interfaces.IManifest{
+				&Manifest{
+					descriptor:   structs.ManifestDescriptor{Id: "manifest1"},
+					installables: []interfaces.IInstallable{installableObj},
+				},
+			},
+		},
+		status:   &ClusterStatus{},
+		registry: registry.New(),
+	}
+
+	_, err = stackObj.GetTemplatedVars(installableObj, map[string]interface{}{})
+	assert.Error(t, err)
+
+	// templates aren't masked, only the values they render to
+	assert.Equal(t, "license="+redact.Mask+" cert={{ .someCert }}",
+		redact.String("license=license-key-5678 cert={{ .someCert }}"))
+}
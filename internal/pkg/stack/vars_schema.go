@@ -0,0 +1,174 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package stack
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/constants"
+	"github.com/sugarkube/sugarkube/internal/pkg/interfaces"
+	"github.com/sugarkube/sugarkube/internal/pkg/schema"
+	"github.com/sugarkube/sugarkube/internal/pkg/vars"
+	"strings"
+)
+
+// Validates an installable's templated vars against the schema in its `vars_schema` field (if it
+// has one). If `templatedVars` is nil the vars are templated without any extra vars. Strings are
+// converted to the types the schema expects first since templated values are always strings. The
+// returned error lists every invalid value along with where it was set.
+func (s *Stack) ValidateVars(installableObj interfaces.IInstallable, templatedVars map[string]interface{}) error {
+	varsSchema, err := getVarsSchema(installableObj)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if varsSchema == nil {
+		return nil
+	}
+
+	if templatedVars == nil {
+		templatedVars, err = s.GetTemplatedVars(installableObj, map[string]interface{}{})
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+
+	kappVars, _ := getKappVars(templatedVars)
+
+	validationErrors, err := schema.Validate(varsSchema, schema.Coerce(varsSchema, kappVars))
+	if err != nil {
+		return errors.Wrapf(err, "Invalid vars_schema for kapp '%s'", installableObj.FullyQualifiedId())
+	}
+
+	if len(validationErrors) == 0 {
+		return nil
+	}
+
+	// only work out where values came from if they're invalid
+	_, provenance, err := s.ExplainVars(installableObj)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	messages := make([]string, 0, len(validationErrors))
+
+	for _, validationError := range validationErrors {
+		path := append([]string{constants.KappVarsKappKey, constants.KappVarsVarsKey},
+			validationError.Path...)
+		message := fmt.Sprintf("  * %s: %s", schema.FormatPath(path), validationError.Message)
+
+		// list elements are recorded as part of the list they're in
+		keys := make([]string, 0, len(path))
+		for _, element := range path {
+			if !strings.HasPrefix(element, "[") {
+				keys = append(keys, element)
+			}
+		}
+
+		if origin, ok := provenance.Find(strings.Join(keys, ".")); ok {
+			message = fmt.Sprintf("%s (set by %s)", message, origin)
+		}
+
+		messages = append(messages, message)
+	}
+
+	return errors.New(fmt.Sprintf("Vars for kapp '%s' don't conform to its vars_schema:\n%s",
+		installableObj.FullyQualifiedId(), strings.Join(messages, "\n")))
+}
+
+// Sets defaults declared in an installable's vars schema for any of its vars that are missing
+func applySchemaDefaults(installableObj interfaces.IInstallable, templatedVars map[string]interface{},
+	provenance vars.Provenance) error {
+	varsSchema, err := getVarsSchema(installableObj)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if varsSchema == nil {
+		return nil
+	}
+
+	kappVars, kappData := getKappVars(templatedVars)
+	if kappData == nil {
+		return nil
+	}
+
+	defaultedVars, applied := schema.ApplyDefaults(varsSchema, kappVars)
+	kappData[constants.KappVarsVarsKey] = defaultedVars
+
+	for _, path := range applied {
+		value, _ := lookupPath(defaultedVars, path)
+		provenance.Record(append([]string{constants.KappVarsKappKey, constants.KappVarsVarsKey}, path...),
+			value, vars.SourceSchemaDefault, "")
+	}
+
+	return nil
+}
+
+// Returns an installable's vars schema with values converted to the types they'd have if they'd
+// been loaded from YAML, or nil if it doesn't have one
+func getVarsSchema(installableObj interfaces.IInstallable) (map[string]interface{}, error) {
+	varsSchema := installableObj.GetDescriptor().VarsSchema
+	if len(varsSchema) == 0 {
+		return nil, nil
+	}
+
+	// descriptors are deep copied via JSON which converts all numbers to floats
+	normalised, err := vars.Normalise(varsSchema)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error loading the vars_schema for kapp '%s'",
+			installableObj.FullyQualifiedId())
+	}
+
+	return normalised, nil
+}
+
+// Returns an installable's vars from templated vars along with the map they're in
+func getKappVars(templatedVars map[string]interface{}) (interface{}, map[interface{}]interface{}) {
+	kappData, ok := templatedVars[constants.KappVarsKappKey].(map[interface{}]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	return kappData[constants.KappVarsVarsKey], kappData
+}
+
+// Returns the value at a path of string keys in nested maps
+func lookupPath(data interface{}, path []string) (interface{}, bool) {
+	current := data
+
+	for _, element := range path {
+		switch typed := current.(type) {
+		case map[interface{}]interface{}:
+			value, ok := typed[element]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case map[string]interface{}:
+			value, ok := typed[element]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
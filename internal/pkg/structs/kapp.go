@@ -32,12 +32,22 @@ type Template struct {
 type Output struct {
 	Id   string
 	Path string
-	// todo - implement - this allows multiple kapps to set the same key. Don't allow kapps to overwrite the outputs under `outputs.kapp` though
-	//RegistryPath      string // Path to store the output in the registry (some paths are protected and may not be specified)
-	Format    string
-	Sensitive bool // sensitive outputs will be deleted after adding the data to the registry to try to prevent
+	// a dotted path to also store the output at in the registry (e.g. `network.vpc`). This allows
+	// different kapps to provide the same value. Paths under `outputs`, `kubeconfig` and `this` are protected.
+	RegistryPath string `yaml:"registry_path,omitempty" mapstructure:"registry_path"`
+	Format       string
+	// a JSONPath (starting with `$`) or jq expression selecting the data to publish from the output
+	Select string `yaml:",omitempty"`
+	// keys of the selected data to rename, keyed by their current names
+	Rename    map[string]string `yaml:",omitempty"`
+	Sensitive bool              // sensitive outputs will be deleted after adding the data to the registry to try to prevent
 	// secrets lingering on disk
 	Conditions []string `yaml:",omitempty"` // outputs will only be loaded if all these are true
+	// a JSON schema the published output must conform to. During dry runs, missing outputs are
+	// generated from it if there's no `dry_run_value`
+	Schema map[string]interface{} `yaml:",omitempty"`
+	// the value to publish for the output during dry runs if it's missing
+	DryRunValue interface{} `yaml:"dry_run_value,omitempty" mapstructure:"dry_run_value"`
 }
 
 type Source struct {
@@ -108,8 +118,12 @@ type KappConfig struct {
 	RunUnits             map[string]RunUnit     `yaml:"run_units" mapstructure:"run_units"`
 	DependsOn            []Dependency           `yaml:"depends_on,omitempty"`   // fully qualified IDs of other kapps this depends on
 	IgnoreGlobalDefaults bool                   `yaml:"ignore_global_defaults"` // don't add globally configured defaults for each requirement
-	// todo - implement
-	//VarsTemplate string		// this will be read as a string, templated then converted to YAML and merged with the Vars map
+	// this will be read as a string, templated then parsed as YAML and merged with the Vars map
+	VarsTemplate string `yaml:"vars_template,omitempty" mapstructure:"vars_template"`
+	// a JSON schema the kapp's final vars must conform to. Defaults declared in it are set for missing vars
+	VarsSchema map[string]interface{} `yaml:"vars_schema,omitempty" mapstructure:"vars_schema"`
+	// paths to kapp vars (e.g. `db.password`) whose values are masked in all output
+	SensitiveVars []string `yaml:"sensitive_vars,omitempty" mapstructure:"sensitive_vars"`
 }
 
 // KappDescriptors describe where to find a kapp plus some other data, but isn't the kapp itself.
@@ -129,4 +143,6 @@ type KappDescriptorWithMaps struct {
 	KappConfig `yaml:",inline"`
 	Sources    map[string]Source `yaml:",omitempty"` // keys are object IDs so values for individual objects can be overridden
 	Outputs    map[string]Output `yaml:",omitempty"` // keys are object IDs so values for individual objects can be overridden
+	// describes where this descriptor came from so we can explain where vars came from
+	Origin string `yaml:"-"`
 }
@@ -47,4 +47,8 @@ type StackFile struct {
 	ManifestDescriptors []ManifestDescriptor `yaml:"manifests"` // this struct should be immutable, so don't store pointers
 	TemplateDirs        []string             `yaml:"template_dirs"`
 	Defaults            KappConfig           // Defaults that apply to all manifests in the stack
+	// vars set on the command line keyed by fully-qualified kapp ID. These take precedence over all other kapp vars.
+	KappVarOverrides map[string]map[string]interface{} `yaml:"-"`
+	// whether to run output steps again instead of reusing outputs persisted in the registry
+	RefreshOutputs bool `yaml:"-"`
 }
@@ -17,14 +17,23 @@
 package templater
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/imdario/mergo"
 	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/convert"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/secrets"
 	"github.com/sugarkube/sugarkube/internal/pkg/utils"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
 	"os"
+	"strings"
 	"text/template"
 )
 
+// Functions available to all templates in addition to Sprig's. Some of these replace Sprig functions
+// of the same name to support the map[interface{}]interface{} maps that YAML is unmarshalled into.
 var CustomFunctions = template.FuncMap{
 	"exists":      exists,
 	"findFiles":   findFiles,
@@ -32,6 +41,14 @@ var CustomFunctions = template.FuncMap{
 	"listString":  listString,
 	"isSet":       isSet,
 	"removeEmpty": removeEmpty,
+	"required":    required,
+	"toYaml":      toYaml,
+	"fromYaml":    fromYaml,
+	"toJson":      toJson,
+	"fromJson":    fromJson,
+	"merge":       merge,
+	"readFile":    readFile,
+	"secret":      secrets.Resolve, // e.g. `secret "vault://secret/app/db#password"`
 }
 
 // Turn separate string parameters into a single []string array
@@ -149,3 +166,94 @@ func isSet(input map[interface{}]interface{}, key string) bool {
 	_, ok := input[key]
 	return ok
 }
+
+// Returns an error containing the message if the value is nil or an empty string, otherwise returns
+// the value
+func required(message string, value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, errors.New(message)
+	}
+
+	if str, ok := value.(string); ok && str == "" {
+		return nil, errors.New(message)
+	}
+
+	return value, nil
+}
+
+// Serialises a value to YAML without a trailing newline
+func toYaml(value interface{}) (string, error) {
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return "", errors.Wrapf(err, "Error serialising value to YAML: %#v", value)
+	}
+
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+// Parses a YAML map
+func fromYaml(input string) (map[string]interface{}, error) {
+	output := map[string]interface{}{}
+
+	err := yaml.Unmarshal([]byte(input), &output)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error parsing YAML: %s", input)
+	}
+
+	return output, nil
+}
+
+// Serialises a value to JSON
+func toJson(value interface{}) (string, error) {
+	data, err := json.Marshal(convert.StringifyKeys(value))
+	if err != nil {
+		return "", errors.Wrapf(err, "Error serialising value to JSON: %#v", value)
+	}
+
+	return string(data), nil
+}
+
+// Parses a JSON object
+func fromJson(input string) (map[string]interface{}, error) {
+	output := map[string]interface{}{}
+
+	err := json.Unmarshal([]byte(input), &output)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error parsing JSON: %s", input)
+	}
+
+	return output, nil
+}
+
+// Deep-merges maps into the first one. As with Sprig's `merge`, values in earlier maps take
+// precedence over values in later ones.
+func merge(dest interface{}, sources ...interface{}) (map[string]interface{}, error) {
+	merged, ok := convert.StringifyKeys(dest).(map[string]interface{})
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Can't merge into a value that isn't a map: %#v", dest))
+	}
+
+	for _, source := range sources {
+		sourceMap, ok := convert.StringifyKeys(source).(map[string]interface{})
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Can't merge a value that isn't a map: %#v", source))
+		}
+
+		err := mergo.Merge(&merged, sourceMap)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	return merged, nil
+}
+
+// Returns the contents of a file
+func readFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "Error reading file '%s'", path)
+	}
+
+	return string(data), nil
+}
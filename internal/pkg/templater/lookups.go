@@ -0,0 +1,138 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package templater
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/constants"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// Returns functions that look up values in the vars a template is being rendered with. Unlike
+// referring to values directly, these return an error if the value doesn't exist.
+func lookupFunctions(vars map[string]interface{}) template.FuncMap {
+	return template.FuncMap{
+		// e.g. `output "manifest:kapp" "some_output.some_key"`
+		"output": func(kappId string, path string) (interface{}, error) {
+			kappKey := TemplateKey(kappId)
+
+			outputs, ok := lookupPath(vars, []string{constants.RegistryKeyOutputs, kappKey})
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("No outputs found for kapp '%s'. Outputs "+
+					"are only available after the kapp has been installed or its outputs loaded",
+					kappId))
+			}
+
+			elements := strings.Split(path, ".")
+			// output IDs have hyphens replaced by underscores when they're added to the registry
+			elements[0] = strings.Replace(elements[0], "-", "_", -1)
+
+			value, ok := lookupPath(outputs, elements)
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("No output '%s' found for kapp '%s'", path, kappId))
+			}
+
+			return value, nil
+		},
+		// e.g. `kappVar "some.key"` for the current kapp or `kappVar "manifest:kapp" "some.key"`
+		// for another kapp
+		"kappVar": func(args ...string) (interface{}, error) {
+			var prefix []string
+			var path string
+			var description string
+
+			switch len(args) {
+			case 1:
+				prefix = []string{constants.KappVarsKappKey, constants.KappVarsVarsKey}
+				path = args[0]
+				description = "this kapp"
+			case 2:
+				prefix = []string{constants.KappVarsKappsKey, TemplateKey(args[0]), constants.KappVarsVarsKey}
+				path = args[1]
+				description = fmt.Sprintf("kapp '%s'", args[0])
+			default:
+				return nil, errors.New(fmt.Sprintf("kappVar takes either a path or a kapp ID "+
+					"and a path, but got %d arguments", len(args)))
+			}
+
+			value, ok := lookupPath(vars, append(prefix, strings.Split(path, ".")...))
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("Kapp var '%s' isn't set for %s", path,
+					description))
+			}
+
+			return value, nil
+		},
+		// e.g. `stackVar "stack.region"`
+		"stackVar": func(path string) (interface{}, error) {
+			value, ok := lookupPath(vars, strings.Split(path, "."))
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("Stack var '%s' isn't set", path))
+			}
+
+			return value, nil
+		},
+	}
+}
+
+// Returns the path to the value a call to a lookup function refers to if all its arguments are
+// literal strings, or nil
+func lookupFunctionReference(command *parse.CommandNode) []string {
+	if len(command.Args) < 2 {
+		return nil
+	}
+
+	identifier, ok := command.Args[0].(*parse.IdentifierNode)
+	if !ok {
+		return nil
+	}
+
+	args := make([]string, 0, len(command.Args)-1)
+	for _, arg := range command.Args[1:] {
+		str, ok := arg.(*parse.StringNode)
+		if !ok {
+			return nil
+		}
+		args = append(args, str.Text)
+	}
+
+	switch {
+	case identifier.Ident == "output" && len(args) == 2:
+		return append([]string{constants.RegistryKeyOutputs, TemplateKey(args[0])},
+			strings.Split(args[1], ".")...)
+	case identifier.Ident == "kappVar" && len(args) == 1:
+		return append([]string{constants.KappVarsKappKey, constants.KappVarsVarsKey}, strings.Split(args[0], ".")...)
+	case identifier.Ident == "kappVar" && len(args) == 2:
+		return append([]string{constants.KappVarsKappsKey, TemplateKey(args[0]), constants.KappVarsVarsKey},
+			strings.Split(args[1], ".")...)
+	case identifier.Ident == "stackVar" && len(args) == 1:
+		return strings.Split(args[0], ".")
+	}
+
+	return nil
+}
+
+// Returns the key a kapp can be referred to by in templates. The namespace separator in
+// fully-qualified IDs is replaced and hyphens are replaced by underscores because Go's templating
+// library doesn't allow them in map keys.
+func TemplateKey(kappId string) string {
+	key := strings.Replace(kappId, constants.NamespaceSeparator, constants.TemplateNamespaceSeparator, -1)
+	return strings.Replace(key, "-", "_", -1)
+}
@@ -0,0 +1,652 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package templater
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/utils"
+	"gopkg.in/yaml.v2"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// functions that are commonly used to handle missing values. References passed to them (or used
+// in `if` and `with` conditions) aren't reported as undefined.
+var guardFunctions = []string{"default", "empty", "coalesce", "isSet", "hasKey", "ternary"}
+
+// A string value in the vars that needs templating
+type templatedValue struct {
+	path       []interface{} // map keys and list indices leading to the value
+	name       string        // the path formatted for messages
+	template   *template.Template
+	references [][]string // paths to the other values the template refers to
+	unguarded  [][]string // references that aren't guarded by conditions or functions like `default`
+	self       bool       // whether the template refers to its own value
+	dependsOn  []string   // names of other templated values that must be rendered first
+	undefined  []string   // references to values that don't exist
+}
+
+// Resolves templates in vars. References to other values are parsed out of each template to build
+// a dependency graph so each value is rendered once, after the values it refers to. This allows
+// defining intermediate variables or aliases (e.g. set `cluster_name` to
+// '{{ .stack.region }}-{{ .stack.account }}' then use '{{ .kapp.vars.cluster_name }}').
+//
+// An error is returned if values refer to each other in a cycle. A value that refers to itself
+// (e.g. `release: '{{ .kapp.vars.release | default .kapp.id }}'`) sees itself as unset. References
+// to values that don't exist are logged, or in strict mode returned as an error.
+//
+// Rendered values are strings and nested maps are returned as map[interface{}]interface{}, i.e. the
+// same types as if the vars had been loaded from YAML.
+func ResolveVars(vars map[string]interface{}) (map[string]interface{}, error) {
+	log.Logger.Tracef("Resolving templates in variables: %+v", vars)
+
+	// convert the vars to the same types as when loading YAML and so we don't mutate the input
+	yamlData, err := yaml.Marshal(&vars)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	var resolved map[string]interface{}
+	err = yaml.Unmarshal(yamlData, &resolved)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if resolved == nil {
+		resolved = map[string]interface{}{}
+	}
+
+	values, err := findTemplatedValues(resolved)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	ordered, err := sortTemplatedValues(resolved, values)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	err = checkUndefined(resolved, ordered)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	for _, value := range ordered {
+		rendered, err := value.render(resolved)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		setPath(resolved, value.path, rendered)
+	}
+
+	log.Logger.Tracef("Resolved variables as: %#v", resolved)
+
+	return resolved, nil
+}
+
+// Logs references to undefined values, or in strict mode returns an error listing each value
+// that has them along with variables with similar names that they may have meant
+func checkUndefined(vars map[string]interface{}, values []*templatedValue) error {
+	messages := make([]string, 0)
+
+	for _, value := range values {
+		if len(value.undefined) == 0 {
+			continue
+		}
+
+		if !isStrict() {
+			log.Logger.Warnf("Variable '%s' refers to undefined variables: %s", value.name,
+				strings.Join(value.undefined, ", "))
+			continue
+		}
+
+		references := make([]string, 0, len(value.undefined))
+		for _, reference := range value.undefined {
+			suggestion := suggestPath(vars, strings.Split(strings.TrimPrefix(reference, "."), "."))
+			if suggestion != "" {
+				reference = fmt.Sprintf("%s (did you mean '%s'?)", reference, suggestion)
+			}
+			references = append(references, reference)
+		}
+
+		messages = append(messages, fmt.Sprintf("'%s' refers to %s", value.name,
+			strings.Join(references, ", ")))
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	sort.Strings(messages)
+
+	return errors.New(fmt.Sprintf("Undefined variables: %s", strings.Join(messages, "; ")))
+}
+
+// Returns the path to a defined variable similar to an undefined one, or an empty string
+func suggestPath(vars map[string]interface{}, path []string) string {
+	for i, element := range path {
+		parent, ok := lookupPath(vars, path[:i])
+		if !ok {
+			return ""
+		}
+
+		if _, ok := lookupPath(parent, []string{element}); ok {
+			continue
+		}
+
+		suggestion := closestKey(parent, element)
+		if suggestion == "" {
+			return ""
+		}
+
+		return "." + strings.Join(append(path[:i:i], suggestion), ".")
+	}
+
+	return ""
+}
+
+// Returns all string values that contain templates, keyed by their formatted paths
+func findTemplatedValues(vars map[string]interface{}) (map[string]*templatedValue, error) {
+	values := map[string]*templatedValue{}
+
+	var walk func(value interface{}, path []interface{}) error
+	walk = func(value interface{}, path []interface{}) error {
+		switch typed := value.(type) {
+		case map[string]interface{}:
+			for key, child := range typed {
+				err := walk(child, appendPath(path, key))
+				if err != nil {
+					return err
+				}
+			}
+		case map[interface{}]interface{}:
+			for key, child := range typed {
+				err := walk(child, appendPath(path, key))
+				if err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			for i, child := range typed {
+				err := walk(child, appendPath(path, i))
+				if err != nil {
+					return err
+				}
+			}
+		case string:
+			if !strings.Contains(typed, "{{") {
+				return nil
+			}
+
+			name := formatPath(path)
+			tpl, err := newTemplate(name).Parse(typed)
+			if err != nil {
+				return errors.Wrapf(err, "Error parsing the template in variable '%s'", name)
+			}
+
+			values[name] = &templatedValue{
+				path:       path,
+				name:       name,
+				template:   tpl,
+				references: findReferences(tpl.Tree.Root),
+				unguarded:  findUnguardedReferences(tpl.Tree.Root),
+			}
+		}
+
+		return nil
+	}
+
+	err := walk(vars, []interface{}{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return values, nil
+}
+
+// Works out which templated values each value depends on and returns them in the order they
+// should be rendered
+func sortTemplatedValues(vars map[string]interface{},
+	values map[string]*templatedValue) ([]*templatedValue, error) {
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := values[name]
+		valuePath := stringPath(value.path)
+
+		for _, reference := range value.references {
+			if isPrefix(reference, valuePath) && len(reference) == len(valuePath) {
+				value.self = true
+				continue
+			}
+
+			if _, ok := lookupPath(vars, reference); !ok {
+				formatted := "." + strings.Join(reference, ".")
+				if containsPath(value.unguarded, reference) && !utils.InStringArray(value.undefined, formatted) {
+					value.undefined = append(value.undefined, formatted)
+				}
+				continue
+			}
+
+			// depend on all templated values nested under the reference, or that the reference
+			// is nested under
+			for _, otherName := range names {
+				if otherName == name {
+					continue
+				}
+
+				otherPath := stringPath(values[otherName].path)
+				if (isPrefix(reference, otherPath) || isPrefix(otherPath, reference)) &&
+					!utils.InStringArray(value.dependsOn, otherName) {
+					value.dependsOn = append(value.dependsOn, otherName)
+				}
+			}
+		}
+	}
+
+	ordered := make([]*templatedValue, 0, len(values))
+	done := map[string]bool{}
+
+	// repeatedly take all values whose dependencies have been rendered. Values are sorted by name
+	// so the order is deterministic.
+	for len(ordered) < len(values) {
+		progressed := false
+
+		for _, name := range names {
+			if done[name] {
+				continue
+			}
+
+			ready := true
+			for _, dependency := range values[name].dependsOn {
+				if !done[dependency] {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				ordered = append(ordered, values[name])
+				done[name] = true
+				progressed = true
+			}
+		}
+
+		if !progressed {
+			return nil, errors.New(fmt.Sprintf("Circular references between variables: %s",
+				strings.Join(findCycle(values, done), " -> ")))
+		}
+	}
+
+	return ordered, nil
+}
+
+// Returns a cycle among the values that haven't been rendered, with the first value repeated at the end
+func findCycle(values map[string]*templatedValue, done map[string]bool) []string {
+	names := make([]string, 0)
+	for name := range values {
+		if !done[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	// every remaining value depends on another remaining value so following the first such
+	// dependency must eventually revisit a value
+	visited := map[string]int{}
+	path := make([]string, 0)
+	current := names[0]
+
+	for {
+		if index, ok := visited[current]; ok {
+			return append(path[index:], current)
+		}
+
+		visited[current] = len(path)
+		path = append(path, current)
+
+		for _, dependency := range values[current].dependsOn {
+			if !done[dependency] {
+				current = dependency
+				break
+			}
+		}
+	}
+}
+
+// Renders the value's template with the given vars
+func (v templatedValue) render(vars map[string]interface{}) (string, error) {
+	if v.self {
+		// hide the value from itself so it's treated as unset
+		original, _ := lookupPath(vars, stringPath(v.path))
+		deletePath(vars, v.path)
+		defer setPath(vars, v.path, original)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	err := v.template.Funcs(lookupFunctions(vars)).Execute(buf, vars)
+	if err != nil {
+		if missingKeyErr, ok := toMissingKeyError(err, vars); ok {
+			return "", errors.Wrapf(missingKeyErr, "Error templating variable '%s'", v.name)
+		}
+		return "", errors.Wrapf(err, "Error templating variable '%s'", v.name)
+	}
+
+	return buf.String(), nil
+}
+
+// Returns the paths of all values referred to in a parsed template. Fields in `range` and `with` blocks
+// are ignored because they're relative to the value being ranged over, which is referred to itself.
+func findReferences(root parse.Node) [][]string {
+	references := make([][]string, 0)
+
+	var walk func(node parse.Node, dotIsRoot bool)
+	walk = func(node parse.Node, dotIsRoot bool) {
+		if node == nil {
+			return
+		}
+
+		switch typed := node.(type) {
+		case *parse.ListNode:
+			if typed == nil {
+				return
+			}
+			for _, child := range typed.Nodes {
+				walk(child, dotIsRoot)
+			}
+		case *parse.ActionNode:
+			walk(typed.Pipe, dotIsRoot)
+		case *parse.IfNode:
+			walk(typed.Pipe, dotIsRoot)
+			walk(typed.List, dotIsRoot)
+			walk(typed.ElseList, dotIsRoot)
+		case *parse.RangeNode:
+			walk(typed.Pipe, dotIsRoot)
+			walk(typed.List, false)
+			walk(typed.ElseList, dotIsRoot)
+		case *parse.WithNode:
+			walk(typed.Pipe, dotIsRoot)
+			walk(typed.List, false)
+			walk(typed.ElseList, dotIsRoot)
+		case *parse.TemplateNode:
+			walk(typed.Pipe, dotIsRoot)
+		case *parse.PipeNode:
+			if typed == nil {
+				return
+			}
+			for _, command := range typed.Cmds {
+				walk(command, dotIsRoot)
+			}
+		case *parse.ChainNode:
+			walk(typed.Node, dotIsRoot)
+		case *parse.CommandNode:
+			// lookup functions like `kappVar "a.b"` refer to values given by their arguments
+			if reference := lookupFunctionReference(typed); reference != nil {
+				references = append(references, reference)
+				return
+			}
+
+			// `index .a "b" "c"` refers to `.a.b.c`
+			if len(typed.Args) > 2 {
+				if identifier, ok := typed.Args[0].(*parse.IdentifierNode); ok && identifier.Ident == "index" {
+					base := referencePath(typed.Args[1], dotIsRoot)
+					if base != nil {
+						for _, arg := range typed.Args[2:] {
+							key, ok := arg.(*parse.StringNode)
+							if !ok {
+								break
+							}
+							base = append(base, key.Text)
+						}
+						references = append(references, base)
+
+						for _, arg := range typed.Args[2:] {
+							walk(arg, dotIsRoot)
+						}
+						return
+					}
+				}
+			}
+
+			for _, arg := range typed.Args {
+				walk(arg, dotIsRoot)
+			}
+		case *parse.FieldNode, *parse.VariableNode:
+			reference := referencePath(typed, dotIsRoot)
+			if reference != nil {
+				references = append(references, reference)
+			}
+		}
+	}
+
+	walk(root, true)
+
+	return references
+}
+
+// Returns references in a template that aren't guarded by functions or conditions that handle
+// missing values
+func findUnguardedReferences(root parse.Node) [][]string {
+	references := make([][]string, 0)
+
+	var walk func(node parse.Node, dotIsRoot bool)
+	walk = func(node parse.Node, dotIsRoot bool) {
+		switch typed := node.(type) {
+		case *parse.ListNode:
+			if typed == nil {
+				return
+			}
+			for _, child := range typed.Nodes {
+				walk(child, dotIsRoot)
+			}
+		case *parse.ActionNode:
+			if !isGuarded(typed.Pipe) {
+				references = append(references, findReferences(typed.Pipe)...)
+			}
+		case *parse.IfNode:
+			walk(typed.List, dotIsRoot)
+			walk(typed.ElseList, dotIsRoot)
+		case *parse.WithNode:
+			walk(typed.ElseList, dotIsRoot)
+		case *parse.RangeNode:
+			walk(typed.ElseList, dotIsRoot)
+		}
+	}
+
+	walk(root, true)
+
+	return references
+}
+
+// Returns whether a pipeline uses any functions that handle missing values
+func isGuarded(pipe *parse.PipeNode) bool {
+	if pipe == nil {
+		return false
+	}
+
+	for _, command := range pipe.Cmds {
+		for _, arg := range command.Args {
+			if identifier, ok := arg.(*parse.IdentifierNode); ok && utils.InStringArray(guardFunctions, identifier.Ident) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Returns the path referred to by a field (e.g. `.kapp.vars.name`) or a variable rooted at `$`
+// (e.g. `$.kapp.vars.name`), or nil
+func referencePath(node parse.Node, dotIsRoot bool) []string {
+	switch typed := node.(type) {
+	case *parse.FieldNode:
+		if dotIsRoot {
+			return append([]string{}, typed.Ident...)
+		}
+	case *parse.VariableNode:
+		if len(typed.Ident) > 1 && typed.Ident[0] == "$" {
+			return append([]string{}, typed.Ident[1:]...)
+		}
+	}
+
+	return nil
+}
+
+func appendPath(path []interface{}, element interface{}) []interface{} {
+	return append(append(make([]interface{}, 0, len(path)+1), path...), element)
+}
+
+// Formats a path for messages, e.g. `kapp.vars.hosts[0]`
+func formatPath(path []interface{}) string {
+	var builder strings.Builder
+
+	for _, element := range path {
+		if index, ok := element.(int); ok {
+			builder.WriteString(fmt.Sprintf("[%d]", index))
+			continue
+		}
+
+		if builder.Len() > 0 {
+			builder.WriteString(".")
+		}
+		builder.WriteString(fmt.Sprintf("%v", element))
+	}
+
+	return builder.String()
+}
+
+// Converts a path to strings so it can be compared to references in templates
+func stringPath(path []interface{}) []string {
+	elements := make([]string, len(path))
+	for i, element := range path {
+		elements[i] = fmt.Sprintf("%v", element)
+	}
+	return elements
+}
+
+// Returns whether `prefix` is a prefix of (or equal to) `path`
+func isPrefix(prefix []string, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+
+	for i, element := range prefix {
+		if path[i] != element {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the value at a path of string keys
+func lookupPath(data interface{}, path []string) (interface{}, bool) {
+	current := data
+
+	for _, element := range path {
+		switch typed := current.(type) {
+		case map[string]interface{}:
+			value, ok := typed[element]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case map[interface{}]interface{}:
+			found := false
+			for key, value := range typed {
+				if fmt.Sprintf("%v", key) == element {
+					current = value
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// Returns the container holding the value at the path along with the final path element
+func parentOf(data map[string]interface{}, path []interface{}) (interface{}, interface{}) {
+	var current interface{} = data
+
+	for _, element := range path[:len(path)-1] {
+		switch typed := current.(type) {
+		case map[string]interface{}:
+			current = typed[fmt.Sprintf("%v", element)]
+		case map[interface{}]interface{}:
+			current = typed[element]
+		case []interface{}:
+			current = typed[element.(int)]
+		}
+	}
+
+	return current, path[len(path)-1]
+}
+
+// Sets the value at a path found by `findTemplatedValues`
+func setPath(data map[string]interface{}, path []interface{}, value interface{}) {
+	parent, last := parentOf(data, path)
+
+	switch typed := parent.(type) {
+	case map[string]interface{}:
+		typed[fmt.Sprintf("%v", last)] = value
+	case map[interface{}]interface{}:
+		typed[last] = value
+	case []interface{}:
+		typed[last.(int)] = value
+	}
+}
+
+// Deletes the value at a path found by `findTemplatedValues`. List elements are set to nil.
+func deletePath(data map[string]interface{}, path []interface{}) {
+	parent, last := parentOf(data, path)
+
+	switch typed := parent.(type) {
+	case map[string]interface{}:
+		delete(typed, fmt.Sprintf("%v", last))
+	case map[interface{}]interface{}:
+		delete(typed, last)
+	case []interface{}:
+		typed[last.(int)] = nil
+	}
+}
+
+func containsPath(paths [][]string, path []string) bool {
+	for _, candidate := range paths {
+		if len(candidate) == len(path) && isPrefix(candidate, path) {
+			return true
+		}
+	}
+	return false
+}
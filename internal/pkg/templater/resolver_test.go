@@ -0,0 +1,119 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package templater
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestResolveVars(t *testing.T) {
+	input := map[string]interface{}{
+		"stack": map[string]interface{}{
+			"name":   "dev",
+			"region": "eu-west-1",
+		},
+		"kapp": map[string]interface{}{
+			"id": "web",
+			"vars": map[string]interface{}{
+				// refers to a value that itself needs templating
+				"greeting":     "hello {{ .kapp.vars.cluster_name }}",
+				"cluster_name": "{{ .stack.name }}-{{ .stack.region }}",
+				"hosts": []interface{}{
+					"{{ .kapp.vars.cluster_name }}.example.com",
+					"static.example.com",
+				},
+				"first_host": `{{ index .kapp.vars.hosts 0 }}`,
+				"by_index":   `{{ index .kapp "vars" "cluster_name" }}`,
+				"release":    "{{ .kapp.vars.release | default .kapp.id }}",
+				"replicas":   3,
+				"count":      "{{ .kapp.vars.replicas }}",
+				// rendered values aren't templated again
+				"literal": `{{ "{{ not a template }}" }}`,
+				"copy":    "{{ .kapp.vars.literal }}",
+				"missing": "{{ .kapp.vars.undefined }}",
+				// lookup functions refer to the values named by their arguments
+				"looked_up": `{{ kappVar "cluster_name" }}`,
+			},
+		},
+	}
+
+	resolved, err := ResolveVars(input)
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[interface{}]interface{}{
+		"greeting":     "hello dev-eu-west-1",
+		"cluster_name": "dev-eu-west-1",
+		"hosts": []interface{}{
+			"dev-eu-west-1.example.com",
+			"static.example.com",
+		},
+		"first_host": "dev-eu-west-1.example.com",
+		"by_index":   "dev-eu-west-1",
+		"release":    "web",
+		"replicas":   3,
+		"count":      "3",
+		"literal":    "{{ not a template }}",
+		"copy":       "{{ not a template }}",
+		"missing":    "<no value>",
+		"looked_up":  "dev-eu-west-1",
+	}, resolved["kapp"].(map[interface{}]interface{})["vars"])
+
+	// the input isn't mutated
+	assert.Equal(t, "{{ .stack.name }}-{{ .stack.region }}",
+		input["kapp"].(map[string]interface{})["vars"].(map[string]interface{})["cluster_name"])
+}
+
+func TestResolveVarsErrors(t *testing.T) {
+	_, err := ResolveVars(map[string]interface{}{
+		"a": "{{ .b }}",
+		"b": map[string]interface{}{
+			"c": "{{ .d }}",
+		},
+		"d": "{{ .a }}",
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Circular references between variables: a -> b.c -> d -> a")
+
+	_, err = ResolveVars(map[string]interface{}{
+		"list": []interface{}{"{{ .broken "},
+	})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "list[0]")
+}
+
+// In strict mode all references to undefined variables are reported at once
+func TestResolveVarsUndefinedStrict(t *testing.T) {
+	input := map[string]interface{}{
+		"a": "{{ .missing1 }}",
+		"b": map[string]interface{}{
+			"c": "{{ .missing2 }}-{{ .missing3.x }}",
+		},
+		// guarded references are fine
+		"d": `{{ .missing4 | default "x" }}`,
+	}
+
+	_, err := ResolveVars(input)
+	assert.Nil(t, err)
+
+	defer enableStrictMode()()
+
+	_, err = ResolveVars(input)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "'a' refers to .missing1; 'b.c' refers to .missing2, .missing3.x")
+	assert.NotContains(t, err.Error(), "missing4")
+}
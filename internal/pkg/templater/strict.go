@@ -0,0 +1,293 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package templater
+
+import (
+	"fmt"
+	"github.com/Masterminds/sprig"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Matches the error text/template returns when a template refers to a key that doesn't exist
+var missingKeyPattern = regexp.MustCompile(
+	`:(\d+):\d+: executing "[^"]*" at <([^>]*)>: map has no entry for key "([^"]*)"`)
+
+// Matches YAML lines that start with a key
+var yamlKeyPattern = regexp.MustCompile(`^("[^"]*"|'[^']*'|[^\s#'"][^:]*):(\s|$)`)
+
+// Returned in strict mode when a template refers to a variable that isn't defined
+type MissingKeyError struct {
+	Line       int    // the line of the template the variable was referred to on
+	Path       string // the full path to the missing variable, e.g. `.kapp.vars.region`
+	Suggestion string // the path to a defined variable with a similar name, if there is one
+}
+
+func (e MissingKeyError) Error() string {
+	message := fmt.Sprintf("Undefined variable '%s' on line %d", e.Path, e.Line)
+	if e.Suggestion != "" {
+		message = fmt.Sprintf("%s (did you mean '%s'?)", message, e.Suggestion)
+	}
+
+	return message
+}
+
+// Returns whether templates should fail when they refer to undefined variables
+func isStrict() bool {
+	return config.CurrentConfig != nil && config.CurrentConfig.Strict
+}
+
+// Returns a new template with all our functions available. In strict mode executing the template
+// will fail if it refers to a variable that isn't defined.
+func newTemplate(name string) *template.Template {
+	// lookup functions are bound to the vars when the template is executed
+	tpl := template.New(name).Funcs(sprig.TxtFuncMap()).Funcs(CustomFunctions).Funcs(lookupFunctions(nil))
+	if isStrict() {
+		tpl = tpl.Option("missingkey=error")
+	}
+
+	return tpl
+}
+
+// Converts an error executing a template into a MissingKeyError if it was caused by an undefined
+// variable. The vars are searched for a similarly named variable to suggest instead.
+func toMissingKeyError(err error, vars map[string]interface{}) (MissingKeyError, bool) {
+	matches := missingKeyPattern.FindStringSubmatch(err.Error())
+	if matches == nil {
+		return MissingKeyError{}, false
+	}
+
+	line, _ := strconv.Atoi(matches[1])
+	reference := matches[2]
+	key := matches[3]
+
+	missing := MissingKeyError{
+		Line: line,
+		Path: reference,
+	}
+
+	// references inside `range` and `with` blocks are relative to the current item so they can't
+	// be resolved against the vars
+	if !strings.HasPrefix(reference, ".") && !strings.HasPrefix(reference, "$.") {
+		return missing, true
+	}
+
+	elements := strings.Split(strings.TrimPrefix(strings.TrimPrefix(reference, "$"), "."), ".")
+
+	for i, element := range elements {
+		if element != key {
+			continue
+		}
+
+		parent, ok := lookupPath(vars, elements[:i])
+		if !ok {
+			continue
+		}
+
+		if _, ok := lookupPath(parent, []string{key}); ok {
+			continue
+		}
+
+		missing.Path = "." + strings.Join(elements[:i+1], ".")
+
+		suggestion := closestKey(parent, key)
+		if suggestion != "" {
+			missing.Suggestion = "." + strings.Join(append(elements[:i:i], suggestion), ".")
+		}
+		break
+	}
+
+	return missing, true
+}
+
+// Returns the key in a map that's most similar to the given one, or an empty string if none are
+// similar enough to be worth suggesting
+func closestKey(data interface{}, key string) string {
+	value := reflect.ValueOf(data)
+	if !value.IsValid() || value.Kind() != reflect.Map {
+		return ""
+	}
+
+	candidates := make([]string, 0, value.Len())
+	for _, candidate := range value.MapKeys() {
+		candidates = append(candidates, fmt.Sprintf("%v", candidate.Interface()))
+	}
+	sort.Strings(candidates)
+
+	// allow roughly one typo for every three characters
+	maxDistance := int(math.Max(1, float64(len(key)/3)))
+
+	closest := ""
+	closestDistance := maxDistance + 1
+
+	for _, candidate := range candidates {
+		distance := editDistance(strings.ToLower(key), strings.ToLower(candidate))
+		if distance < closestDistance {
+			closest = candidate
+			closestDistance = distance
+		}
+	}
+
+	return closest
+}
+
+// Returns the number of single character insertions, deletions, substitutions and transpositions
+// of adjacent characters needed to turn one string into another
+func editDistance(a string, b string) int {
+	first := []rune(a)
+	second := []rune(b)
+
+	distances := make([][]int, len(first)+1)
+	for i := range distances {
+		distances[i] = make([]int, len(second)+1)
+		distances[i][0] = i
+	}
+	for j := range distances[0] {
+		distances[0][j] = j
+	}
+
+	for i := 1; i <= len(first); i++ {
+		for j := 1; j <= len(second); j++ {
+			cost := 1
+			if first[i-1] == second[j-1] {
+				cost = 0
+			}
+
+			distances[i][j] = minInt(distances[i-1][j]+1,
+				minInt(distances[i][j-1]+1, distances[i-1][j-1]+cost))
+
+			if i > 1 && j > 1 && first[i-1] == second[j-2] && first[i-2] == second[j-1] {
+				distances[i][j] = minInt(distances[i][j], distances[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return distances[len(first)][len(second)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// Returns the path to the field defined on the given line (starting from 1) of a YAML document,
+// e.g. `templates.config.dest` or `run_units.make.apply_install[0].args`. Returns an empty string
+// if the line doesn't exist.
+func FieldAtLine(yamlText string, line int) string {
+	lines := strings.Split(yamlText, "\n")
+	if line < 1 || line > len(lines) {
+		return ""
+	}
+
+	elements := make([]string, 0)
+	// the indentation of the outermost element found so far. Only less indented lines are parents.
+	level := math.MaxInt32
+
+	for i := line - 1; i >= 0; i-- {
+		indent, isItem, key, ok := parseYamlLine(lines[i])
+		if !ok {
+			continue
+		}
+
+		if !isItem {
+			if key != "" && indent < level {
+				elements = append([]string{key}, elements...)
+				level = indent
+			} else if i == line-1 {
+				// the line continues a multi-line value so belongs to the next key up
+				level = indent
+			}
+			continue
+		}
+
+		// keys of list items are indented past the dash
+		if key != "" && indent+2 < level {
+			elements = append([]string{key}, elements...)
+		}
+
+		if indent+1 < level {
+			elements = append([]string{fmt.Sprintf("[%d]", listIndex(lines, i, indent))}, elements...)
+			level = indent + 1
+		}
+	}
+
+	path := ""
+	for _, element := range elements {
+		if path != "" && !strings.HasPrefix(element, "[") {
+			path += "."
+		}
+		path += element
+	}
+
+	return path
+}
+
+// Returns the position of the list item on the given line in its list
+func listIndex(lines []string, itemLine int, itemIndent int) int {
+	index := 0
+
+	for i := itemLine - 1; i >= 0; i-- {
+		indent, isItem, key, ok := parseYamlLine(lines[i])
+		if !ok || indent > itemIndent {
+			continue
+		}
+
+		if isItem && indent == itemIndent {
+			index++
+			continue
+		}
+
+		if indent < itemIndent || key != "" {
+			break
+		}
+	}
+
+	return index
+}
+
+// Parses a line of YAML, returning its indentation, whether it starts a list item and the key
+// defined on it (if any). Returns false for lines that don't contain any data.
+func parseYamlLine(line string) (int, bool, string, bool) {
+	content := strings.TrimLeft(line, " ")
+	if strings.TrimSpace(content) == "" || strings.HasPrefix(content, "#") || content == "---" {
+		return 0, false, "", false
+	}
+
+	indent := len(line) - len(content)
+
+	isItem := content == "-" || strings.HasPrefix(content, "- ")
+	if isItem {
+		content = strings.TrimLeft(strings.TrimPrefix(content, "-"), " ")
+	}
+
+	key := ""
+	matches := yamlKeyPattern.FindStringSubmatch(content)
+	if matches != nil {
+		key = strings.Trim(matches[1], `"'`)
+	}
+
+	return indent, isItem, key, true
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package templater
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/sugarkube/sugarkube/internal/pkg/config"
+	"testing"
+)
+
+// Enables strict mode, returning a function that restores the previous config
+func enableStrictMode() func() {
+	previous := config.CurrentConfig
+	config.CurrentConfig = &config.Config{Strict: true}
+
+	return func() {
+		config.CurrentConfig = previous
+	}
+}
+
+func TestRenderTemplateStrict(t *testing.T) {
+	vars := map[string]interface{}{
+		"kapp": map[interface{}]interface{}{
+			"vars": map[interface{}]interface{}{
+				"region":   "eu-west-1",
+				"replicas": 3,
+			},
+		},
+	}
+
+	// without strict mode missing keys are rendered as a placeholder
+	result, err := RenderTemplate("{{ .kapp.vars.regoin }}", vars)
+	assert.Nil(t, err)
+	assert.Equal(t, "<no value>", result)
+
+	defer enableStrictMode()()
+
+	result, err = RenderTemplate("{{ .kapp.vars.region }}", vars)
+	assert.Nil(t, err)
+	assert.Equal(t, "eu-west-1", result)
+
+	_, err = RenderTemplate("first line\n{{ .kapp.vars.regoin | upper }}", vars)
+	assert.NotNil(t, err)
+	assert.Equal(t, MissingKeyError{
+		Line:       2,
+		Path:       ".kapp.vars.regoin",
+		Suggestion: ".kapp.vars.region",
+	}, errors.Cause(err))
+	assert.Equal(t, "Undefined variable '.kapp.vars.regoin' on line 2 "+
+		"(did you mean '.kapp.vars.region'?)", err.Error())
+
+	// the full path is reported even when a parent is missing
+	_, err = RenderTemplate("{{ $.kap.vars.region }}", vars)
+	assert.NotNil(t, err)
+	assert.Equal(t, MissingKeyError{
+		Line:       1,
+		Path:       ".kap",
+		Suggestion: ".kapp",
+	}, errors.Cause(err))
+
+	// nothing is suggested if no keys are similar
+	_, err = RenderTemplate("{{ .kapp.vars.zone }}", vars)
+	assert.NotNil(t, err)
+	assert.Equal(t, MissingKeyError{
+		Line: 1,
+		Path: ".kapp.vars.zone",
+	}, errors.Cause(err))
+
+	// optional values can still be looked up with `index`
+	result, err = RenderTemplate(`{{ index .kapp.vars "zone" | default "none" }}`, vars)
+	assert.Nil(t, err)
+	assert.Equal(t, "none", result)
+}
+
+func TestResolveVarsStrict(t *testing.T) {
+	defer enableStrictMode()()
+
+	_, err := ResolveVars(map[string]interface{}{
+		"name":     "world",
+		"greeting": "hello {{ .nmae }}",
+	})
+	assert.NotNil(t, err)
+	assert.Equal(t, "Undefined variables: 'greeting' refers to .nmae (did you mean '.name'?)",
+		err.Error())
+}
+
+func TestFieldAtLine(t *testing.T) {
+	input := `id: example
+templates:
+  config:
+    source: a.tpl
+    dest: "{{ .kapp.vars.dest }}"
+run_units:
+  make:
+    apply_install:
+    - name: first
+      command: make
+    - name: second
+      args:
+        target: install
+      command: |
+        make
+        {{ .kapp.vars.target }}
+vars:
+  list:
+  - a
+  - b`
+
+	tests := map[int]string{
+		1:  "id",
+		5:  "templates.config.dest",
+		9:  "run_units.make.apply_install[0].name",
+		10: "run_units.make.apply_install[0].command",
+		11: "run_units.make.apply_install[1].name",
+		13: "run_units.make.apply_install[1].args.target",
+		16: "run_units.make.apply_install[1].command",
+		20: "vars.list[1]",
+		21: "",
+	}
+
+	for line, expected := range tests {
+		assert.Equal(t, expected, FieldAtLine(input, line), "Unexpected field for line %d", line)
+	}
+}
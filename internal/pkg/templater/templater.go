@@ -18,23 +18,27 @@ package templater
 
 import (
 	"bytes"
-	"github.com/Masterminds/sprig"
 	"github.com/pkg/errors"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
 	"io/ioutil"
 	"os"
-	"text/template"
 )
 
-// Returns a template rendered with the given input variables
+// Returns a template rendered with the given input variables. In strict mode a MissingKeyError
+// is returned if the template refers to a variable that isn't defined.
 func RenderTemplate(inputTemplate string, vars map[string]interface{}) (string, error) {
-	tpl := template.Must(
-		template.New("gotpl").Funcs(
-			sprig.TxtFuncMap()).Funcs(CustomFunctions).Parse(inputTemplate))
+	tpl, err := newTemplate("gotpl").Parse(inputTemplate)
+	if err != nil {
+		return "", errors.Wrapf(err, "Error parsing template %s", inputTemplate)
+	}
 
 	buf := bytes.NewBuffer(nil)
-	err := tpl.Execute(buf, vars)
+	err = tpl.Funcs(lookupFunctions(vars)).Execute(buf, vars)
 	if err != nil {
+		if missingKeyErr, ok := toMissingKeyError(err, vars); ok {
+			return "", errors.WithStack(missingKeyErr)
+		}
+
 		return "", errors.Wrapf(err, "Error executing template %s with vars %#v", inputTemplate, vars)
 	}
 
@@ -54,7 +58,12 @@ func TemplateFile(src string, outBuf *bytes.Buffer, vars map[string]interface{})
 		return errors.Wrapf(err, "Error reading source template file %s", src)
 	}
 
-	return TemplateString(string(srcTemplate[:]), outBuf, vars)
+	err = TemplateString(string(srcTemplate[:]), outBuf, vars)
+	if err != nil {
+		return errors.Wrapf(err, "Error rendering template file '%s'", src)
+	}
+
+	return nil
 }
 
 // Renders a template into a buffer
@@ -135,3 +135,221 @@ func TestCustomFunctions(t *testing.T) {
 		assert.Equal(t, test.expected, output)
 	}
 }
+
+func TestLibraryFunctions(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "template-functions-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	filePath := filepath.Join(tempDir, "file.txt")
+	err = ioutil.WriteFile(filePath, []byte("file contents"), 0644)
+	assert.Nil(t, err)
+
+	err = os.Setenv("SUGARKUBE_TEST_TEMPLATE_ENV", "from-env")
+	assert.Nil(t, err)
+	defer os.Unsetenv("SUGARKUBE_TEST_TEMPLATE_ENV")
+
+	vars := map[string]interface{}{
+		"file": filePath,
+		"kapp": map[interface{}]interface{}{
+			"vars": map[interface{}]interface{}{
+				"name":  "web",
+				"empty": "",
+				"labels": map[interface{}]interface{}{
+					"app":  "web",
+					"tier": "frontend",
+				},
+				"overrides": map[interface{}]interface{}{
+					"tier": "backend",
+					"team": "platform",
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			name:     "default",
+			input:    `{{ .kapp.vars.empty | default "fallback" }}`,
+			expected: "fallback",
+		},
+		{
+			name:     "required",
+			input:    `{{ required "name is required" .kapp.vars.name }}`,
+			expected: "web",
+		},
+		{
+			name:     "toYaml",
+			input:    `{{ .kapp.vars.labels | toYaml }}`,
+			expected: "app: web\ntier: frontend",
+		},
+		{
+			name:     "fromYaml",
+			input:    `{{ $parsed := fromYaml "a:\n  b: c" }}{{ $parsed.a.b }}`,
+			expected: "c",
+		},
+		{
+			name:     "toJson",
+			input:    `{{ .kapp.vars.labels | toJson }}`,
+			expected: `{"app":"web","tier":"frontend"}`,
+		},
+		{
+			name:     "fromJson",
+			input:    `{{ $parsed := fromJson "{\"a\": {\"b\": 1}}" }}{{ $parsed.a.b }}`,
+			expected: "1",
+		},
+		{
+			name:     "b64enc",
+			input:    `{{ .kapp.vars.name | b64enc }}`,
+			expected: "d2Vi",
+		},
+		{
+			name:     "sha256sum",
+			input:    `{{ .kapp.vars.name | sha256sum }}`,
+			expected: "4b5e57f6eb2f42b9039b3d1e13929295f231749c510cbe341cd68036d9af97e2",
+		},
+		{
+			name:     "indent",
+			input:    `{{ .kapp.vars.labels | toYaml | indent 2 }}`,
+			expected: "  app: web\n  tier: frontend",
+		},
+		{
+			name:     "dict",
+			input:    `{{ dict "a" 1 "b" "two" | toJson }}`,
+			expected: `{"a":1,"b":"two"}`,
+		},
+		{
+			name:     "merge",
+			input:    `{{ merge .kapp.vars.labels .kapp.vars.overrides | toJson }}`,
+			expected: `{"app":"web","team":"platform","tier":"frontend"}`,
+		},
+		{
+			name:     "semverCompare",
+			input:    `{{ semverCompare ">=1.2.0" "1.10.3" }}`,
+			expected: "true",
+		},
+		{
+			name:     "env",
+			input:    `{{ env "SUGARKUBE_TEST_TEMPLATE_ENV" }}`,
+			expected: "from-env",
+		},
+		{
+			name:     "readFile",
+			input:    `{{ readFile .file }}`,
+			expected: "file contents",
+		},
+		{
+			name:     "secret",
+			input:    `{{ secret "env://SUGARKUBE_TEST_TEMPLATE_ENV" }}`,
+			expected: "from-env",
+		},
+	}
+
+	for _, test := range tests {
+		output, err := RenderTemplate(test.input, vars)
+		assert.Nil(t, err, "Error rendering template for %s", test.name)
+		assert.Equal(t, test.expected, output, "Unexpected output for %s", test.name)
+	}
+
+	_, err = RenderTemplate(`{{ required "zone is required" .kapp.vars.empty }}`, vars)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "zone is required")
+
+	_, err = RenderTemplate(`{{ readFile "/does/not/exist" }}`, vars)
+	assert.NotNil(t, err)
+}
+
+func TestLookupFunctions(t *testing.T) {
+	vars := map[string]interface{}{
+		"stack": map[interface{}]interface{}{
+			"region": "eu-west-1",
+		},
+		"kapp": map[interface{}]interface{}{
+			"vars": map[interface{}]interface{}{
+				"db": map[interface{}]interface{}{
+					"port": 5432,
+				},
+			},
+		},
+		"kapps": map[interface{}]interface{}{
+			"data__postgres_db": map[interface{}]interface{}{
+				"vars": map[interface{}]interface{}{
+					"version": "11",
+				},
+			},
+		},
+		"outputs": map[interface{}]interface{}{
+			"data__postgres_db": map[interface{}]interface{}{
+				"tf_output": map[interface{}]interface{}{
+					"endpoint": "db.example.com",
+				},
+			},
+		},
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    `{{ output "data:postgres-db" "tf-output.endpoint" }}`,
+			expected: "db.example.com",
+		},
+		{
+			input:    `{{ kappVar "db.port" }}`,
+			expected: "5432",
+		},
+		{
+			input:    `{{ kappVar "data:postgres-db" "version" }}`,
+			expected: "11",
+		},
+		{
+			input:    `{{ stackVar "stack.region" }}`,
+			expected: "eu-west-1",
+		},
+	}
+
+	for _, test := range tests {
+		output, err := RenderTemplate(test.input, vars)
+		assert.Nil(t, err, "Error rendering %s", test.input)
+		assert.Equal(t, test.expected, output)
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			input:    `{{ output "data:postgres-db" "tf_output.missing" }}`,
+			expected: "No output 'tf_output.missing' found for kapp 'data:postgres-db'",
+		},
+		{
+			input:    `{{ output "data:redis" "endpoint" }}`,
+			expected: "No outputs found for kapp 'data:redis'",
+		},
+		{
+			input:    `{{ kappVar "db.host" }}`,
+			expected: "Kapp var 'db.host' isn't set for this kapp",
+		},
+		{
+			input:    `{{ kappVar "data:postgres-db" "missing" }}`,
+			expected: "Kapp var 'missing' isn't set for kapp 'data:postgres-db'",
+		},
+		{
+			input:    `{{ stackVar "stack.zone" }}`,
+			expected: "Stack var 'stack.zone' isn't set",
+		},
+	}
+
+	for _, test := range errorTests {
+		_, err := RenderTemplate(test.input, vars)
+		assert.NotNil(t, err, "Expected an error rendering %s", test.input)
+		if err != nil {
+			assert.Contains(t, err.Error(), test.expected)
+		}
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
 	"io"
 	"os"
 	"os/exec"
@@ -50,6 +51,8 @@ func ExecCommand(command string, args []string, envVars map[string]string,
 	// sort the env vars to simplify copying and pasting log output
 	sort.Strings(strEnvVars)
 
+	addSensitiveEnvVars(envVars, os.Environ())
+
 	log.Logger.Infof("Command '%s' has args: %#v and explicit env vars: %#v", command, args, strEnvVars)
 
 	completeEnvVars := append(os.Environ(), strEnvVars...)
@@ -148,6 +151,8 @@ func ExecCommandUnbuffered(command string, args []string, envVars map[string]str
 	// sort the env vars to simplify copying and pasting log output
 	sort.Strings(strEnvVars)
 
+	addSensitiveEnvVars(envVars, os.Environ())
+
 	log.Logger.Infof("Command '%s' has args: %#v and explicit env vars: %#v", command, args, strEnvVars)
 
 	completeEnvVars := append(os.Environ(), strEnvVars...)
@@ -226,3 +231,16 @@ func ExecCommandUnbuffered(command string, args []string, envVars map[string]str
 
 	return nil
 }
+
+// Masks the values of explicit and inherited env vars whose names match the sensitive key
+// patterns so they aren't logged
+func addSensitiveEnvVars(envVars map[string]string, environ []string) {
+	redact.AddMatchingValues(envVars)
+
+	for _, envVar := range environ {
+		parts := strings.SplitN(envVar, "=", 2)
+		if len(parts) == 2 && redact.IsSensitiveKey(parts[0]) {
+			redact.AddValue(parts[1])
+		}
+	}
+}
@@ -49,6 +49,33 @@ func DeepCopy(in interface{}, out interface{}) error {
 	return nil
 }
 
+// Copies nested maps and lists, keeping the types of their keys and values (unlike DeepCopy). Other
+// values are returned as-is.
+func CopyNested(data interface{}) interface{} {
+	switch typed := data.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(typed))
+		for k, v := range typed {
+			result[k] = CopyNested(v)
+		}
+		return result
+	case map[interface{}]interface{}:
+		result := make(map[interface{}]interface{}, len(typed))
+		for k, v := range typed {
+			result[k] = CopyNested(v)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(typed))
+		for i, v := range typed {
+			result[i] = CopyNested(v)
+		}
+		return result
+	default:
+		return data
+	}
+}
+
 // Returns true if all conditions are true. Conditions must be parseable as booleans.
 func All(conditions []string) (bool, error) {
 	var boolCondition bool
@@ -0,0 +1,148 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vars
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/constants"
+	"github.com/sugarkube/sugarkube/internal/pkg/program"
+	"gopkg.in/yaml.v2"
+	"sort"
+	"strings"
+)
+
+// Prefixes of the names of env vars that set vars. They follow the program's env var prefix.
+const (
+	KappVarEnvPrefix  = "VAR_"
+	StackVarEnvPrefix = "STACKVAR_"
+)
+
+// A var set by an environment variable
+type EnvOverride struct {
+	Name  string      // the name of the environment variable
+	Kapp  string      // for kapp vars, the manifest and kapp IDs as given in the name, e.g. `WEB__APP`
+	Path  []string    // the path to the var
+	Value interface{} // the value parsed as YAML
+}
+
+// Parses kapp and stack vars set by environment variables. Kapp vars are set by variables named
+// '<prefix>_VAR_<MANIFEST>__<KAPP>__<PATH>' and stack vars by '<prefix>_STACKVAR_<PATH>', where
+// elements of paths are also separated by double underscores. Paths that are entirely upper case
+// are lower-cased, so `SUGARKUBE_VAR_WEB__APP__IMAGE__TAG` sets `image.tag` for the kapp
+// `web:app`. Values are parsed as YAML. `environ` should be formatted like `os.Environ()`.
+func ParseEnvOverrides(prefix string, environ []string) (kappOverrides []EnvOverride,
+	stackOverrides []EnvOverride, err error) {
+	kappPrefix := prefix + "_" + KappVarEnvPrefix
+	stackPrefix := prefix + "_" + StackVarEnvPrefix
+
+	sorted := append([]string{}, environ...)
+	// sort so shallower paths are set before deeper ones
+	sort.Strings(sorted)
+
+	for _, entry := range sorted {
+		keyValue := strings.SplitN(entry, "=", 2)
+		if len(keyValue) != 2 {
+			continue
+		}
+
+		name := keyValue[0]
+
+		switch {
+		case strings.HasPrefix(name, kappPrefix):
+			elements := strings.Split(strings.TrimPrefix(name, kappPrefix), constants.TemplateNamespaceSeparator)
+			if len(elements) < 3 {
+				return nil, nil, program.SimpleError{Message: fmt.Sprintf("Invalid environment "+
+					"variable '%s'. It should be named '%s<MANIFEST>%s<KAPP>%s<PATH>'", name,
+					kappPrefix, constants.TemplateNamespaceSeparator, constants.TemplateNamespaceSeparator)}
+			}
+
+			override, err := newEnvOverride(name, elements[2:], keyValue[1])
+			if err != nil {
+				return nil, nil, errors.WithStack(err)
+			}
+			override.Kapp = strings.Join(elements[:2], constants.TemplateNamespaceSeparator)
+
+			kappOverrides = append(kappOverrides, override)
+		case strings.HasPrefix(name, stackPrefix):
+			elements := strings.Split(strings.TrimPrefix(name, stackPrefix), constants.TemplateNamespaceSeparator)
+
+			override, err := newEnvOverride(name, elements, keyValue[1])
+			if err != nil {
+				return nil, nil, errors.WithStack(err)
+			}
+
+			stackOverrides = append(stackOverrides, override)
+		}
+	}
+
+	return kappOverrides, stackOverrides, nil
+}
+
+// Returns an override for the var at the given path parsed from an environment variable
+func newEnvOverride(name string, path []string, rawValue string) (EnvOverride, error) {
+	for _, element := range path {
+		if element == "" {
+			return EnvOverride{}, program.SimpleError{Message: fmt.Sprintf("Invalid path in "+
+				"environment variable '%s'", name)}
+		}
+	}
+
+	joined := strings.Join(path, constants.TemplateNamespaceSeparator)
+	if joined == strings.ToUpper(joined) {
+		for i, element := range path {
+			path[i] = strings.ToLower(element)
+		}
+	}
+
+	var value interface{}
+	err := yaml.Unmarshal([]byte(rawValue), &value)
+	if err != nil {
+		return EnvOverride{}, program.SimpleError{Message: fmt.Sprintf("Error parsing the value "+
+			"of environment variable '%s' as YAML: %v", name, err)}
+	}
+
+	return EnvOverride{
+		Name:  name,
+		Path:  path,
+		Value: value,
+	}, nil
+}
+
+// Returns a map of vars set by the overrides. Later overrides take precedence.
+func EnvOverridesToMap(overrides []EnvOverride) (map[string]interface{}, error) {
+	data := map[interface{}]interface{}{}
+
+	for _, override := range overrides {
+		SetNested(data, override.Path, override.Value)
+	}
+
+	result, err := Normalise(data)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return result, nil
+}
+
+// Records that values were set by environment variables
+func (p Provenance) RecordEnvOverrides(prefix []string, overrides []EnvOverride) {
+	for _, override := range overrides {
+		p.Record(append(append([]string{}, prefix...), override.Path...), override.Value,
+			SourceEnvOverride, override.Name)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vars
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseEnvOverrides(t *testing.T) {
+	kappOverrides, stackOverrides, err := ParseEnvOverrides("SUGARKUBE", []string{
+		"HOME=/root",
+		"SUGARKUBE_LOG_LEVEL=debug",
+		"SUGARKUBE_VAR_WEB__KAPP_A__IMAGE__TAG=1.1",
+		"SUGARKUBE_VAR_WEB__KAPP_A__hosts=[a.com, b.com]",
+		"SUGARKUBE_STACKVAR_REGION=eu-west-1",
+		"SUGARKUBE_STACKVAR_kops__nodeCount=3",
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, []EnvOverride{
+		{
+			Name:  "SUGARKUBE_VAR_WEB__KAPP_A__IMAGE__TAG",
+			Kapp:  "WEB__KAPP_A",
+			Path:  []string{"image", "tag"},
+			Value: 1.1,
+		},
+		{
+			Name:  "SUGARKUBE_VAR_WEB__KAPP_A__hosts",
+			Kapp:  "WEB__KAPP_A",
+			Path:  []string{"hosts"},
+			Value: []interface{}{"a.com", "b.com"},
+		},
+	}, kappOverrides)
+
+	assert.Equal(t, []EnvOverride{
+		{
+			Name:  "SUGARKUBE_STACKVAR_REGION",
+			Path:  []string{"region"},
+			Value: "eu-west-1",
+		},
+		{
+			Name:  "SUGARKUBE_STACKVAR_kops__nodeCount",
+			Path:  []string{"kops", "nodeCount"},
+			Value: 3,
+		},
+	}, stackOverrides)
+
+	stackVars, err := EnvOverridesToMap(stackOverrides)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"region": "eu-west-1",
+		"kops":   map[interface{}]interface{}{"nodeCount": 3},
+	}, stackVars)
+}
+
+func TestParseEnvOverridesErrors(t *testing.T) {
+	tests := []string{
+		"SUGARKUBE_VAR_WEB__KAPP_A=1",          // no path
+		"SUGARKUBE_VAR_WEB__KAPP_A__IMAGE__=1", // empty path element
+		"SUGARKUBE_STACKVAR_HOSTS=[a, b",       // invalid YAML
+	}
+
+	for _, test := range tests {
+		_, _, err := ParseEnvOverrides("SUGARKUBE", []string{test})
+		assert.Error(t, err, "expected an error for '%s'", test)
+	}
+}
@@ -18,29 +18,29 @@ package vars
 
 import (
 	"github.com/imdario/mergo"
+	"github.com/pelletier/go-toml"
 	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/convert"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
+	"github.com/sugarkube/sugarkube/internal/pkg/utils"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
+	"path/filepath"
+	"strings"
 )
 
-// Merges YAML files from multiple paths, with data from files loaded later
+// Extensions of files vars can be loaded from
+var FileExtensions = []string{".yaml", ".yml", ".json", ".toml"}
+
+// Merges vars files from multiple paths, with data from files loaded later
 // overriding values loaded earlier.
 func MergePaths(result *map[string]interface{}, paths ...string) error {
 
 	for _, path := range paths {
-		log.Logger.Debug("Loading path ", path)
-
-		contents, err := ioutil.ReadFile(path)
+		yamlData, err := LoadFile(path)
 		if err != nil {
-			return errors.Wrapf(err, "Error reading file %s", path)
-		}
-
-		var yamlData = map[string]interface{}{}
-
-		err = yaml.Unmarshal(contents, yamlData)
-		if err != nil {
-			return errors.Wrapf(err, "Error parsing YAML: %s", path)
+			return errors.WithStack(err)
 		}
 
 		log.Logger.Tracef("Merging %v with %v", result, yamlData)
@@ -54,6 +54,77 @@ func MergePaths(result *map[string]interface{}, paths ...string) error {
 	return nil
 }
 
+// Loads a vars file. The format is determined by the file's extension (see `FileExtensions`). Files
+// encrypted with SOPS are decrypted in memory and their encrypted values are marked as sensitive.
+func LoadFile(path string) (map[string]interface{}, error) {
+	log.Logger.Debug("Loading path ", path)
+
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error reading file %s", path)
+	}
+
+	data, err := parseVars(path, contents)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	if !IsSopsEncrypted(data) {
+		redact.AddMatchingValues(data)
+		return data, nil
+	}
+
+	decrypted, err := decryptSopsFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	encrypted := data
+
+	data, err = parseVars(path, decrypted)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error parsing decrypted SOPS file")
+	}
+
+	// files can have unencrypted values (e.g. with `unencrypted_suffix` or `encrypted_regex`) which
+	// don't need masking
+	redact.AddValues(sopsEncryptedValues(encrypted, data))
+
+	return data, nil
+}
+
+// Parses the contents of a vars file in the format given by the extension of its path
+func parseVars(path string, contents []byte) (map[string]interface{}, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		var jsonData = map[string]interface{}{}
+
+		err := convert.UnmarshalJson(contents, &jsonData)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error parsing JSON: %s", path)
+		}
+
+		// convert values to the types they'd have if they'd been loaded from YAML
+		return Normalise(convert.JsonNumbers(jsonData))
+	case ".toml":
+		tree, err := toml.Load(string(contents))
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error parsing TOML: %s", path)
+		}
+
+		return Normalise(tree.ToMap())
+	}
+
+	var yamlData = map[string]interface{}{}
+
+	err := yaml.Unmarshal(contents, yamlData)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error parsing YAML: %s", path)
+	}
+
+	return yamlData, nil
+}
+
 // Merges all given fragments, with values from later fragments overriding values
 // from earlier ones.
 func MergeFragments(result *map[string]interface{}, fragments ...map[string]interface{}) error {
@@ -83,3 +154,41 @@ func Merge(dest interface{}, source interface{}) error {
 	}
 	return nil
 }
+
+// Returns whether vars can be loaded from the file at the given path
+func IsVarsFile(path string) bool {
+	extension := strings.ToLower(filepath.Ext(path))
+
+	for _, candidate := range FileExtensions {
+		if extension == candidate {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Logs a warning for each group of vars files in the same directory that have the same basename but
+// different extensions (e.g. 'values.yaml' and 'values.json'). All of them are loaded, with later
+// files overriding earlier ones, which probably isn't what was intended.
+func WarnAboutConflicts(paths []string) {
+	byBasename := make(map[string][]string, 0)
+	basenames := make([]string, 0)
+
+	for _, path := range paths {
+		basename := utils.StripExtension(path)
+		if _, ok := byBasename[basename]; !ok {
+			basenames = append(basenames, basename)
+		}
+		byBasename[basename] = append(byBasename[basename], path)
+	}
+
+	for _, basename := range basenames {
+		conflicting := byBasename[basename]
+		if len(conflicting) > 1 {
+			log.Logger.Warnf("Found multiple vars files with the basename '%s'. They'll all be "+
+				"loaded with values in later files overriding earlier ones: %s", basename,
+				strings.Join(conflicting, ", "))
+		}
+	}
+}
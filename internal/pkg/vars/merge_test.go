@@ -19,6 +19,7 @@ package vars
 import (
 	"github.com/stretchr/testify/assert"
 	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -171,3 +172,53 @@ func TestMergingListsReplace(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, dest, expected)
 }
+
+func TestLoadFileFormats(t *testing.T) {
+	for _, format := range []string{"yml", "json", "toml"} {
+		path := getAbsPath(t, filepath.Join("../../testdata/value-merging/formats", "values."+format))
+
+		actual, err := LoadFile(path)
+		assert.Nil(t, err, "Error loading %s file", format)
+
+		// values should have the same types regardless of the format they were loaded from
+		assert.Equal(t, map[string]interface{}{
+			"name":     format,
+			"replicas": 2,
+			"ports":    []interface{}{80, 443},
+			"database": map[interface{}]interface{}{
+				"host": "db.example.com",
+				"port": 5432,
+			},
+		}, actual, "Unexpected values loaded from %s file", format)
+	}
+}
+
+// large integers in JSON files shouldn't be converted to floats
+func TestLoadJsonFileNumbers(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "vars-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	path := filepath.Join(tempDir, "values.json")
+	err = ioutil.WriteFile(path, []byte(`{"account": 123456789012, "ratio": 0.5, "ids": [210987654321]}`), 0644)
+	assert.Nil(t, err)
+
+	actual, err := LoadFile(path)
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"account": 123456789012,
+		"ratio":   0.5,
+		"ids":     []interface{}{210987654321},
+	}, actual)
+}
+
+func TestIsVarsFile(t *testing.T) {
+	for _, path := range []string{"values.yaml", "values.yml", "a/b/values.JSON", "values.toml"} {
+		assert.True(t, IsVarsFile(path), "Expected %s to be a vars file", path)
+	}
+
+	for _, path := range []string{"values", "values.txt", "values.yaml.bak"} {
+		assert.False(t, IsVarsFile(path), "Expected %s not to be a vars file", path)
+	}
+}
@@ -0,0 +1,207 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vars
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/constants"
+	"github.com/sugarkube/sugarkube/internal/pkg/program"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"strings"
+)
+
+// A kapp var set on the command line, formatted 'manifest-id:kapp-id.path.to.key=value'
+type Override struct {
+	Value string
+	// if true the value is always a string, otherwise it's parsed as YAML
+	IsString bool
+}
+
+// Parses kapp vars set on the command line. Values files are merged first, then overrides are
+// applied in the order they were given so later ones win. Values files should contain maps of
+// vars keyed by fully-qualified kapp ID. Overrides are parsed as YAML (so maps and lists can be
+// given) unless they're strings. Returns a map of vars keyed by fully-qualified kapp ID.
+func ParseOverrides(valuesFiles []string, kappOverrides []Override) (map[string]map[string]interface{}, error) {
+	overrides := map[string]map[interface{}]interface{}{}
+
+	for _, path := range valuesFiles {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error reading values file '%s'", path)
+		}
+
+		fileOverrides := map[string]map[interface{}]interface{}{}
+		err = yaml.Unmarshal(contents, &fileOverrides)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error parsing values file '%s'. It should contain maps "+
+				"of vars keyed by fully-qualified kapp ID", path)
+		}
+
+		for kappId, kappVars := range fileOverrides {
+			err = validateKappId(kappId)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Invalid kapp ID in values file '%s'", path)
+			}
+
+			existing, ok := overrides[kappId]
+			if !ok {
+				existing = map[interface{}]interface{}{}
+			}
+
+			err = Merge(&existing, kappVars)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+
+			overrides[kappId] = existing
+		}
+	}
+
+	for _, override := range kappOverrides {
+		err := setOverride(overrides, override.Value, !override.IsString)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+
+	// convert to the same types we get when loading vars files so they can be merged with them
+	result := make(map[string]map[string]interface{}, len(overrides))
+	for kappId, kappVars := range overrides {
+		converted, err := Normalise(kappVars)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		result[kappId] = converted
+	}
+
+	return result, nil
+}
+
+// Converts a map to the types we get when loading a YAML vars file (i.e. nested maps are
+// map[interface{}]interface{}). Mergo panics if it's asked to merge maps of different types.
+func Normalise(data interface{}) (map[string]interface{}, error) {
+	yamlData, err := yaml.Marshal(data)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	converted := map[string]interface{}{}
+	err = yaml.Unmarshal(yamlData, &converted)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return converted, nil
+}
+
+// Parses an override formatted 'manifest-id:kapp-id.path.to.key=value' and sets it in the map
+func setOverride(overrides map[string]map[interface{}]interface{}, override string, parseValue bool) error {
+	keyValue := strings.SplitN(override, "=", 2)
+	if len(keyValue) != 2 {
+		return program.SimpleError{Message: fmt.Sprintf("Invalid kapp var '%s'. It should be "+
+			"formatted 'manifest-id:kapp-id.path.to.key=value'", override)}
+	}
+
+	kappId, path, err := splitOverrideKey(keyValue[0])
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	var value interface{} = keyValue[1]
+
+	if parseValue {
+		err = yaml.Unmarshal([]byte(keyValue[1]), &value)
+		if err != nil {
+			return program.SimpleError{Message: fmt.Sprintf("Error parsing the value of kapp var "+
+				"'%s' as YAML. Use --set-string to set it as a string: %v", override, err)}
+		}
+	}
+
+	kappVars, ok := overrides[kappId]
+	if !ok {
+		kappVars = map[interface{}]interface{}{}
+		overrides[kappId] = kappVars
+	}
+
+	SetNested(kappVars, path, value)
+
+	return nil
+}
+
+// Splits a key formatted 'manifest-id:kapp-id.path.to.key' into the fully-qualified kapp ID and
+// the path to the key
+func splitOverrideKey(key string) (string, []string, error) {
+	separatorIndex := strings.Index(key, constants.NamespaceSeparator)
+	if separatorIndex < 0 {
+		return "", nil, program.SimpleError{Message: fmt.Sprintf("Invalid kapp var key '%s'. "+
+			"It should be formatted 'manifest-id:kapp-id.path.to.key'", key)}
+	}
+
+	elements := strings.Split(key[separatorIndex+1:], ".")
+	kappId := key[:separatorIndex+1] + elements[0]
+	path := elements[1:]
+
+	err := validateKappId(kappId)
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+
+	if len(path) == 0 {
+		return "", nil, program.SimpleError{Message: fmt.Sprintf("No path to a var given in "+
+			"kapp var key '%s'. It should be formatted 'manifest-id:kapp-id.path.to.key'", key)}
+	}
+
+	for _, element := range path {
+		if element == "" {
+			return "", nil, program.SimpleError{Message: fmt.Sprintf("Invalid path in kapp var "+
+				"key '%s'", key)}
+		}
+	}
+
+	return kappId, path, nil
+}
+
+// Returns an error unless the ID is a fully-qualified kapp ID
+func validateKappId(kappId string) error {
+	elements := strings.Split(kappId, constants.NamespaceSeparator)
+	if len(elements) != 2 || elements[0] == "" || elements[1] == "" {
+		return program.SimpleError{Message: fmt.Sprintf("'%s' isn't a fully-qualified kapp ID. "+
+			"It should be formatted 'manifest-id:kapp-id'", kappId)}
+	}
+
+	return nil
+}
+
+// Sets a value in a nested map, creating intermediate maps as necessary and replacing any
+// values that aren't maps
+func SetNested(data map[interface{}]interface{}, path []string, value interface{}) {
+	if len(path) == 1 {
+		data[path[0]] = value
+		return
+	}
+
+	child, ok := data[path[0]].(map[interface{}]interface{})
+	if !ok {
+		child = map[interface{}]interface{}{}
+		data[path[0]] = child
+	}
+
+	SetNested(child, path[1:], value)
+}
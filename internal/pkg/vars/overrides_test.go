@@ -0,0 +1,97 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vars
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseOverrides(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "overrides-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+
+	valuesFile := filepath.Join(tempDir, "values.yaml")
+	err = ioutil.WriteFile(valuesFile, []byte(`
+manifest1:kappA:
+  image:
+    repo: nginx
+    tag: "1.0"
+  replicas: 1
+`), 0644)
+	assert.Nil(t, err)
+
+	overrides, err := ParseOverrides([]string{valuesFile},
+		[]Override{
+			{Value: "manifest1:kappA.image.tag=1.1"},
+			{Value: "manifest1:kappA.hosts=[a.com, b.com]"},
+			{Value: "manifest2:kapp-b.flags={beta: true}"},
+			{Value: "manifest1:kappA.replicas=3", IsString: true},
+		})
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]map[string]interface{}{
+		"manifest1:kappA": {
+			"image": map[interface{}]interface{}{
+				"repo": "nginx",
+				"tag":  1.1,
+			},
+			"replicas": "3",
+			"hosts":    []interface{}{"a.com", "b.com"},
+		},
+		"manifest2:kapp-b": {
+			"flags": map[interface{}]interface{}{"beta": true},
+		},
+	}, overrides)
+}
+
+func TestParseOverridesErrors(t *testing.T) {
+	tests := []string{
+		"manifest1:kappA.image.tag",    // no value
+		"kappA.image.tag=1",            // not fully-qualified
+		"manifest1:kappA=1",            // no path
+		"manifest1:kappA.image..tag=1", // empty path element
+		"manifest1:kappA.image=[a, b",  // invalid YAML
+	}
+
+	for _, test := range tests {
+		_, err := ParseOverrides([]string{}, []Override{{Value: test}})
+		assert.Error(t, err, "expected an error for '%s'", test)
+	}
+}
+
+// Test that overrides are applied in the order they're given whether or not they're strings
+func TestParseOverridesOrder(t *testing.T) {
+	overrides, err := ParseOverrides([]string{}, []Override{
+		{Value: "manifest1:kappA.a=1", IsString: true},
+		{Value: "manifest1:kappA.a=2"},
+		{Value: "manifest1:kappA.b=1"},
+		{Value: "manifest1:kappA.b=2", IsString: true},
+	})
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]map[string]interface{}{
+		"manifest1:kappA": {
+			"a": 2,
+			"b": "2",
+		},
+	}, overrides)
+}
@@ -0,0 +1,275 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vars
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/constants"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Layers that vars can come from
+const (
+	SourceStackIntrinsic   = "stack intrinsic data"
+	SourceRuntime          = "runtime values"
+	SourceProviderVarsFile = "provider vars file"
+	SourceRegistry         = "registry"
+	SourceRegistryOutput   = "registry output"
+	SourceKappsVars        = "vars of another kapp"
+	SourceKappIntrinsic    = "kapp intrinsic data"
+	SourceKappVarsFile     = "kapp vars file"
+	SourceProgramDefaults  = "programs defaults"
+	SourceDescriptorVars   = "descriptor vars"
+	SourceManifestDefaults = "manifest defaults"
+	SourceManifestKapp     = "manifest kapp descriptor"
+	SourceStackDefaults    = "stack defaults"
+	SourceStackOverrides   = "stack overrides"
+	SourceKappTemplates    = "kapp templates"
+	SourceEnvOverride      = "environment variable"
+	SourceCliOverride      = "command line override"
+	SourceVarsTemplate     = "vars_template"
+	SourceSchemaDefault    = "vars_schema default"
+)
+
+// separates the elements of paths to values
+const pathSeparator = "."
+
+// Where a value came from
+type Origin struct {
+	Source string      // the layer the value came from, e.g. a provider vars file
+	Detail string      // additional details, e.g. the path to the file
+	Raw    interface{} // the value before templating
+}
+
+func (o Origin) String() string {
+	if o.Detail == "" {
+		return o.Source
+	}
+
+	return fmt.Sprintf("%s: %s", o.Source, o.Detail)
+}
+
+// Records which layer each leaf value came from, keyed by dot-separated paths to the values. Layers
+// must be recorded in the same order they're merged in for the result to be accurate.
+type Provenance map[string]Origin
+
+// A resolved value and where it came from
+type Explanation struct {
+	Path  string
+	Value interface{}
+	Origin
+}
+
+// Records that all leaf values in the fragment came from the given source. Values are nested
+// under the prefix. As when merging, empty values don't override existing ones and lists are
+// treated as leaf values.
+func (p Provenance) Record(prefix []string, fragment interface{}, source string, detail string) {
+	if p == nil {
+		return
+	}
+
+	value := reflect.ValueOf(fragment)
+	if value.IsValid() && value.Kind() == reflect.Map && value.Len() > 0 {
+		for _, key := range value.MapKeys() {
+			path := append(append([]string{}, prefix...), fmt.Sprintf("%v", key.Interface()))
+			p.Record(path, value.MapIndex(key).Interface(), source, detail)
+		}
+		return
+	}
+
+	path := strings.Join(prefix, pathSeparator)
+
+	if isEmpty(value) && p.has(path) {
+		return
+	}
+
+	// a leaf replaces anything previously nested under it, and any leaf it's now nested under
+	for existing := range p {
+		if strings.HasPrefix(existing, path+pathSeparator) ||
+			strings.HasPrefix(path, existing+pathSeparator) {
+			delete(p, existing)
+		}
+	}
+
+	p[path] = Origin{
+		Source: source,
+		Detail: detail,
+		Raw:    fragment,
+	}
+}
+
+// Records the sources in another provenance as if they were merged after the ones already recorded
+func (p Provenance) Merge(other Provenance) {
+	if p == nil {
+		return
+	}
+
+	paths := make([]string, 0, len(other))
+	for path := range other {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		origin := other[path]
+		p.Record(strings.Split(path, pathSeparator), origin.Raw, origin.Source, origin.Detail)
+	}
+}
+
+// Records the values in each vars file. Files later in the list have higher precedence.
+func (p Provenance) RecordFiles(prefix []string, source string, paths ...string) error {
+	if p == nil {
+		return nil
+	}
+
+	for i, path := range paths {
+		data, err := LoadFile(path)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		p.Record(prefix, data, source, fmt.Sprintf("%s (precedence %d of %d)", path, i+1,
+			len(paths)))
+	}
+
+	return nil
+}
+
+// Records values from the registry. Outputs are attributed to the kapp that created them.
+func (p Provenance) RecordRegistry(registry map[string]interface{}) {
+	if p == nil {
+		return
+	}
+
+	for key, value := range registry {
+		if key != constants.RegistryKeyOutputs {
+			p.Record([]string{key}, value, SourceRegistry, "")
+			continue
+		}
+
+		outputs := reflect.ValueOf(value)
+		if !outputs.IsValid() || outputs.Kind() != reflect.Map {
+			p.Record([]string{key}, value, SourceRegistry, "")
+			continue
+		}
+
+		for _, kappKey := range outputs.MapKeys() {
+			kappName := fmt.Sprintf("%v", kappKey.Interface())
+			detail := fmt.Sprintf("kapp '%s'", kappName)
+			if kappName == constants.RegistryKeyThis {
+				detail = "this kapp"
+			}
+
+			p.Record([]string{key, kappName}, outputs.MapIndex(kappKey).Interface(),
+				SourceRegistryOutput, detail)
+		}
+	}
+}
+
+// Returns an explanation for each value in the resolved vars that a source was recorded for,
+// sorted by path
+func (p Provenance) Explain(resolved map[string]interface{}) []Explanation {
+	paths := make([]string, 0, len(p))
+	for path := range p {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	explanations := make([]Explanation, 0, len(paths))
+
+	for _, path := range paths {
+		value, ok := lookup(resolved, strings.Split(path, pathSeparator))
+		if !ok {
+			continue
+		}
+
+		explanations = append(explanations, Explanation{
+			Path:   path,
+			Value:  value,
+			Origin: p[path],
+		})
+	}
+
+	return explanations
+}
+
+// Returns where the value at the path came from. If the value was set as part of a larger one
+// (e.g. an element of a list) the origin of the closest parent is returned.
+func (p Provenance) Find(path string) (Origin, bool) {
+	for path != "" {
+		if origin, ok := p[path]; ok {
+			return origin, true
+		}
+
+		index := strings.LastIndex(path, pathSeparator)
+		if index < 0 {
+			break
+		}
+		path = path[:index]
+	}
+
+	return Origin{}, false
+}
+
+// Returns whether a source has been recorded for the path or anything under it
+func (p Provenance) has(path string) bool {
+	for existing := range p {
+		if existing == path || strings.HasPrefix(existing, path+pathSeparator) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the value at the path in nested maps of either type
+func lookup(data interface{}, elements []string) (interface{}, bool) {
+	if len(elements) == 0 {
+		return data, true
+	}
+
+	value := reflect.ValueOf(data)
+	if !value.IsValid() || value.Kind() != reflect.Map {
+		return nil, false
+	}
+
+	for _, key := range value.MapKeys() {
+		if fmt.Sprintf("%v", key.Interface()) == elements[0] {
+			return lookup(value.MapIndex(key).Interface(), elements[1:])
+		}
+	}
+
+	return nil, false
+}
+
+func isEmpty(value reflect.Value) bool {
+	if !value.IsValid() {
+		return true
+	}
+
+	switch value.Kind() {
+	case reflect.Map, reflect.Slice, reflect.String, reflect.Array:
+		return value.Len() == 0
+	case reflect.Interface, reflect.Ptr:
+		return value.IsNil()
+	}
+
+	return reflect.DeepEqual(value.Interface(), reflect.Zero(value.Type()).Interface())
+}
@@ -0,0 +1,98 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vars
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestProvenanceRecord(t *testing.T) {
+	provenance := Provenance{}
+
+	provenance.Record([]string{}, map[string]interface{}{
+		"image": map[interface{}]interface{}{
+			"repo": "nginx",
+			"tag":  "1.0",
+		},
+		"replicas": 1,
+		"debug":    true,
+	}, SourceKappVarsFile, "values.yaml")
+
+	// empty values don't override existing ones, a scalar replaces a map and vice versa
+	provenance.Record([]string{}, map[string]interface{}{
+		"image": map[string]interface{}{
+			"tag": "{{ .version }}",
+		},
+		"replicas": 0,
+		"debug": map[string]interface{}{
+			"level": 2,
+		},
+	}, SourceDescriptorVars, "")
+
+	provenance.Record([]string{"image"}, "nginx:latest", SourceCliOverride, "")
+
+	assert.Equal(t, Provenance{
+		"image":       {Source: SourceCliOverride, Raw: "nginx:latest"},
+		"replicas":    {Source: SourceKappVarsFile, Detail: "values.yaml", Raw: 1},
+		"debug.level": {Source: SourceDescriptorVars, Raw: 2},
+	}, provenance)
+
+	// values nested under a recorded value are attributed to it
+	origin, ok := provenance.Find("image.tag")
+	assert.True(t, ok)
+	assert.Equal(t, SourceCliOverride, origin.Source)
+
+	_, ok = provenance.Find("debug")
+	assert.False(t, ok)
+}
+
+func TestProvenanceRegistryAndMerge(t *testing.T) {
+	provenance := Provenance{}
+
+	provenance.RecordRegistry(map[string]interface{}{
+		"kubeconfig": "/path/to/kubeconfig",
+		"outputs": map[string]interface{}{
+			"manifest1__kappA": map[string]interface{}{"endpoint": "db.local"},
+			"this":             map[string]interface{}{"id": "abc"},
+		},
+	})
+
+	assert.Equal(t, SourceRegistry, provenance["kubeconfig"].Source)
+	assert.Equal(t, Origin{Source: SourceRegistryOutput, Detail: "kapp 'manifest1__kappA'", Raw: "db.local"},
+		provenance["outputs.manifest1__kappA.endpoint"])
+	assert.Equal(t, "this kapp", provenance["outputs.this.id"].Detail)
+
+	provenance.Merge(Provenance{
+		"kubeconfig": {Source: SourceCliOverride, Raw: "/other"},
+	})
+	assert.Equal(t, SourceCliOverride, provenance["kubeconfig"].Source)
+
+	explanations := provenance.Explain(map[string]interface{}{
+		"kubeconfig": "/other",
+		"outputs": map[interface{}]interface{}{
+			"manifest1__kappA": map[interface{}]interface{}{"endpoint": "db.local"},
+		},
+	})
+
+	// paths that aren't in the resolved vars are skipped
+	assert.Equal(t, []Explanation{
+		{Path: "kubeconfig", Value: "/other", Origin: Origin{Source: SourceCliOverride, Raw: "/other"}},
+		{Path: "outputs.manifest1__kappA.endpoint", Value: "db.local", Origin: Origin{
+			Source: SourceRegistryOutput, Detail: "kapp 'manifest1__kappA'", Raw: "db.local"}},
+	}, explanations)
+}
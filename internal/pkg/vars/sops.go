@@ -0,0 +1,102 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vars
+
+import (
+	"bytes"
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/utils"
+	"strings"
+)
+
+// The SOPS binary used to decrypt and edit encrypted vars files
+var SopsCommand = "sops"
+
+// the key SOPS adds to encrypted files to store its metadata
+const sopsMetadataKey = "sops"
+
+// the prefix of values SOPS has encrypted
+const sopsEncryptedPrefix = "ENC["
+
+// Returns whether vars loaded from a file were encrypted by SOPS (which stores the keys data is
+// encrypted with (e.g. age or PGP) along with a MAC under a top-level `sops` key)
+func IsSopsEncrypted(data map[string]interface{}) bool {
+	metadata, ok := data[sopsMetadataKey].(map[interface{}]interface{})
+	if !ok {
+		return false
+	}
+
+	_, ok = metadata["mac"]
+	return ok
+}
+
+// Decrypts a SOPS-encrypted file, returning its contents in the same format
+func decryptSopsFile(path string) ([]byte, error) {
+	var stdoutBuf, stderrBuf bytes.Buffer
+
+	err := utils.ExecCommand(SopsCommand, []string{"--decrypt", path}, map[string]string{},
+		&stdoutBuf, &stderrBuf, "", 0, 0, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error decrypting SOPS file '%s'. Make sure sops is "+
+			"installed and you have access to a key it was encrypted with", path)
+	}
+
+	return stdoutBuf.Bytes(), nil
+}
+
+// Returns the decrypted values of a SOPS file whose values were encrypted in the original file, i.e.
+// excluding values SOPS left unencrypted and its metadata
+func sopsEncryptedValues(encrypted map[string]interface{}, decrypted map[string]interface{}) []interface{} {
+	values := make([]interface{}, 0)
+
+	for key, value := range encrypted {
+		if key == sopsMetadataKey {
+			continue
+		}
+		collectEncryptedValues(value, decrypted[key], &values)
+	}
+
+	return values
+}
+
+// Walks encrypted and decrypted data together collecting decrypted values that were encrypted
+func collectEncryptedValues(encrypted interface{}, decrypted interface{}, values *[]interface{}) {
+	switch typed := encrypted.(type) {
+	case string:
+		if strings.HasPrefix(typed, sopsEncryptedPrefix) {
+			*values = append(*values, decrypted)
+		}
+	case map[interface{}]interface{}:
+		decryptedMap, ok := decrypted.(map[interface{}]interface{})
+		if !ok {
+			return
+		}
+		for key, value := range typed {
+			collectEncryptedValues(value, decryptedMap[key], values)
+		}
+	case []interface{}:
+		decryptedList, ok := decrypted.([]interface{})
+		if !ok {
+			return
+		}
+		for i, value := range typed {
+			if i < len(decryptedList) {
+				collectEncryptedValues(value, decryptedList[i], values)
+			}
+		}
+	}
+}
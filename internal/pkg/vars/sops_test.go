@@ -0,0 +1,112 @@
+/*
+ * Copyright 2018 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package vars
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/sugarkube/sugarkube/internal/pkg/redact"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const encryptedYaml = `db:
+  password: ENC[AES256_GCM,data:abc,iv:def,tag:ghi,type:str]
+  user: ENC[AES256_GCM,data:jkl,iv:mno,tag:pqr,type:str]
+  host_unencrypted: db.example.com
+sops:
+  age:
+  - recipient: age1example
+  mac: ENC[AES256_GCM,data:stu,iv:vwx,tag:yz,type:str]
+  version: 3.5.0
+`
+
+const encryptedJson = `{
+  "token": "ENC[AES256_GCM,data:abc,iv:def,tag:ghi,type:str]",
+  "sops": {"pgp": [{"fp": "ABC123"}], "mac": "ENC[AES256_GCM,data:stu,iv:vwx,tag:yz,type:str]"}
+}`
+
+// Replaces the sops binary with a script that prints the decrypted contents of files (which are
+// stored alongside them with a '.decrypted' suffix)
+func fakeSops(t *testing.T, dir string) func() {
+	script := filepath.Join(dir, "sops")
+	err := ioutil.WriteFile(script, []byte("#!/bin/sh\ncat \"$2.decrypted\"\n"), 0755)
+	assert.Nil(t, err)
+
+	previous := SopsCommand
+	SopsCommand = script
+	return func() { SopsCommand = previous }
+}
+
+func TestLoadSopsFile(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "sops-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(tempDir)
+	defer fakeSops(t, tempDir)()
+	defer redact.Reset()
+
+	files := map[string]string{
+		"secrets.yaml":           encryptedYaml,
+		"secrets.yaml.decrypted": "db:\n  password: hunter22\n  user: admin\n  host_unencrypted: db.example.com\n",
+		"secrets.json":           encryptedJson,
+		"secrets.json.decrypted": `{"token": "abcdef123"}`,
+		"plain.yaml":             "region: eu-west-1\n",
+	}
+
+	for name, contents := range files {
+		err = ioutil.WriteFile(filepath.Join(tempDir, name), []byte(contents), 0600)
+		assert.Nil(t, err)
+	}
+
+	result := map[string]interface{}{}
+	err = MergePaths(&result, filepath.Join(tempDir, "plain.yaml"),
+		filepath.Join(tempDir, "secrets.yaml"), filepath.Join(tempDir, "secrets.json"))
+	assert.Nil(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"region": "eu-west-1",
+		"db": map[interface{}]interface{}{
+			"password":         "hunter22",
+			"user":             "admin",
+			"host_unencrypted": "db.example.com",
+		},
+		"token": "abcdef123",
+	}, result)
+
+	// decrypted values are masked but values SOPS left unencrypted aren't
+	assert.Equal(t, map[string]interface{}{
+		"region": "eu-west-1",
+		"db": map[interface{}]interface{}{
+			"password":         redact.Mask,
+			"user":             redact.Mask,
+			"host_unencrypted": "db.example.com",
+		},
+		"token": redact.Mask,
+	}, redact.Value(result))
+
+	redact.SetEnabled(false)
+	assert.Equal(t, "hunter22", redact.String("hunter22"))
+}
+
+func TestIsSopsEncrypted(t *testing.T) {
+	assert.False(t, IsSopsEncrypted(map[string]interface{}{"sops": "just a var"}))
+	assert.False(t, IsSopsEncrypted(map[string]interface{}{"region": "eu-west-1"}))
+	assert.True(t, IsSopsEncrypted(map[string]interface{}{
+		"sops": map[interface{}]interface{}{"mac": "ENC[...]"},
+	}))
+}
@@ -0,0 +1,232 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package watcher
+
+import (
+	"github.com/pkg/errors"
+	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"github.com/sugarkube/sugarkube/internal/pkg/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// directories that are never watched because tools write to them while kapps are running. Note
+// symlinks aren't followed, so acquired sources are found under each kapp's hidden cache dir.
+var ignoredDirs = []string{".git", ".terraform"}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// Polls directory trees for changes, mapping them back to the IDs of the things (e.g. kapps)
+// that depend on each tree. Polling is used instead of filesystem events so whole trees can be
+// watched recursively on all platforms.
+type Watcher struct {
+	interval time.Duration
+	debounce time.Duration
+	paths    map[string][]string // watched path -> IDs affected by changes under it
+	states   map[string]map[string]fileState
+}
+
+func New(interval time.Duration, debounce time.Duration) *Watcher {
+	return &Watcher{
+		interval: interval,
+		debounce: debounce,
+		paths:    map[string][]string{},
+		states:   map[string]map[string]fileState{},
+	}
+}
+
+// Watches a file or directory tree. Changes under it will be reported as affecting the given IDs.
+func (w *Watcher) Add(path string, ids ...string) error {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, id := range ids {
+		if !utils.InStringArray(w.paths[absPath], id) {
+			w.paths[absPath] = append(w.paths[absPath], id)
+		}
+	}
+
+	// make sure we have an entry even if no IDs were given
+	if _, ok := w.paths[absPath]; !ok {
+		w.paths[absPath] = []string{}
+	}
+
+	return nil
+}
+
+// Returns the watched paths
+func (w *Watcher) Paths() []string {
+	paths := make([]string, 0, len(w.paths))
+	for path := range w.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Records the current state of all watched paths. Changes are detected relative to the most
+// recent snapshot, so this should be called after doing anything that itself writes to
+// the watched paths.
+func (w *Watcher) Snapshot() error {
+	for path := range w.paths {
+		state, err := scan(path)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		w.states[path] = state
+	}
+
+	return nil
+}
+
+// Returns the sorted IDs affected by changes since the last snapshot, and takes a new snapshot
+func (w *Watcher) Changed() ([]string, error) {
+	changedIds := make([]string, 0)
+
+	for path, ids := range w.paths {
+		state, err := scan(path)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		if !equal(w.states[path], state) {
+			log.Logger.Debugf("Detected changes under '%s'", path)
+			for _, id := range ids {
+				if !utils.InStringArray(changedIds, id) {
+					changedIds = append(changedIds, id)
+				}
+			}
+		}
+
+		w.states[path] = state
+	}
+
+	sort.Strings(changedIds)
+
+	return changedIds, nil
+}
+
+// Blocks polling for changes. Once changes are detected we wait until nothing else has changed
+// for the debounce period before calling `onChange` with the affected IDs. Returns when
+// `onChange` returns an error or `stop` is closed.
+func (w *Watcher) Watch(stop <-chan struct{}, onChange func(ids []string) error) error {
+	err := w.Snapshot()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	pending := make([]string, 0)
+	var lastChange time.Time
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+
+		changedIds, err := w.Changed()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if len(changedIds) > 0 {
+			lastChange = time.Now()
+			for _, id := range changedIds {
+				if !utils.InStringArray(pending, id) {
+					pending = append(pending, id)
+				}
+			}
+			continue
+		}
+
+		if len(pending) == 0 || time.Since(lastChange) < w.debounce {
+			continue
+		}
+
+		sort.Strings(pending)
+		err = onChange(pending)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		pending = make([]string, 0)
+
+		// ignore anything written while handling the changes
+		err = w.Snapshot()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+	}
+}
+
+// Returns the state of all files under a path. Missing paths have an empty state.
+func scan(root string) (map[string]fileState, error) {
+	state := map[string]fileState{}
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// files may be deleted while we're walking
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return errors.WithStack(err)
+		}
+
+		if info.IsDir() {
+			if path != root && utils.InStringArray(ignoredDirs, info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		state[path] = fileState{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Error scanning '%s' for changes", root)
+	}
+
+	return state, nil
+}
+
+func equal(a map[string]fileState, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for path, stateA := range a {
+		stateB, ok := b[path]
+		if !ok || !stateA.modTime.Equal(stateB.modTime) || stateA.size != stateB.size {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,159 @@
+/*
+ * Copyright 2019 The Sugarkube Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package watcher
+
+import (
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/sugarkube/sugarkube/internal/pkg/log"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func init() {
+	log.ConfigureLogger("trace", false, os.Stderr)
+}
+
+func TestChanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sugarkube-watcher-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	kappA := filepath.Join(dir, "kappA")
+	kappB := filepath.Join(dir, "kappB")
+	shared := filepath.Join(dir, "shared")
+	for _, path := range []string{kappA, filepath.Join(kappB, ".terraform"), shared} {
+		assert.Nil(t, os.MkdirAll(path, 0755))
+	}
+
+	watcherObj := New(time.Millisecond, time.Millisecond)
+	assert.Nil(t, watcherObj.Add(kappA, "manifest:kappA"))
+	assert.Nil(t, watcherObj.Add(kappB, "manifest:kappB"))
+	assert.Nil(t, watcherObj.Add(shared, "manifest:kappA", "manifest:kappB"))
+	assert.Nil(t, watcherObj.Snapshot())
+
+	changed, err := watcherObj.Changed()
+	assert.Nil(t, err)
+	assert.Empty(t, changed)
+
+	// new files are detected
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(kappA, "values.yaml"), []byte("a: b"), 0644))
+	changed, err = watcherObj.Changed()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"manifest:kappA"}, changed)
+
+	// changes are only reported once
+	changed, err = watcherObj.Changed()
+	assert.Nil(t, err)
+	assert.Empty(t, changed)
+
+	// ignored directories aren't watched
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(kappB, ".terraform", "state"), []byte("x"), 0644))
+	changed, err = watcherObj.Changed()
+	assert.Nil(t, err)
+	assert.Empty(t, changed)
+
+	// modified files are detected
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(kappA, "values.yaml"), []byte("a: changed"), 0644))
+	changed, err = watcherObj.Changed()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"manifest:kappA"}, changed)
+
+	// changes to shared dirs affect all their IDs
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(shared, "vars.yaml"), []byte("x: y"), 0644))
+	changed, err = watcherObj.Changed()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"manifest:kappA", "manifest:kappB"}, changed)
+
+	// deleted files are detected
+	assert.Nil(t, os.Remove(filepath.Join(shared, "vars.yaml")))
+	changed, err = watcherObj.Changed()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"manifest:kappA", "manifest:kappB"}, changed)
+}
+
+func TestWatchDebounces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sugarkube-watcher-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	watcherObj := New(5*time.Millisecond, 50*time.Millisecond)
+	assert.Nil(t, watcherObj.Add(dir, "manifest:kapp"))
+
+	calls := make(chan []string, 10)
+	stop := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		done <- watcherObj.Watch(stop, func(ids []string) error {
+			calls <- ids
+			// files written while handling changes shouldn't trigger another call
+			return ioutil.WriteFile(filepath.Join(dir, "_generated_output.txt"), []byte("x"), 0644)
+		})
+	}()
+
+	// give the watcher time to take its initial snapshot
+	time.Sleep(20 * time.Millisecond)
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte{byte(i)}, 0644))
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case ids := <-calls:
+		assert.Equal(t, []string{"manifest:kapp"}, ids)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for changes to be reported")
+	}
+
+	// no more calls should be made
+	time.Sleep(150 * time.Millisecond)
+	assert.Empty(t, calls)
+
+	close(stop)
+	assert.Nil(t, <-done)
+}
+
+func TestWatchReturnsErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sugarkube-watcher-")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	watcherObj := New(time.Millisecond, time.Millisecond)
+	assert.Nil(t, watcherObj.Add(dir, "manifest:kapp"))
+
+	done := make(chan error)
+	go func() {
+		done <- watcherObj.Watch(nil, func(ids []string) error {
+			return errors.New("failed")
+		})
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(dir, "file.txt"), []byte("x"), 0644))
+
+	select {
+	case err := <-done:
+		assert.NotNil(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for the watcher to return")
+	}
+}